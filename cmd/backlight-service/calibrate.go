@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	redisClient "github.com/librescoot/dbc-backlight-service/internal/redis"
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// runCalibrate implements `calibrate`: for each ambient condition the user
+// sets up, it measures lux, sweeps the live panel through its brightness
+// range so the user can pick the step that looks right, and finally emits
+// the captured points as a config file for -config.
+// The service must be stopped while calibrating, or it will fight the
+// sweep writes.
+func runCalibrate(args []string) int {
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	redisURL := fs.String("redis-url", defaultRedisURL, "Redis URL")
+	sensorPath := fs.String("sensor-path", "", "Read lux from this IIO file instead of Redis")
+	backlightPath := fs.String("backlight-path", "/sys/class/backlight/backlight/brightness", "Path to backlight brightness file")
+	samples := fs.Int("samples", 10, "Lux samples averaged per measurement")
+	steps := fs.Int("steps", 12, "Brightness steps in each sweep")
+	minBrightness := fs.Int("min-brightness", 400, "Lowest brightness in the sweep")
+	maxBrightness := fs.Int("max-brightness", 0, "Highest brightness in the sweep (0 = max_brightness from sysfs)")
+	output := fs.String("o", "", "Write a config file with the resulting curve to this path")
+	fs.Parse(args)
+
+	panel := &calibrationPanel{path: *backlightPath}
+	max := *maxBrightness
+	if max <= 0 {
+		var err error
+		if max, err = panel.maxBrightness(); err != nil {
+			fmt.Fprintf(os.Stderr, "calibrate: %v (use -max-brightness)\n", err)
+			return 1
+		}
+	}
+	if *steps < 2 || max <= *minBrightness {
+		fmt.Fprintf(os.Stderr, "calibrate: need at least 2 steps and max-brightness above min-brightness\n")
+		return 2
+	}
+	sweep := sweepValues(*minBrightness, max, *steps)
+
+	var redis *redisClient.Client
+	if *sensorPath == "" {
+		var err error
+		if redis, err = connectRedis(*redisURL); err != nil {
+			fmt.Fprintf(os.Stderr, "calibrate: %v\n", err)
+			return 1
+		}
+		defer redis.Close()
+	}
+
+	readLux := func() (float64, error) {
+		if *sensorPath != "" {
+			data, err := os.ReadFile(*sensorPath)
+			if err != nil {
+				return 0, err
+			}
+			return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+		defer cancel()
+		il, err := redis.GetIlluminance(ctx)
+		return il.Lux, err
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	prompt := func(format string, args ...interface{}) (string, bool) {
+		fmt.Printf(format, args...)
+		if !in.Scan() {
+			return "", false
+		}
+		return strings.TrimSpace(in.Text()), true
+	}
+
+	if original, err := panel.read(); err == nil {
+		defer panel.write(original)
+	}
+
+	fmt.Println("Stop dbc-backlight before calibrating so it doesn't overwrite the sweep.")
+	var points []backlight.Point
+	for {
+		line, ok := prompt("\nSet up the ambient light, then press Enter to measure (q to finish): ")
+		if !ok || line == "q" {
+			break
+		}
+
+		lux, err := averageLux(readLux, *samples)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "calibrate: failed to read lux: %v\n", err)
+			continue
+		}
+		fmt.Printf("Measured %.2f lux. Sweeping: Enter = next, b = back, y = pick, s = skip, or type a raw value.\n", lux)
+
+		brightness, ok := pickBrightness(panel, sweep, prompt)
+		if ok {
+			points = append(points, backlight.Point{Lux: lux, Brightness: brightness})
+			fmt.Printf("Captured %.2f:%d\n", lux, brightness)
+		}
+	}
+
+	if len(points) < 2 {
+		fmt.Fprintf(os.Stderr, "calibrate: need at least 2 points, got %d\n", len(points))
+		return 1
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Lux < points[j].Lux })
+	curve := backlight.FormatCurve(points)
+
+	if *output == "" {
+		fmt.Printf("\ncurve = \"%s\"\n", curve)
+		return 0
+	}
+	contents := fmt.Sprintf("# Generated by dbc-backlight calibrate on %s\ncurve = \"%s\"\n",
+		time.Now().Format(time.RFC3339), curve)
+	if err := os.WriteFile(*output, []byte(contents), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "calibrate: failed to write config: %v\n", err)
+		return 1
+	}
+	fmt.Printf("\nWrote %s; use it with dbc-backlight -config %s\n", *output, *output)
+	return 0
+}
+
+// pickBrightness sweeps the panel through values until the user picks one.
+func pickBrightness(panel *calibrationPanel, values []int, prompt func(string, ...interface{}) (string, bool)) (int, bool) {
+	i := 0
+	current := values[0]
+	for {
+		if err := panel.write(current); err != nil {
+			fmt.Fprintf(os.Stderr, "calibrate: failed to write brightness: %v\n", err)
+			return 0, false
+		}
+		line, ok := prompt("[%2d/%d] brightness %d: ", i+1, len(values), current)
+		if !ok {
+			return 0, false
+		}
+		switch line {
+		case "":
+			if i < len(values)-1 {
+				i++
+			}
+			current = values[i]
+		case "b":
+			if i > 0 {
+				i--
+			}
+			current = values[i]
+		case "y":
+			return current, true
+		case "s":
+			return 0, false
+		default:
+			v, err := strconv.Atoi(line)
+			if err != nil || v < 0 {
+				fmt.Println("Enter, b, y, s or a non-negative raw brightness value")
+				continue
+			}
+			current = v
+		}
+	}
+}
+
+// sweepValues returns n evenly spaced brightness values from min to max.
+func sweepValues(min, max, n int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = min + (max-min)*i/(n-1)
+	}
+	return values
+}
+
+// calibrationPanel is direct sysfs access for the sweep.
+type calibrationPanel struct {
+	path string
+}
+
+func (p *calibrationPanel) read() (int, error) {
+	return readIntFile(p.path)
+}
+
+func (p *calibrationPanel) write(v int) error {
+	return os.WriteFile(p.path, []byte(strconv.Itoa(v)), 0644)
+}
+
+func (p *calibrationPanel) maxBrightness() (int, error) {
+	return readIntFile(filepath.Join(filepath.Dir(p.path), "max_brightness"))
+}
+
+func readIntFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// averageLux averages n readings taken 100ms apart, rounded to 0.01 lux.
+func averageLux(read func() (float64, error), n int) (float64, error) {
+	if n < 1 {
+		n = 1
+	}
+	var sum float64
+	for i := 0; i < n; i++ {
+		lux, err := read()
+		if err != nil {
+			return 0, err
+		}
+		sum += lux
+		if i < n-1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return math.Round(sum/float64(n)*100) / 100, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+)
+
+// runCheck implements `check`: it parses the service flags and -config,
+// validates them together with the configured device paths, and prints
+// every problem found. It exits 0 if the configuration is usable and 1
+// otherwise, for image builds and pre-deploy hooks.
+func runCheck(args []string) int {
+	cfg := config.New()
+	if err := cfg.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "check: %v\n", err)
+		return 1
+	}
+	errs := service.Check(cfg)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "check: %v\n", err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Println("configuration ok")
+	return 0
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	redisClient "github.com/librescoot/dbc-backlight-service/internal/redis"
+)
+
+const defaultRedisURL = "redis://192.168.7.1:6379"
+
+// cliTimeout bounds every client subcommand's Redis or HTTP traffic.
+const cliTimeout = 5 * time.Second
+
+// connectRedis creates a quiet Redis client for the client subcommands.
+// Credentials come from the URL, $REDIS_USERNAME and $REDIS_PASSWORD.
+func connectRedis(redisURL string) (*redisClient.Client, error) {
+	password, err := config.LookupRedisPassword("", "")
+	if err != nil {
+		return nil, err
+	}
+	return redisClient.New(redisURL, log.New(io.Discard, "", 0), redisClient.Options{
+		ReadTimeout:  cliTimeout,
+		WriteTimeout: cliTimeout,
+		Username:     os.Getenv("REDIS_USERNAME"),
+		Password:     password,
+		DB:           -1,
+	})
+}
+
+// cliState is what `status` and `get` report when querying via Redis.
+type cliState struct {
+	Lux       string `json:"lux"`
+	Backlight string `json:"backlight"`
+	Enabled   string `json:"enabled"`
+	Mode      string `json:"mode"`
+	Service   string `json:"service"`
+}
+
+func readCLIState(ctx context.Context, redis *redisClient.Client) (*cliState, error) {
+	dashboard, err := redis.GetDashboard(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dashboard: %v", err)
+	}
+	mode, err := redis.GetBacklightMode(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backlight mode: %v", err)
+	}
+	service, err := redis.GetServiceState(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service state: %v", err)
+	}
+
+	st := &cliState{
+		Lux:       dashboard["brightness"],
+		Backlight: dashboard["backlight"],
+		Enabled:   dashboard["backlight-enabled"],
+		Mode:      mode,
+		Service:   service,
+	}
+	if st.Enabled == "" {
+		st.Enabled = "true"
+	}
+	if st.Service == "" {
+		st.Service = "unknown"
+	}
+	return st, nil
+}
+
+// runStatus implements `status`: the service's own view via the HTTP API
+// when -http-addr is given, otherwise the state published in Redis.
+func runStatus(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	redisURL := fs.String("redis-url", defaultRedisURL, "Redis URL")
+	httpAddr := fs.String("http-addr", "", "Query the service's HTTP API at this address instead of Redis")
+	asJSON := fs.Bool("json", false, "Print JSON")
+	fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+	defer cancel()
+
+	if *httpAddr != "" {
+		return statusHTTP(ctx, *httpAddr, *asJSON)
+	}
+
+	redis, err := connectRedis(*redisURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "status: %v\n", err)
+		return 1
+	}
+	defer redis.Close()
+
+	st, err := readCLIState(ctx, redis)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "status: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(st)
+		return 0
+	}
+	fmt.Printf("service:   %s\n", st.Service)
+	fmt.Printf("mode:      %s\n", st.Mode)
+	fmt.Printf("enabled:   %s\n", st.Enabled)
+	fmt.Printf("lux:       %s\n", st.Lux)
+	fmt.Printf("backlight: %s\n", st.Backlight)
+	return 0
+}
+
+func statusHTTP(ctx context.Context, addr string, asJSON bool) int {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+"/status", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "status: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "status: %s\n", resp.Status)
+		return 1
+	}
+	if asJSON {
+		io.Copy(os.Stdout, resp.Body)
+		return 0
+	}
+
+	var st map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		fmt.Fprintf(os.Stderr, "status: invalid response: %v\n", err)
+		return 1
+	}
+	keys := make([]string, 0, len(st))
+	for k := range st {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%-14s %v\n", k+":", st[k])
+	}
+	return 0
+}
+
+// runGet implements `get <field>`, printing one value for scripts.
+func runGet(args []string) int {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	redisURL := fs.String("redis-url", defaultRedisURL, "Redis URL")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dbc-backlight get [flags] lux|backlight|mode|enabled|service\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+	defer cancel()
+
+	redis, err := connectRedis(*redisURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "get: %v\n", err)
+		return 1
+	}
+	defer redis.Close()
+
+	st, err := readCLIState(ctx, redis)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "get: %v\n", err)
+		return 1
+	}
+
+	values := map[string]string{
+		"lux":       st.Lux,
+		"backlight": st.Backlight,
+		"mode":      st.Mode,
+		"enabled":   st.Enabled,
+		"service":   st.Service,
+	}
+	v, ok := values[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "get: unknown field %q\n", fs.Arg(0))
+		return 2
+	}
+	fmt.Println(v)
+	return 0
+}
+
+// runSet implements `set -level` and `set -brightness`, writing the
+// backlight settings the same way the dashboard settings menu does.
+func runSet(args []string) int {
+	fs := flag.NewFlagSet("set", flag.ExitOnError)
+	redisURL := fs.String("redis-url", defaultRedisURL, "Redis URL")
+	level := fs.String("level", "", "Backlight mode: auto, manual, a level name (low, medium, high) or fixed:<level|value>")
+	brightness := fs.Int("brightness", -1, "Raw brightness applied in manual mode")
+	fs.Parse(args)
+
+	mode := strings.ToLower(*level)
+	if mode == "" && *brightness < 0 {
+		fmt.Fprintf(os.Stderr, "set: -level or -brightness is required\n")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+	defer cancel()
+
+	redis, err := connectRedis(*redisURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "set: %v\n", err)
+		return 1
+	}
+	defer redis.Close()
+
+	if *brightness >= 0 {
+		if err := redis.SetManualBrightness(ctx, *brightness); err != nil {
+			fmt.Fprintf(os.Stderr, "set: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Manual brightness set to %d\n", *brightness)
+	}
+	if mode != "" {
+		if err := redis.SetBacklightMode(ctx, mode); err != nil {
+			fmt.Fprintf(os.Stderr, "set: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Backlight mode set to %s\n", mode)
+	}
+	return 0
+}
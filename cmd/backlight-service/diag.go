@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/internal/diag"
+)
+
+// runDiag implements the `diag` subcommand: it collects a support bundle
+// from Redis and the local sysfs node and writes it to stdout or a file.
+func runDiag(args []string) int {
+	fs := flag.NewFlagSet("diag", flag.ExitOnError)
+	redisURL := fs.String("redis-url", "redis://192.168.7.1:6379", "Redis URL")
+	backlightPath := fs.String("backlight-path", "/sys/class/backlight/backlight/brightness", "Path to backlight brightness file")
+	output := fs.String("o", "", "Output file (.json, or .tar.gz for a tarball); stdout if empty")
+	timeout := fs.Duration("timeout", 5*time.Second, "Timeout for collecting data from Redis")
+	fs.Parse(args)
+
+	redis, err := connectRedis(*redisURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diag: %v\n", err)
+		return 1
+	}
+	defer redis.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	bundle := diag.Collect(ctx, redis, *backlightPath, version)
+
+	if *output == "" {
+		err = bundle.Write(os.Stdout)
+	} else {
+		err = bundle.WriteFile(*output)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diag: failed to write bundle: %v\n", err)
+		return 1
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Wrote diagnostic bundle to %s\n", *output)
+	}
+	return 0
+}
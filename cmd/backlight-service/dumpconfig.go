@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+)
+
+// dumpConfig prints the effective configuration for -dump-config, each
+// value with where it came from, as YAML or JSON.
+func dumpConfig(w io.Writer, cfg *config.Config, format string) error {
+	settings := cfg.Settings()
+	switch format {
+	case "yaml":
+		fmt.Fprintln(w, "# Effective configuration; each comment names where the value came from.")
+		for _, st := range settings {
+			// Go's quoting is a valid YAML double-quoted scalar.
+			fmt.Fprintf(w, "%s: %s # %s\n", st.Name, strconv.Quote(st.Value), st.Source)
+		}
+		return nil
+	case "json":
+		type entry struct {
+			Value  string `json:"value"`
+			Source string `json:"source"`
+		}
+		out := make(map[string]entry, len(settings))
+		for _, st := range settings {
+			out[st.Name] = entry{Value: st.Value, Source: st.Source}
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	return fmt.Errorf("invalid dump-format %q (expected yaml or json)", format)
+}
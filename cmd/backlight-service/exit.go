@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+)
+
+// Exit codes of the service, from sysexits(3) where one fits, so a unit
+// can set RestartPreventExitStatus for the ones a restart won't fix. 1
+// stays a generic failure, 2 a usage error and 3 to 5 belong to -once.
+const (
+	exitFailure          = 1
+	exitRedisUnreachable = 69 // EX_UNAVAILABLE: Redis gone for -redis-give-up
+	exitPanic            = 70 // EX_SOFTWARE: internal error
+	exitHardwareMissing  = 72 // EX_OSFILE: PWM or I2C backlight device can't be opened
+	exitConfig           = 78 // EX_CONFIG: invalid configuration
+)
+
+// exitCode maps an error from service.New or Run to an exit code. Only
+// invalid settings get exitConfig; anything else may be transient.
+func exitCode(err error) int {
+	var cfgErr *service.ConfigError
+	var hwErr *service.HardwareError
+	switch {
+	case errors.As(err, &cfgErr):
+		return exitConfig
+	case errors.As(err, &hwErr):
+		return exitHardwareMissing
+	case errors.Is(err, service.ErrRedisUnreachable):
+		return exitRedisUnreachable
+	}
+	return exitFailure
+}
+
+// failed logs like log.Printf and returns code, for runService to return
+// to main; exiting there would skip runService's deferred cleanup.
+func failed(code int, format string, args ...interface{}) int {
+	log.Output(2, fmt.Sprintf(format, args...))
+	return code
+}
+
+// exitOnPanic turns a panic on the calling goroutine into exitPanic, after
+// logging it with the stack. Deferred first in runService, so its other
+// deferred cleanup runs before the exit.
+func exitOnPanic() {
+	if r := recover(); r != nil {
+		log.Printf("Panic: %v\n%s", r, debug.Stack())
+		os.Exit(exitPanic)
+	}
+}
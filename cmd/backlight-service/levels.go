@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// runLevels implements `levels`: it prints perceptually even brightness
+// values for the given level names, ready for -manual-levels.
+func runLevels(args []string) int {
+	fs := flag.NewFlagSet("levels", flag.ExitOnError)
+	min := fs.Int("min", 1000, "Brightness of the dimmest level")
+	max := fs.Int("max", 10240, "Brightness of the brightest level")
+	gamma := fs.Float64("gamma", 2.2, "Display gamma; 1 spaces the values linearly")
+	names := fs.String("names", "low medium high", "Level names, dimmest first")
+	fs.Parse(args)
+
+	list := strings.Fields(*names)
+	values, err := backlight.GammaSteps(*min, *max, len(list), *gamma)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "levels: %v\n", err)
+		return 2
+	}
+	pairs := make([]string, len(list))
+	for i, name := range list {
+		pairs[i] = fmt.Sprintf("%s:%d", name, values[i])
+	}
+	fmt.Println(strings.Join(pairs, " "))
+	return 0
+}
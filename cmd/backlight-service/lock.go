@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockPollInterval is how often a held instance lock is retried.
+const lockPollInterval = 100 * time.Millisecond
+
+// acquireLock takes an exclusive flock on path so two instances never drive
+// the same backlight. During an update the old unit may still be stopping,
+// so a held lock is retried for up to wait. The lock is released when the
+// process exits; the returned file must stay open until then.
+func acquireLock(path string, wait time.Duration) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if err != unix.EWOULDBLOCK || time.Now().After(deadline) {
+			holder, _ := os.ReadFile(path)
+			f.Close()
+			if err == unix.EWOULDBLOCK {
+				return nil, fmt.Errorf("another instance (pid %s) holds %s", pidOf(holder), path)
+			}
+			return nil, fmt.Errorf("failed to lock %s: %v", path, err)
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return f, nil
+}
+
+func pidOf(holder []byte) string {
+	if pid := strings.TrimSpace(string(holder)); pid != "" {
+		return pid
+	}
+	return "unknown"
+}
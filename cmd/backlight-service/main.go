@@ -4,40 +4,173 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/librescoot/dbc-backlight-service/internal/api"
 	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/internal/dbus"
+	"github.com/librescoot/dbc-backlight-service/internal/debug"
+	"github.com/librescoot/dbc-backlight-service/internal/logging"
+	"github.com/librescoot/dbc-backlight-service/internal/mqtt"
 	"github.com/librescoot/dbc-backlight-service/internal/service"
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
 )
 
-var version = "dev"
+const usage = `Usage: dbc-backlight [command] [flags]
+
+Commands:
+  run        run the backlight service (default)
+  status     show the current backlight state
+  get        print a single value (lux, backlight, mode, enabled, service)
+  set        set the backlight mode (-level) or manual brightness (-brightness)
+  calibrate  capture lux:brightness points for the -curve flag
+  levels     print perceptually even brightness values for -manual-levels
+  diag       collect a diagnostic bundle for bug reports
+  selftest   sweep the panel, verify every write and the sensor response
+  simulate   print the lux-to-brightness transfer table of a configuration
+  suggest    suggest a -curve from the lux seen while riding
+  pipe       read lux lines on stdin, print each decision as JSON
+  check      validate a configuration and its device paths
+  version    print the version, commit and build date
+
+Run 'dbc-backlight <command> -h' for command flags.
+`
 
 func main() {
+	bootTime := time.Now()
+
+	args := os.Args[1:]
+	cmd := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "run":
+		os.Exit(runService(bootTime, args))
+	case "status":
+		os.Exit(runStatus(args))
+	case "get":
+		os.Exit(runGet(args))
+	case "set":
+		os.Exit(runSet(args))
+	case "calibrate":
+		os.Exit(runCalibrate(args))
+	case "diag":
+		os.Exit(runDiag(args))
+	case "levels":
+		os.Exit(runLevels(args))
+	case "selftest":
+		os.Exit(runSelftest(args))
+	case "check":
+		os.Exit(runCheck(args))
+	case "simulate":
+		os.Exit(runSimulate(args))
+	case "suggest":
+		os.Exit(runSuggest(args))
+	case "pipe":
+		os.Exit(runPipe(args))
+	case "version":
+		os.Exit(runVersion(args))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
+
+func runService(bootTime time.Time, args []string) int {
+	defer exitOnPanic()
 	showVersion := flag.Bool("version", false, "Print version and exit")
+	once := flag.Bool("once", false, "Run a single read, adjust and publish cycle and exit (see README for exit codes)")
+	dump := flag.Bool("dump-config", false, "Print the effective configuration with the source of each value and exit")
+	dumpFormat := flag.String("dump-format", "yaml", "Format of -dump-config: yaml or json")
 	cfg := config.New()
-	cfg.Parse()
+	if err := cfg.Parse(args); err != nil {
+		return failed(exitConfig, "Invalid configuration: %v", err)
+	}
 
 	if *showVersion {
-		fmt.Printf("dbc-backlight %s\n", version)
-		return
+		return runVersion(nil)
+	}
+	if *dump {
+		if err := dumpConfig(os.Stdout, cfg, *dumpFormat); err != nil {
+			return failed(exitConfig, "Invalid configuration: %v", err)
+		}
+		return 0
 	}
 
 	var logger *log.Logger
-	if os.Getenv("JOURNAL_STREAM") != "" {
+	if cfg.LogFile != "" {
+		file, err := logging.OpenFile(cfg.LogFile, cfg.LogMaxSize, cfg.LogMaxAge, cfg.LogBackups)
+		if err != nil {
+			return failed(exitFailure, "Failed to open log file: %v", err)
+		}
+		defer file.Close()
+		logger = log.New(file, "dbc-backlight: ", log.LstdFlags|log.Lmsgprefix)
+		log.SetOutput(io.MultiWriter(os.Stderr, file))
+	} else if os.Getenv("JOURNAL_STREAM") != "" {
 		logger = log.New(os.Stdout, "", 0)
+		if cfg.LogJournal {
+			if journal, err := logging.DialJournal("", "dbc-backlight"); err == nil {
+				journal.Fallback = os.Stdout
+				defer journal.Close()
+				logger.SetOutput(journal)
+				log.SetOutput(journal)
+				log.SetFlags(0)
+			} else {
+				logger.Printf("Warning: %v; logging to stdout", err)
+			}
+		}
 	} else {
 		logger = log.New(os.Stdout, "dbc-backlight: ", log.LstdFlags|log.Lmsgprefix)
 	}
 
+	// Right after parsing, so the panel is right before the lock, the
+	// device wait and Redis, which can each take seconds.
+	early := service.WriteEarly(cfg, logger)
+
+	if cfg.LockFile != "" {
+		lock, err := acquireLock(cfg.LockFile, cfg.LockWait)
+		if err != nil {
+			return failed(exitFailure, "Refusing to start: %v", err)
+		}
+		defer lock.Close()
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	svc, err := service.New(cfg, logger, version)
+	if cfg.DeviceWait > 0 {
+		device := cfg.SysBacklightPath
+		switch cfg.Sink {
+		case "pwm":
+			device = cfg.PWMChip
+		case "i2c":
+			device = cfg.I2CBus
+		}
+		// A panel that probes late still gets picked up by the first write.
+		if err := backlight.WaitForDevice(ctx, device, cfg.DeviceWait); err != nil {
+			logger.Printf("Warning: %v", err)
+		}
+	}
+
+	svc, err := service.New(cfg, logger, buildInfo())
 	if err != nil {
-		log.Fatalf("Failed to create service: %v", err)
+		return failed(exitCode(err), "Failed to create service: %v", err)
+	}
+	svc.BootTime = bootTime
+	svc.Early = early
+
+	if *once {
+		return runOnce(ctx, svc, logger)
 	}
 
 	sigChan := make(chan os.Signal, 1)
@@ -47,7 +180,53 @@ func main() {
 		cancel()
 	}()
 
+	// SIGUSR1 forces an immediate adjustment, SIGUSR2 dumps state to the log.
+	debugChan := make(chan os.Signal, 1)
+	signal.Notify(debugChan, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range debugChan {
+			if sig == syscall.SIGUSR1 {
+				svc.TriggerAdjust()
+			} else {
+				svc.TriggerDump()
+			}
+		}
+	}()
+
+	if cfg.HTTPAddr != "" {
+		go func() {
+			if err := api.New(svc, logger).ListenAndServe(ctx, cfg.HTTPAddr); err != nil {
+				logger.Printf("HTTP API failed: %v", err)
+			}
+		}()
+	}
+
+	if cfg.DebugAddr != "" {
+		go func() {
+			if err := debug.New(svc, logger).ListenAndServe(ctx, cfg.DebugAddr); err != nil {
+				logger.Printf("Debug server failed: %v", err)
+			}
+		}()
+	}
+
+	if cfg.DBusBus != "" {
+		if _, err := dbus.New(ctx, svc, logger, cfg.DBusBus); err != nil {
+			logger.Printf("D-Bus interface disabled: %v", err)
+		}
+	}
+
+	if cfg.MQTTBroker != "" {
+		bridge, err := mqtt.New(ctx, svc, logger, cfg)
+		if err != nil {
+			return failed(exitConfig, "Invalid MQTT configuration: %v", err)
+		}
+		if bridge.Lux != nil {
+			svc.SetSource(bridge.Lux)
+		}
+	}
+
 	if err := svc.Run(ctx); err != nil {
-		log.Fatalf("Service failed: %v", err)
+		return failed(exitCode(err), "Service failed: %v", err)
 	}
+	return 0
 }
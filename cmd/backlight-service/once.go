@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+)
+
+// Exit codes of -once. 1 and 2 keep their usual meaning of a fatal error
+// and a usage error.
+const (
+	onceOK            = 0
+	onceReadFailed    = 3
+	onceWriteFailed   = 4
+	oncePublishFailed = 5
+)
+
+func runOnce(ctx context.Context, svc *service.Service, logger *log.Logger) int {
+	err := svc.RunOnce(ctx)
+	if err == nil {
+		return onceOK
+	}
+	logger.Printf("Failed single cycle: %v", err)
+	var cycleErr *service.CycleError
+	if !errors.As(err, &cycleErr) {
+		return 1
+	}
+	switch cycleErr.Step {
+	case "read":
+		return onceReadFailed
+	case "write":
+		return onceWriteFailed
+	default:
+		return oncePublishFailed
+	}
+}
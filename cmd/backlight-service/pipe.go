@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+)
+
+// runPipe implements `pipe`: it takes the service flags (and -config),
+// reads lux values from stdin and prints the decision for each as a JSON
+// line on stdout, for driving the control logic from test scripts.
+// Engine log lines go to stderr.
+func runPipe(args []string) int {
+	learned := flag.String("learned", "", "Learned curve offsets to apply, as in the offsets field of backlight:learned")
+	cfg := config.New()
+	if err := cfg.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "pipe: %v\n", err)
+		return 1
+	}
+	logger := log.New(os.Stderr, "dbc-backlight: ", log.Lmsgprefix)
+	if err := service.RunPipe(cfg, *learned, os.Stdin, os.Stdout, logger); err != nil {
+		fmt.Fprintf(os.Stderr, "pipe: %v\n", err)
+		return 1
+	}
+	return 0
+}
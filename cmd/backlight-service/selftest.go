@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// runSelftest implements `selftest` for end-of-line testing: it ramps the
+// panel from min to max and back, reads every write back, and checks that
+// the lux sensor sees the panel get brighter. Any failure exits 1. It takes
+// the service flags, so -sink and -config pick the output it tests.
+// Like calibrate, it needs the service stopped.
+func runSelftest(args []string) int {
+	steps := flag.Int("steps", 11, "Brightness steps from min to max")
+	minBrightness := flag.Int("min-brightness", 0, "Lowest brightness in the sweep")
+	settle := flag.Duration("settle", 100*time.Millisecond, "Wait after each write before reading back")
+	samples := flag.Int("samples", 5, "Lux samples averaged per measurement")
+	minLuxDelta := flag.Float64("min-lux-delta", 0.5, "Lux increase the sensor must see between min and max brightness (0 skips the sensor check)")
+	cfg := config.New()
+	if err := cfg.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: %v\n", err)
+		return 1
+	}
+
+	sink, err := service.NewSink(cfg, log.New(os.Stderr, "selftest: ", 0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: %v\n", err)
+		return 1
+	}
+	if _, ok := sink.(*backlight.MemorySink); ok {
+		fmt.Fprintf(os.Stderr, "selftest: backlight hardware missing\n")
+		return 1
+	}
+	if c, ok := sink.(io.Closer); ok {
+		defer c.Close()
+	}
+	max := cfg.MaxBrightness
+	if max <= 0 {
+		if max = sink.Max(); max <= 0 {
+			fmt.Fprintf(os.Stderr, "selftest: cannot read the sink's maximum (use -max-brightness)\n")
+			return 1
+		}
+	}
+	if *steps < 2 || max <= *minBrightness {
+		fmt.Fprintf(os.Stderr, "selftest: need at least 2 steps and max-brightness above min-brightness\n")
+		return 2
+	}
+
+	var readLux func() (float64, error)
+	if *minLuxDelta > 0 {
+		if cfg.SensorPath != "" {
+			readLux = func() (float64, error) {
+				data, err := os.ReadFile(cfg.SensorPath)
+				if err != nil {
+					return 0, err
+				}
+				return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+			}
+		} else {
+			redis, err := connectRedis(cfg.RedisURL)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "selftest: %v\n", err)
+				return 1
+			}
+			defer redis.Close()
+			readLux = func() (float64, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+				defer cancel()
+				il, err := redis.GetIlluminance(ctx)
+				return il.Lux, err
+			}
+		}
+	}
+
+	if original, err := sink.Read(); err == nil {
+		defer sink.Write(original)
+	}
+
+	t := &selftest{sink: sink, settle: *settle}
+	up := sweepValues(*minBrightness, max, *steps)
+	var luxAtMin, luxAtMax float64
+	for i, v := range up {
+		t.set(v)
+		if readLux != nil && (i == 0 || i == len(up)-1) {
+			lux, err := averageLux(readLux, *samples)
+			if err != nil {
+				t.fail("read lux at brightness %d: %v", v, err)
+				readLux = nil
+				continue
+			}
+			fmt.Printf("  lux at brightness %d: %.2f\n", v, lux)
+			if i == 0 {
+				luxAtMin = lux
+			} else {
+				luxAtMax = lux
+			}
+		}
+	}
+	for i := len(up) - 2; i >= 0; i-- {
+		t.set(up[i])
+	}
+
+	if readLux != nil {
+		if delta := luxAtMax - luxAtMin; delta < *minLuxDelta {
+			t.fail("sensor rose %.2f lux from min to max brightness, want at least %.2f", delta, *minLuxDelta)
+		} else {
+			fmt.Printf("ok   sensor rose %.2f lux\n", delta)
+		}
+	}
+
+	if t.failures > 0 {
+		fmt.Printf("FAIL %d check(s) failed\n", t.failures)
+		return 1
+	}
+	fmt.Printf("PASS %d writes verified\n", t.writes)
+	return 0
+}
+
+type selftest struct {
+	sink     backlight.Sink
+	settle   time.Duration
+	writes   int
+	failures int
+}
+
+// set writes value and checks that the driver reports it back.
+func (t *selftest) set(value int) {
+	if err := t.sink.Write(value); err != nil {
+		t.fail("write %d: %v", value, err)
+		return
+	}
+	time.Sleep(t.settle)
+	got, err := t.sink.Read()
+	switch {
+	case err != nil:
+		t.fail("read back %d: %v", value, err)
+	case got != value:
+		t.fail("wrote %d, read back %d", value, got)
+	default:
+		t.writes++
+		fmt.Printf("ok   brightness %d\n", value)
+	}
+}
+
+func (t *selftest) fail(format string, args ...interface{}) {
+	t.failures++
+	fmt.Printf("FAIL "+format+"\n", args...)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// runSimulate implements `simulate`: it takes the service flags (and
+// -config) and prints the transfer table of the resulting curve, the
+// target for each lux and the band of readings that hold it, without
+// touching Redis or the panel.
+func runSimulate(args []string) int {
+	to := flag.Float64("to", 100, "Highest lux in the table")
+	step := flag.Float64("step", 1, "Lux between rows")
+	format := flag.String("format", "text", "Output format: text or csv")
+	cfg := config.New()
+	if err := cfg.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "simulate: %v\n", err)
+		return 1
+	}
+	if *step <= 0 || *to < 0 || (*format != "text" && *format != "csv") {
+		fmt.Fprintf(os.Stderr, "simulate: need -step > 0, -to >= 0 and -format text or csv\n")
+		return 2
+	}
+
+	units, err := service.UnitsFor(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simulate: %v\n", err)
+		return 1
+	}
+	curve, err := backlight.ParseCurveIn(cfg.Curve, units)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simulate: invalid curve: %v\n", err)
+		return 1
+	}
+	m, err := backlight.NewManager(noSink{max: cfg.MaxBrightness}, backlight.Config{
+		Curve:         curve,
+		LuxAlpha:      cfg.LuxAlpha,
+		LogLux:        cfg.LogLux,
+		LuxHysteresis: cfg.LuxHysteresis,
+		MaxBrightness: cfg.MaxBrightness,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simulate: %v\n", err)
+		return 1
+	}
+	max := cfg.MaxBrightness
+	for _, p := range curve {
+		if cfg.MaxBrightness <= 0 && p.Brightness > max {
+			max = p.Brightness
+		}
+	}
+
+	if *format == "csv" {
+		fmt.Println("lux,brightness,percent,hold_low,hold_high")
+	} else {
+		fmt.Printf("%10s %10s %7s %10s %10s\n", "lux", "brightness", "percent", "hold_low", "hold_high")
+	}
+	// Count rows rather than accumulating the step, so -step 0.1 doesn't
+	// drift.
+	for i := 0; float64(i)**step <= *to+1e-9; i++ {
+		row := m.Transfer(float64(i) * *step)
+		percent := 0
+		if max > 0 {
+			percent = (row.Brightness*100 + max/2) / max
+		}
+		high := fmt.Sprintf("%.2f", row.HoldHigh)
+		if math.IsInf(row.HoldHigh, 1) {
+			high = "inf"
+		}
+		if *format == "csv" {
+			fmt.Printf("%g,%d,%d,%.2f,%s\n", row.Lux, row.Brightness, percent, row.HoldLow, high)
+		} else {
+			fmt.Printf("%10.2f %10d %6d%% %10.2f %10s\n", row.Lux, row.Brightness, percent, row.HoldLow, high)
+		}
+	}
+	return 0
+}
+
+// noSink stands in for the panel where only the Manager's arithmetic is
+// needed.
+type noSink struct{ max int }
+
+func (noSink) Read() (int, error)    { return 0, fmt.Errorf("no panel") }
+func (noSink) Write(value int) error { return nil }
+func (s noSink) Max() int {
+	if s.max > 0 {
+		return s.max
+	}
+	return -1
+}
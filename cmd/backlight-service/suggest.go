@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// runSuggest implements `suggest`: it reads the lux histogram the service
+// accumulates in -lux-histogram-key and prints it with a curve that keeps
+// the brightness of each -curve point but spreads the points over the lux
+// actually seen. Nothing is applied; the output is for review.
+func runSuggest(args []string) int {
+	cfg := config.New()
+	if err := cfg.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "suggest: %v\n", err)
+		return 1
+	}
+	if cfg.LuxHistogramKey == "" {
+		fmt.Fprintf(os.Stderr, "suggest: need -lux-histogram-key\n")
+		return 2
+	}
+	units, err := service.UnitsFor(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "suggest: %v\n", err)
+		return 1
+	}
+	curve, err := backlight.ParseCurveIn(cfg.Curve, units)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "suggest: invalid curve: %v\n", err)
+		return 1
+	}
+
+	redis, err := connectRedis(cfg.RedisURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "suggest: %v\n", err)
+		return 1
+	}
+	defer redis.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+	defer cancel()
+	fields, err := redis.GetLuxHistogram(ctx, cfg.LuxHistogramKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "suggest: failed to read %s: %v\n", cfg.LuxHistogramKey, err)
+		return 1
+	}
+	h, err := backlight.ParseLuxHistogram(fields)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "suggest: %v\n", err)
+		return 1
+	}
+	total := h.Total()
+	if total <= 0 {
+		fmt.Fprintf(os.Stderr, "suggest: %s is empty; ride with the service running first\n", cfg.LuxHistogramKey)
+		return 1
+	}
+
+	fmt.Printf("%.1f hours of riding\n\n", total/3600)
+	fmt.Printf("%10s %8s %7s\n", "from lux", "hours", "below")
+	cumulative := 0.0
+	for _, b := range h.Buckets() {
+		cumulative += h[b]
+		fmt.Printf("%10s %8.2f %6.1f%%\n", backlight.HistogramField(b), h[b]/3600, cumulative/total*100)
+	}
+
+	points, err := backlight.SuggestCurve(curve, h, cfg.LogLux)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "suggest: %v\n", err)
+		return 1
+	}
+	fmt.Printf("\ncurrent   curve = \"%s\"\n", backlight.FormatCurve(curve))
+	fmt.Printf("suggested curve = \"%s\"\n", backlight.FormatCurve(points))
+	return 0
+}
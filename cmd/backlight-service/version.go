@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+)
+
+// Set with -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=...".
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildInfo returns the injected build information, falling back to the
+// VCS stamp the Go toolchain embeds when building from a checkout.
+func buildInfo() service.BuildInfo {
+	b := service.BuildInfo{Version: version, Commit: commit, Date: buildDate}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && b.Commit == "":
+				b.Commit = s.Value
+				if len(b.Commit) > 12 {
+					b.Commit = b.Commit[:12]
+				}
+			case s.Key == "vcs.time" && b.Date == "":
+				b.Date = s.Value
+			}
+		}
+	}
+	return b
+}
+
+func runVersion(args []string) int {
+	fmt.Printf("dbc-backlight %s\n", buildInfo())
+	return 0
+}
@@ -2,10 +2,18 @@ module github.com/librescoot/dbc-backlight-service
 
 go 1.22.2
 
-require github.com/redis/go-redis/v9 v9.18.0
+require (
+	github.com/eclipse/paho.mqtt.golang v1.4.3
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/redis/go-redis/v9 v9.18.0
+	golang.org/x/sys v0.25.0
+)
 
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/gorilla/websocket v1.5.0 // indirect
 	go.uber.org/atomic v1.11.0 // indirect
+	golang.org/x/net v0.8.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
 )
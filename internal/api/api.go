@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+)
+
+// defaultOverrideDuration applies when an override request names no
+// duration, so a forgotten debug override can't pin the display forever.
+const defaultOverrideDuration = 5 * time.Minute
+
+// Server is the localhost HTTP status and control API.
+type Server struct {
+	svc    *service.Service
+	logger *log.Logger
+	mux    *http.ServeMux
+}
+
+func New(svc *service.Service, logger *log.Logger) *Server {
+	s := &Server{svc: svc, logger: logger, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.HandleFunc("/level", s.handleLevel)
+	s.mux.HandleFunc("/override", s.handleOverride)
+	s.mux.HandleFunc("/config", s.handleConfig)
+	return s
+}
+
+// Handler returns the API's HTTP handler.
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// ListenAndServe serves the API on addr until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	srv := &http.Server{Handler: s.mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	s.logger.Printf("HTTP API listening on %s", ln.Addr())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	st, err := s.svc.Status(r.Context())
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+type levelResponse struct {
+	Mode       string `json:"mode"`
+	Brightness int    `json:"brightness"`
+	Target     int    `json:"target"`
+	Override   bool   `json:"override"`
+}
+
+type levelRequest struct {
+	Level    string  `json:"level"`
+	Duration *string `json:"duration,omitempty"`
+}
+
+// handleLevel reports the current level (GET) or temporarily overrides the
+// brightness with one of the named manual levels (POST).
+func (s *Server) handleLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		st, err := s.svc.Status(r.Context())
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, levelResponse{
+			Mode:       st.Mode,
+			Brightness: st.Output,
+			Target:     st.Target,
+			Override:   st.Override != nil,
+		})
+	case http.MethodPost, http.MethodPut:
+		var req levelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		d, err := parseDuration(req.Duration)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.svc.SetOverrideLevel(r.Context(), req.Level, d); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+type overrideRequest struct {
+	Brightness int     `json:"brightness"`
+	Duration   *string `json:"duration,omitempty"`
+}
+
+// handleOverride sets (POST) or clears (DELETE) a raw brightness override.
+func (s *Server) handleOverride(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		st, err := s.svc.Status(r.Context())
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, st.Override)
+	case http.MethodPost, http.MethodPut:
+		var req overrideRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		d, err := parseDuration(req.Duration)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.svc.SetOverride(r.Context(), req.Brightness, d); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := s.svc.ClearOverride(r.Context()); err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.svc.Config.Values())
+}
+
+// parseDuration parses an optional override duration; absent means the
+// default, "0s" means until cleared.
+func parseDuration(s *string) (time.Duration, error) {
+	if s == nil {
+		return defaultOverrideDuration, nil
+	}
+	d, err := time.ParseDuration(*s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %v", *s, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration must not be negative")
+	}
+	return d, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
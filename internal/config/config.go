@@ -1,38 +1,566 @@
 package config
 
 import (
+	"bufio"
 	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	RedisURL         string
-	PollingTime      time.Duration
-	SysBacklightPath string
-	SensorPath       string
-	Curve            string
-	ManualLevels     string
-	RampRate         float64
-	LuxAlpha         float64
-	Debug            bool
+	ConfigFile          string
+	RedisURL            string
+	RedisReadTimeout    time.Duration
+	RedisWriteTimeout   time.Duration
+	RedisUsername       string
+	RedisPassword       string
+	RedisPasswordFile   string
+	RedisDB             int
+	RedisTLSCert        string
+	RedisTLSKey         string
+	RedisTLSCA          string
+	PollingTime         time.Duration
+	KeyspaceNotify      bool
+	HeartbeatInterval   time.Duration
+	LuxCache            bool
+	MinPollingTime      time.Duration
+	MaxPollingTime      time.Duration
+	ParkedPolling       time.Duration
+	PauseWhenParked     bool
+	ParkedStates        string
+	IdleDim             time.Duration
+	IdleStep            time.Duration
+	IdleOff             bool
+	ChargingKey         string
+	ChargingField       string
+	ChargingValues      string
+	InteractionBoost    time.Duration
+	InteractionLevel    string
+	SleepStates         string
+	HibernateStates     string
+	BlinkerMask         bool
+	SpeedReference      float64
+	SpeedMinFactor      float64
+	SpeedMaxFactor      float64
+	HeadlightKey        string
+	HeadlightField      string
+	HeadlightOnValues   string
+	HeadlightLuxScale   float64
+	HeadlightLuxOffset  float64
+	BlinkerHoldoff      time.Duration
+	AlarmKey            string
+	AlarmField          string
+	AlarmValues         string
+	AlarmLow            int
+	AlarmHigh           int
+	AlarmPeriod         time.Duration
+	Sink                string
+	SysBacklightPath    string
+	MirrorPath          string
+	PWMChip             string
+	PWMChannel          int
+	PWMPeriod           time.Duration
+	PWMSteps            int
+	I2CBus              string
+	I2CAddress          uint
+	I2CRegister         uint
+	I2CSize             int
+	I2CBigEndian        bool
+	I2CMax              int
+	MaxBrightness       int
+	AllowMissingHW      bool
+	FBBlank             string
+	InvertBrightness    bool
+	Quirks              bool
+	QuirksFile          string
+	ModelPath           string
+	Model               string // board model string, if it could be read
+	Quirk               string // name of the applied quirk, if any
+	DeviceWait          time.Duration
+	LockFile            string
+	LockWait            time.Duration
+	MinWriteInterval    time.Duration
+	VerifyWrites        bool
+	SensorPath          string
+	SensorSamples       int
+	SensorInterval      time.Duration
+	SensorPath2         string
+	SensorFusion        string
+	FusionWeight        float64
+	FusionMaxRatio      float64
+	CANInterface        string
+	CANID               uint
+	CANOffset           int
+	CANSize             int
+	CANBigEndian        bool
+	CANScale            float64
+	CANMaxAge           time.Duration
+	FollowLEDs          string
+	LEDsDir             string
+	Curve               string
+	ManualLevels        string
+	NitsTable           string
+	LevelGamma          float64
+	LevelMin            int
+	LevelMax            int
+	StepUpButton        string
+	StepDownButton      string
+	ButtonHold          time.Duration
+	StepTimeout         time.Duration
+	BiasStep            int
+	BiasLimit           int
+	RampRate            float64
+	LuxAlpha            float64
+	LuxFilter           string
+	ProcessNoise        float64
+	MeasurementNoise    float64
+	MinConfidence       float64
+	LuxScale            float64
+	LuxOffset           float64
+	MaxSlew             float64
+	LuxHysteresis       float64
+	JumpFactor          float64
+	TunnelFactor        float64
+	FlickerRatio        float64
+	TunnelWindow        time.Duration
+	LogLux              bool
+	StartupPolicy       string
+	StartupFade         time.Duration
+	StartupFadeTimeout  time.Duration
+	LevelFile           string
+	TransitionFades     string
+	WaitForUI           string
+	WaitForUITimeout    time.Duration
+	BurnInLevel         int
+	BurnInAfter         time.Duration
+	BurnInReduce        float64
+	BurnInNudge         time.Duration
+	ShutdownPolicy      string
+	StatsInterval       time.Duration
+	LuxHistogramKey     string
+	LearnRate           float64
+	LearnLimit          int
+	StatusJSONKey       string
+	AliveKey            string
+	AliveTTL            time.Duration
+	ThemeLevel          string
+	ThemeHysteresis     int
+	HookCommand         string
+	HookLevels          string
+	HookTimeout         time.Duration
+	CommandStream       string
+	UIOverride          time.Duration
+	CommandGroup        string
+	StatusJSONInterval  time.Duration
+	FaultTimeout        time.Duration
+	RedisGiveUp         time.Duration
+	LuxMaxAge           time.Duration
+	StuckTimeout        time.Duration
+	StaleTimeout        time.Duration
+	FallbackSchedule    string
+	SettingsKey         string
+	Probation           time.Duration
+	ProbationFlips      int
+	OscillationRate     int
+	OscillationAction   string
+	SensorSources       string
+	SensorRetry         time.Duration
+	FaultStream         string
+	RideStream          string
+	MQTTBroker          string
+	MQTTClientID        string
+	MQTTUsername        string
+	MQTTPassword        string
+	MQTTTopicPrefix     string
+	MQTTLuxTopic        string
+	MQTTLuxMaxAge       time.Duration
+	MQTTDiscoveryPrefix string
+	DebugAddr           string
+	HTTPAddr            string
+	DBusBus             string
+	Debug               bool
+	LogJournal          bool
+	LogFile             string
+	LogMaxSize          int64
+	LogMaxAge           time.Duration
+	LogBackups          int
+
+	origins map[string]string // where flags not left at their default were set
 }
 
 func New() *Config {
 	cfg := &Config{}
 
+	flag.StringVar(&cfg.ConfigFile, "config", "", "Config file of name = value lines using the flag names; flags override it")
 	flag.StringVar(&cfg.RedisURL, "redis-url", "redis://192.168.7.1:6379", "Redis URL")
+	flag.DurationVar(&cfg.RedisReadTimeout, "redis-read-timeout", 500*time.Millisecond, "Deadline for each Redis read")
+	flag.DurationVar(&cfg.RedisWriteTimeout, "redis-write-timeout", 500*time.Millisecond, "Deadline for each Redis write")
+	flag.StringVar(&cfg.RedisUsername, "redis-username", "", "Redis ACL username (default: from the URL or $REDIS_USERNAME)")
+	flag.StringVar(&cfg.RedisPassword, "redis-password", "", "Redis password; prefer -redis-password-file, $REDIS_PASSWORD or the redis-password systemd credential")
+	flag.StringVar(&cfg.RedisPasswordFile, "redis-password-file", "", "File containing the Redis password")
+	flag.IntVar(&cfg.RedisDB, "redis-db", -1, "Redis database index; -1 uses the URL's")
+	flag.StringVar(&cfg.RedisTLSCert, "redis-tls-cert", "", "TLS client certificate for Redis (enables TLS)")
+	flag.StringVar(&cfg.RedisTLSKey, "redis-tls-key", "", "TLS client key for Redis")
+	flag.StringVar(&cfg.RedisTLSCA, "redis-tls-ca", "", "CA bundle to verify the Redis server (enables TLS)")
 	flag.DurationVar(&cfg.PollingTime, "polling-time", 50*time.Millisecond, "Polling interval for illuminance sensor")
+	flag.BoolVar(&cfg.KeyspaceNotify, "keyspace-notify", false, "Adjust on Redis keyspace notifications for the dashboard hash; polling becomes a heartbeat")
+	flag.BoolVar(&cfg.LuxCache, "lux-cache", false, "Cache the illuminance field with Redis client tracking; polls only hit Redis after it changes")
+	flag.DurationVar(&cfg.HeartbeatInterval, "heartbeat-interval", 5*time.Second, "Polling interval once settled with -keyspace-notify, and the vehicle state check while -pause-when-parked holds polling (0 = none)")
+	flag.DurationVar(&cfg.MinPollingTime, "min-polling-time", 0, "Fastest adaptive polling interval while lux is changing (0 = polling-time)")
+	flag.DurationVar(&cfg.MaxPollingTime, "max-polling-time", 0, "Slowest adaptive polling interval while lux is stable (0 = adaptive polling disabled)")
+	flag.DurationVar(&cfg.ParkedPolling, "parked-polling-time", 0, "Polling interval while the vehicle is parked or in stand-by (0 = unchanged)")
+	flag.BoolVar(&cfg.PauseWhenParked, "pause-when-parked", false, "Stop polling lux while the vehicle is parked or in stand-by; the vehicle state is still checked every -heartbeat-interval")
+	flag.StringVar(&cfg.ParkedStates, "parked-states", "parked stand-by", "Vehicle states that count as parked for polling purposes")
+	flag.DurationVar(&cfg.IdleDim, "idle-dim", 0, "Parked without interaction for this long, step the backlight down one manual level at a time (0 = off)")
+	flag.DurationVar(&cfg.IdleStep, "idle-step", 30*time.Second, "Time between -idle-dim steps")
+	flag.BoolVar(&cfg.IdleOff, "idle-off", true, "Turn the backlight off after the lowest manual level when idle")
+	flag.StringVar(&cfg.ChargingKey, "charging-key", "cb-battery", "Redis hash holding the charging state; idle dimming is suspended while charging (empty = never charging)")
+	flag.StringVar(&cfg.ChargingField, "charging-field", "charge-status", "Field of -charging-key holding the charging state")
+	flag.StringVar(&cfg.ChargingValues, "charging-values", "charging", "Space-separated -charging-field values meaning the scooter is charging")
+	flag.DurationVar(&cfg.InteractionBoost, "interaction-boost", 0, "Parked with the backlight at the lowest level or off, raise it to -interaction-level for this long on a button press, turn signal or kickstand change (0 = off)")
+	flag.StringVar(&cfg.InteractionLevel, "interaction-level", "medium", "Manual level that -interaction-boost raises the backlight to")
+	flag.StringVar(&cfg.HeadlightKey, "headlight-key", "", "Redis hash holding the headlight state; empty disables headlight coupling")
+	flag.StringVar(&cfg.HeadlightField, "headlight-field", "headlight", "Field of -headlight-key holding the headlight state")
+	flag.StringVar(&cfg.HeadlightOnValues, "headlight-on-values", "on", "Space-separated -headlight-field values meaning the headlight is on")
+	flag.Float64Var(&cfg.HeadlightLuxScale, "headlight-lux-scale", 0.7, "Lux multiplier while the headlight is on, favouring lower levels")
+	flag.Float64Var(&cfg.HeadlightLuxOffset, "headlight-lux-offset", 0, "Lux the headlight adds to the sensor, subtracted after -headlight-lux-scale while it is on")
+	flag.Float64Var(&cfg.SpeedReference, "speed-reference", 0, "Speed (km/h) at which the lux filter and ramp run as configured; faster speeds them up, slower smooths more. 0 disables")
+	flag.Float64Var(&cfg.SpeedMinFactor, "speed-min-factor", 0.5, "Slowest responsiveness factor, used when stopped")
+	flag.Float64Var(&cfg.SpeedMaxFactor, "speed-max-factor", 3, "Fastest responsiveness factor")
+	flag.BoolVar(&cfg.BlinkerMask, "blinker-mask", false, "Ignore lux rises while the turn indicators flash")
+	flag.DurationVar(&cfg.BlinkerHoldoff, "blinker-holdoff", 500*time.Millisecond, "Keep masking lux rises this long after the indicators stop")
+	flag.StringVar(&cfg.AlarmKey, "alarm-key", "", "Redis hash holding the alarm flag, e.g. alarm; empty disables the attention pulse")
+	flag.StringVar(&cfg.AlarmField, "alarm-field", "status", "Field of -alarm-key holding the alarm flag")
+	flag.StringVar(&cfg.AlarmValues, "alarm-values", "triggered", "Space-separated -alarm-field values that start the attention pulse")
+	flag.IntVar(&cfg.AlarmLow, "alarm-low", 0, "Low brightness of the attention pulse")
+	flag.IntVar(&cfg.AlarmHigh, "alarm-high", 0, "High brightness of the attention pulse (0 = highest configured brightness)")
+	flag.DurationVar(&cfg.AlarmPeriod, "alarm-period", time.Second, "Duration of one low-high cycle of the attention pulse")
+	flag.StringVar(&cfg.HibernateStates, "hibernate-states", "hibernating", "Power manager states in which the backlight is turned off and Redis disconnected until wake-up")
+	flag.StringVar(&cfg.SleepStates, "sleep-states", "suspending suspending-imminent hibernating hibernating-imminent", "Power manager states during which polling is paused")
+	flag.StringVar(&cfg.Sink, "sink", "sysfs", "Brightness output: sysfs (-backlight-path), pwm (-pwm-chip) or i2c (-i2c-bus)")
 	flag.StringVar(&cfg.SysBacklightPath, "backlight-path", "/sys/class/backlight/backlight/brightness", "Path to backlight brightness file")
+	flag.StringVar(&cfg.MirrorPath, "mirror-path", "", "Second brightness file or FIFO receiving a copy of every write, e.g. a mirrored passenger display (empty = none)")
+	flag.StringVar(&cfg.PWMChip, "pwm-chip", "/sys/class/pwm/pwmchip0", "PWM chip directory for -sink=pwm")
+	flag.IntVar(&cfg.PWMChannel, "pwm-channel", 0, "PWM channel of -pwm-chip driving the backlight")
+	flag.DurationVar(&cfg.PWMPeriod, "pwm-period", 50*time.Microsecond, "PWM period for -sink=pwm")
+	flag.StringVar(&cfg.I2CBus, "i2c-bus", "/dev/i2c-1", "I2C bus device for -sink=i2c")
+	flag.UintVar(&cfg.I2CAddress, "i2c-address", 0x2c, "7-bit address of the I2C LED driver")
+	flag.UintVar(&cfg.I2CRegister, "i2c-register", 0, "Brightness register of the I2C LED driver")
+	flag.IntVar(&cfg.I2CSize, "i2c-size", 1, "Width of the I2C brightness register in bytes (1 or 2)")
+	flag.BoolVar(&cfg.I2CBigEndian, "i2c-big-endian", false, "Write 2 byte I2C brightness values most significant byte first")
+	flag.IntVar(&cfg.I2CMax, "i2c-max", 255, "I2C brightness register value at full brightness")
+	flag.IntVar(&cfg.PWMSteps, "pwm-steps", 10240, "Brightness value that maps to a 100% duty cycle with -sink=pwm")
+	flag.IntVar(&cfg.MaxBrightness, "max-brightness", 0, "Highest raw brightness the panel accepts (0 = sysfs max_brightness)")
+	flag.BoolVar(&cfg.AllowMissingHW, "allow-missing-hardware", false, "Drive an in-memory sink when the backlight device is missing, for development and CI")
+	flag.StringVar(&cfg.FBBlank, "fb-blank", "", "Framebuffer blank attribute (e.g. /sys/class/graphics/fb0/blank) to power the display down whenever the backlight is turned off; empty disables")
+	flag.BoolVar(&cfg.InvertBrightness, "invert-brightness", false, "The panel is brightest at 0 and darkest at max-brightness")
+	flag.BoolVar(&cfg.Quirks, "quirks", true, "Apply per-board defaults matched against the board model string")
+	flag.StringVar(&cfg.QuirksFile, "quirks-file", "", "Additional board quirks, tried before the built-in ones; [model] sections of name = value lines")
+	flag.StringVar(&cfg.ModelPath, "model-path", "", "File holding the board model string (default: /proc/device-tree/model, then DMI)")
+	flag.StringVar(&cfg.LockFile, "lock-file", "/run/dbc-backlight.lock", "Lock file guarding against a second instance; empty disables")
+	flag.DurationVar(&cfg.LockWait, "lock-wait", 10*time.Second, "How long to wait for another instance to release the lock")
+	flag.DurationVar(&cfg.DeviceWait, "device-wait", 30*time.Second, "How long to wait at startup for the backlight device to appear; 0 disables")
+	flag.DurationVar(&cfg.MinWriteInterval, "min-write-interval", 0, "Minimum time between brightness writes; bursts are coalesced into the latest value. 0 disables")
+	flag.BoolVar(&cfg.VerifyWrites, "verify-writes", false, "Read brightness back after each write and report the value the driver actually applied")
 	flag.StringVar(&cfg.SensorPath, "sensor-path", "", "Path to IIO illuminance input (e.g. /sys/bus/iio/devices/iio:device0/in_illuminance_input). If empty, reads from Redis.")
+	flag.IntVar(&cfg.SensorSamples, "sensor-samples", 1, "Readings of -sensor-path per poll; their median is used, to reject flicker from pulsed lighting")
+	flag.DurationVar(&cfg.SensorInterval, "sensor-sample-interval", 5*time.Millisecond, "Spacing of the -sensor-samples readings")
+	flag.StringVar(&cfg.SensorPath2, "sensor-path2", "", "Second IIO illuminance input, fused with -sensor-path by -sensor-fusion")
+	flag.StringVar(&cfg.SensorFusion, "sensor-fusion", "", "Combine -sensor-path and -sensor-path2: max, average or reject; empty uses -sensor-path alone")
+	flag.Float64Var(&cfg.FusionWeight, "fusion-weight", 0.5, "Weight of -sensor-path in -sensor-fusion average (0..1)")
+	flag.Float64Var(&cfg.FusionMaxRatio, "fusion-max-ratio", 3, "Lux ratio beyond which -sensor-fusion reject treats the two sensors as disagreeing")
+	flag.StringVar(&cfg.CANInterface, "can-interface", "", "SocketCAN interface carrying the lux value (e.g. can0); empty disables")
+	flag.UintVar(&cfg.CANID, "can-id", 0, "CAN frame ID carrying the lux value (e.g. 0x3A1)")
+	flag.IntVar(&cfg.CANOffset, "can-offset", 0, "Byte offset of the lux value in the CAN frame")
+	flag.IntVar(&cfg.CANSize, "can-size", 2, "Size of the lux value in bytes (1, 2 or 4, unsigned)")
+	flag.BoolVar(&cfg.CANBigEndian, "can-big-endian", false, "Decode the CAN lux value as big-endian")
+	flag.Float64Var(&cfg.CANScale, "can-scale", 1, "Lux per raw CAN unit")
+	flag.DurationVar(&cfg.CANMaxAge, "can-max-age", 5*time.Second, "Treat the CAN lux reading as failed once it is older than this")
+	flag.StringVar(&cfg.FollowLEDs, "follow-leds", "", "LED class devices that follow the display brightness, as name:scale pairs (e.g. \"button-backlight:1 handlebar:0.5\")")
+	flag.StringVar(&cfg.LEDsDir, "leds-dir", "/sys/class/leds", "Directory containing LED class devices")
 	flag.StringVar(&cfg.Curve, "curve", "0:400 0.5:1300 1:2200 2:2900 5:4000 10:5200 20:7000 35:8600 50:9600 80:10240", "Lux-to-brightness curve as lux:brightness pairs")
-	flag.StringVar(&cfg.ManualLevels, "manual-levels", "low:1300 medium:4000 high:10240", "Manual backlight levels as name:brightness pairs")
+	flag.Float64Var(&cfg.LevelGamma, "level-gamma", 0, "Respace -manual-levels to look even for this display gamma (e.g. 2.2), keeping their order; 0 uses them as given")
+	flag.IntVar(&cfg.LevelMin, "level-min", 0, "Dimmest level brightness with -level-gamma (0 = lowest configured level)")
+	flag.IntVar(&cfg.LevelMax, "level-max", 0, "Brightest level brightness with -level-gamma (0 = highest configured level)")
+	flag.StringVar(&cfg.ManualLevels, "manual-levels", "low:1300 medium:4000 high:10240", "Manual backlight levels as name:brightness pairs; brightness is raw, a percentage (40%) or nits (250nits)")
+	flag.StringVar(&cfg.NitsTable, "nits-table", "", "Panel luminance calibration as raw:nits pairs (e.g. \"0:0 1300:40 10240:300\"), letting brightness values be given in nits")
+	flag.StringVar(&cfg.StepUpButton, "step-up-button", "", "Button or combo (e.g. \"brake:left+blinker:right\") whose long press steps the level up; disabled if empty")
+	flag.StringVar(&cfg.StepDownButton, "step-down-button", "", "Button or combo whose long press steps the level down; disabled if empty")
+	flag.DurationVar(&cfg.ButtonHold, "button-hold", time.Second, "How long a step button combo must be held")
+	flag.DurationVar(&cfg.StepTimeout, "step-timeout", time.Minute, "How long a button step overrides the automatic level")
+	flag.IntVar(&cfg.BiasStep, "bias-step", 500, "Brightness change per bias-up/bias-down command")
+	flag.IntVar(&cfg.BiasLimit, "bias-limit", 2500, "Maximum user brightness bias in either direction")
 	flag.Float64Var(&cfg.RampRate, "ramp-rate", 0.05, "Fraction of remaining distance to move per tick (0..1)")
+	flag.Float64Var(&cfg.MaxSlew, "max-slew", 0, "Maximum brightness change per second (0 = unlimited)")
+	flag.Float64Var(&cfg.LuxHysteresis, "lux-hysteresis", 0, "Relative lux change (fraction, e.g. 0.1 = 10%) required before the target follows the curve again; 0 disables")
+	flag.Float64Var(&cfg.TunnelFactor, "tunnel-factor", 0, "Lux drop ratio (e.g. 8 = to an eighth) that, sustained for -tunnel-window, jumps straight to the darker brightness (0 = off)")
+	flag.DurationVar(&cfg.TunnelWindow, "tunnel-window", time.Second, "How long a -tunnel-factor drop must last")
+	flag.Float64Var(&cfg.FlickerRatio, "flicker-ratio", 0, "High/low ratio of alternating lux readings treated as pulsed street lighting, holding at the brighter reading, e.g. 2 (0 = off)")
+	flag.Float64Var(&cfg.JumpFactor, "jump-factor", 0, "Lux ratio between a reading and the smoothed lux (e.g. 10) that jumps straight to the new brightness; 0 disables")
+	flag.BoolVar(&cfg.LogLux, "log-lux", false, "Treat curve lux values as log10(lux) decades and smooth in the log domain")
 	flag.Float64Var(&cfg.LuxAlpha, "lux-alpha", 0.1, "EMA smoothing factor for lux input (0..1); lower is slower/less flickery")
+	flag.StringVar(&cfg.LuxFilter, "lux-filter", "ema", "Lux estimator: ema or kalman")
+	flag.Float64Var(&cfg.ProcessNoise, "process-noise", 0.01, "Kalman process noise variance, in curve units")
+	flag.Float64Var(&cfg.MeasurementNoise, "measurement-noise", 1, "Sensor noise variance in curve units; scales the estimator confidence")
+	flag.Float64Var(&cfg.MinConfidence, "min-confidence", 0, "Hold the target while the estimator confidence (0..1) is below this; 0 disables")
+	flag.StringVar(&cfg.StartupPolicy, "startup-policy", "first-reading", "Brightness before the first lux sample: keep, mid, restore or first-reading")
+	flag.DurationVar(&cfg.StartupFade, "startup-fade", 0, "Start dark and fade up to the target over this long once the dashboard sets ready, instead of -startup-policy (0 = off)")
+	flag.DurationVar(&cfg.StartupFadeTimeout, "startup-fade-timeout", 10*time.Second, "Fade in anyway if the dashboard hasn't set ready after this long (0 = wait indefinitely)")
+	flag.StringVar(&cfg.LevelFile, "level-file", "", "File the brightness is saved to on exit and written from at the next start, before Redis, when no -sensor-path is set; empty disables")
+	flag.StringVar(&cfg.TransitionFades, "transition-fades", "", "Fades for target changes between manual levels as from>to:duration[:easing] (linear, ease-in-out or exponential), e.g. \"high>medium:2s:ease-in-out low>*:100ms\"; from and to may be off or *")
+	flag.StringVar(&cfg.WaitForUI, "wait-for-ui", "", "Hold back every backlight write until the UI sets this dashboard field to true, e.g. first-frame (empty = don't wait)")
+	flag.DurationVar(&cfg.WaitForUITimeout, "wait-for-ui-timeout", 15*time.Second, "Light the panel anyway if -wait-for-ui isn't set after this long (0 = wait indefinitely)")
+	flag.IntVar(&cfg.BurnInLevel, "burnin-level", 0, "OLED burn-in protection: brightness at or above which a static level is reduced and nudged (0 = off)")
+	flag.DurationVar(&cfg.BurnInAfter, "burnin-after", 30*time.Minute, "How long a brightness at or above -burnin-level may stay unchanged before it is reduced")
+	flag.Float64Var(&cfg.BurnInReduce, "burnin-reduce", 0.05, "Fraction taken off a static brightness once -burnin-after has passed")
+	flag.DurationVar(&cfg.BurnInNudge, "burnin-nudge", time.Minute, "Interval between alternating adjacent raw values at or above -burnin-level (0 = don't nudge)")
+	flag.StringVar(&cfg.ShutdownPolicy, "shutdown-brightness", "keep", "Backlight on exit: keep, restore-initial, or a brightness in any unit, e.g. 1300 or 10%")
+	flag.DurationVar(&cfg.UIOverride, "ui-override", 0, "Keep a dashboard backlight value written by another client as an override for this long, e.g. 1m (0 = overwrite it)")
+	flag.StringVar(&cfg.CommandStream, "command-stream", "backlight:commands", "Redis stream to consume control commands from; empty disables")
+	flag.StringVar(&cfg.CommandGroup, "command-group", "backlight-service", "Consumer group for -command-stream")
+	flag.StringVar(&cfg.ThemeLevel, "theme-level", "", "Publish dashboard theme=dark at or below this level name or brightness, light above it; empty disables")
+	flag.IntVar(&cfg.ThemeHysteresis, "theme-hysteresis", 500, "Brightness above -theme-level needed before switching back to the light theme")
+	flag.StringVar(&cfg.HookCommand, "hook-command", "", "Shell command run on every level transition, with BACKLIGHT_LEVEL, BACKLIGHT_PREVIOUS_LEVEL, BACKLIGHT_MODE, BACKLIGHT_LUX and BACKLIGHT_BRIGHTNESS set; empty disables")
+	flag.StringVar(&cfg.HookLevels, "hook-levels", "", "Only run -hook-command on entering these space-separated levels; empty means every transition")
+	flag.DurationVar(&cfg.HookTimeout, "hook-timeout", 10*time.Second, "Kill -hook-command after this long")
+	flag.StringVar(&cfg.StatusJSONKey, "status-json-key", "backlight:json", "Redis key and channel for the periodic JSON status")
+	flag.DurationVar(&cfg.StatusJSONInterval, "status-json-interval", 0, "How often to publish the JSON status; 0 disables")
+	flag.StringVar(&cfg.AliveKey, "alive-key", "backlight:alive", "Redis key the control loop refreshes with a TTL, so supervisors can spot a hung service by its expiry; empty disables")
+	flag.DurationVar(&cfg.AliveTTL, "alive-ttl", 15*time.Second, "TTL of -alive-key, refreshed every third of it")
+	flag.DurationVar(&cfg.StatsInterval, "stats-interval", time.Minute, "How often to write counters to the backlight:stats hash; 0 disables")
+	flag.StringVar(&cfg.LuxHistogramKey, "lux-histogram-key", "backlight:lux-histogram", "Redis hash accumulating riding time at each lux, written every -stats-interval, for curve suggestions (empty = off)")
+	flag.Float64Var(&cfg.LearnRate, "learn-rate", 0, "Share (0..1) of the way each manual correction in auto mode moves the curve towards the picked brightness at that lux (0 = don't learn)")
+	flag.IntVar(&cfg.LearnLimit, "learn-limit", 1500, "Largest learned change to any curve point's brightness, either way (0 = unbounded)")
+	flag.DurationVar(&cfg.LuxMaxAge, "lux-max-age", 0, "Reject Redis lux readings whose dashboard brightness:timestamp is older than this, for illumination services that write it; 0 disables")
+	flag.DurationVar(&cfg.StuckTimeout, "stuck-timeout", 0, "Treat the sensor as stuck when its reading stays bit-identical this long while riding (speed > 0); 0 disables")
+	flag.StringVar(&cfg.SettingsKey, "settings-key", "", "Redis hash whose curve and manual-levels fields override the flags, watched for changes (e.g. settings:backlight); empty disables")
+	flag.DurationVar(&cfg.Probation, "settings-probation", 2*time.Minute, "Treat settings from -settings-key as provisional this long, rolling back on a failed write or oscillation; 0 applies them outright")
+	flag.IntVar(&cfg.ProbationFlips, "settings-max-transitions", 20, "Reversals of the brightness direction during -settings-probation that count as oscillation (0 = don't check)")
+	flag.IntVar(&cfg.OscillationRate, "oscillation-rate", 0, "Reversals of the brightness direction per minute above which the control loop is damped, e.g. 12 (0 = don't check)")
+	flag.StringVar(&cfg.OscillationAction, "oscillation-action", "widen", "How to damp oscillation: widen (4x deadband) or freeze (hold the current target)")
+	flag.StringVar(&cfg.FallbackSchedule, "fallback-schedule", "", "Brightness by local hour of day as hour:brightness pairs in any brightness unit, e.g. \"0:20% 6:50% 8:95% 18:50% 21:20%\", used while the sensor is missing, stale or stuck; empty holds the last brightness")
+	flag.StringVar(&cfg.SensorSources, "sensor-sources", "", "Space-separated lux sources in order of preference, from redis, iio (-sensor-path), can (-can-interface), mqtt (-mqtt-lux-topic) and a final schedule; empty uses the single configured source")
+	flag.DurationVar(&cfg.SensorRetry, "sensor-retry", 10*time.Second, "How long a failed -sensor-sources entry is skipped before it is tried again")
+	flag.DurationVar(&cfg.FaultTimeout, "fault-timeout", 10*time.Second, "How long sensor reads or Redis calls may fail before a fault is raised")
+	flag.DurationVar(&cfg.StaleTimeout, "stale-timeout", 10*time.Minute, "Raise the stale-sensor fault when a nonzero lux reading stays exactly the same this long, as when the illumination service has died; 0 disables")
+	flag.DurationVar(&cfg.RedisGiveUp, "redis-give-up", 0, "Exit with status 69 once Redis calls have failed this long, for supervisors that restart or fail over; 0 keeps retrying")
+	flag.StringVar(&cfg.FaultStream, "fault-stream", "events:faults", "Redis stream that fault changes are appended to")
+	flag.StringVar(&cfg.RideStream, "ride-stream", "events:backlight-rides", "Redis stream a summary of each ride (lux range, transitions, time per level, errors) is appended to on parking; empty disables")
+	flag.StringVar(&cfg.MQTTBroker, "mqtt-broker", "", "MQTT broker URL (e.g. tcp://192.168.1.10:1883); empty disables MQTT")
+	flag.StringVar(&cfg.MQTTClientID, "mqtt-client-id", "dbc-backlight", "MQTT client ID")
+	flag.StringVar(&cfg.MQTTUsername, "mqtt-username", "", "MQTT username")
+	flag.StringVar(&cfg.MQTTPassword, "mqtt-password", "", "MQTT password")
+	flag.StringVar(&cfg.MQTTTopicPrefix, "mqtt-topic-prefix", "librescoot/backlight", "Prefix for published MQTT topics")
+	flag.StringVar(&cfg.MQTTLuxTopic, "mqtt-lux-topic", "", "MQTT topic to read lux from instead of Redis or -sensor-path; empty disables")
+	flag.DurationVar(&cfg.MQTTLuxMaxAge, "mqtt-lux-max-age", time.Minute, "Treat the MQTT lux reading as failed once it is older than this")
+	flag.StringVar(&cfg.MQTTDiscoveryPrefix, "mqtt-discovery-prefix", "", "Publish Home Assistant discovery under this prefix (usually homeassistant); empty disables")
+	flag.StringVar(&cfg.DebugAddr, "debug-addr", "", "Serve pprof and a /state dump on this address (e.g. 127.0.0.1:6060); empty disables")
+	flag.StringVar(&cfg.HTTPAddr, "http-addr", "", "Listen address for the HTTP status and control API (e.g. 127.0.0.1:8765); disabled if empty")
+	flag.StringVar(&cfg.DBusBus, "dbus", "", "Export org.librescoot.Backlight1 on the given D-Bus bus (system or session); disabled if empty")
+	flag.Float64Var(&cfg.LuxScale, "lux-scale", 1, "Calibration factor applied to every raw lux reading")
+	flag.Float64Var(&cfg.LuxOffset, "lux-offset", 0, "Calibration offset added to every raw lux reading after scaling")
 	flag.BoolVar(&cfg.Debug, "debug", false, "Enable debug logging")
+	flag.BoolVar(&cfg.LogJournal, "log-journal", true, "Under systemd, log to the journal with per-message priorities instead of stdout")
+	flag.StringVar(&cfg.LogFile, "log-file", "", "Append the log to this file instead of stdout, for runs outside systemd; empty disables")
+	flag.Int64Var(&cfg.LogMaxSize, "log-max-size", 10<<20, "Rotate -log-file once it exceeds this many bytes (0 disables)")
+	flag.DurationVar(&cfg.LogMaxAge, "log-max-age", 24*time.Hour, "Rotate -log-file once it has been written to for this long (0 disables)")
+	flag.IntVar(&cfg.LogBackups, "log-backups", 5, "Number of rotated log files to keep")
 
 	return cfg
 }
 
-func (c *Config) Parse() {
-	flag.Parse()
+// Parse parses the service flags from args (without the program name).
+// Board quirks are applied first, then -config, and the command line is
+// parsed again on top of them, so explicit flags always win.
+func (c *Config) Parse(args []string) error {
+	flag.CommandLine.Parse(args)
+	c.origins = make(map[string]string)
+	var explicit []string
+	flag.Visit(func(f *flag.Flag) { explicit = append(explicit, f.Name) })
+
+	if c.Quirks {
+		if err := c.applyQuirks(); err != nil {
+			return err
+		}
+	}
+	if c.ConfigFile != "" {
+		if err := c.LoadFile(c.ConfigFile); err != nil {
+			return err
+		}
+	}
+	flag.CommandLine.Parse(args)
+	for _, name := range explicit {
+		c.origins[name] = "flag"
+	}
+	return nil
+}
+
+// LoadFile applies a config file to the registered flags. Each non-empty
+// line not starting with '#' is "name = value", where name is a flag name
+// and value may be wrapped in double quotes.
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected name = value", path, n)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if name == "config" {
+			return fmt.Errorf("%s:%d: config files cannot include other configs", path, n)
+		}
+		if err := c.set(name, value, fmt.Sprintf("file %s:%d", path, n)); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// set sets a flag and records where the value came from.
+func (c *Config) set(name, value, origin string) error {
+	if err := flag.Set(name, value); err != nil {
+		return err
+	}
+	if c.origins != nil {
+		c.origins[name] = origin
+	}
+	return nil
+}
+
+// secretFlags are redacted from Values, which ends up in Redis and
+// diagnostic bundles.
+var secretFlags = map[string]bool{"mqtt-password": true, "redis-password": true}
+
+// urlFlags may carry credentials in their userinfo, which Values redacts.
+var urlFlags = map[string]bool{"mqtt-broker": true, "redis-url": true}
+
+// Values returns the effective value of every registered flag, keyed by
+// flag name. Non-empty secrets, and the userinfo of URLs, are replaced
+// with "***".
+func (c *Config) Values() map[string]string {
+	values := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		v := f.Value.String()
+		if secretFlags[f.Name] && v != "" {
+			v = "***"
+		}
+		if urlFlags[f.Name] {
+			v = redactURL(v)
+		}
+		values[f.Name] = v
+	})
+	return values
+}
+
+// redactURL replaces the userinfo of raw with "***". A value that doesn't
+// parse is redacted whole, as it may still hold a password.
+func redactURL(raw string) string {
+	if !strings.Contains(raw, "@") {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "***"
+	}
+	if u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return strings.Replace(u.String(), "//", "//***@", 1)
+}
+
+// Setting is one flag of the effective configuration and where its value
+// came from: "default", "quirk <name>", "file <path>:<line>", "flag", or
+// "env <variable>" and "credential <name>" for Redis credentials.
+type Setting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Settings returns every registered flag with its effective value and
+// source, sorted by name. Secrets are redacted as in Values.
+func (c *Config) Settings() []Setting {
+	values := c.Values()
+	var settings []Setting
+	flag.VisitAll(func(f *flag.Flag) {
+		st := Setting{Name: f.Name, Value: values[f.Name], Source: "default"}
+		if origin, ok := c.origins[f.Name]; ok {
+			st.Source = origin
+		} else if env := credentialEnv[f.Name]; env != "" && os.Getenv(env) != "" {
+			st.Value, st.Source = os.Getenv(env), "env "+env
+			if secretFlags[f.Name] {
+				st.Value = "***"
+			}
+		} else if f.Name == "redis-password" && c.RedisPasswordFile == "" && hasCredential("redis-password") {
+			st.Value, st.Source = "***", "credential redis-password"
+		}
+		settings = append(settings, st)
+	})
+	return settings
+}
+
+// credentialEnv names the environment variables Redis credentials fall
+// back to when their flags are unset.
+var credentialEnv = map[string]string{"redis-username": "REDIS_USERNAME", "redis-password": "REDIS_PASSWORD"}
+
+// hasCredential reports whether systemd passed the named credential.
+func hasCredential(name string) bool {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+// RedisCredentials returns the Redis username and password from the flags,
+// falling back to the environment and systemd credentials.
+func (c *Config) RedisCredentials() (username, password string, err error) {
+	username = c.RedisUsername
+	if username == "" {
+		username = os.Getenv("REDIS_USERNAME")
+	}
+	password, err = LookupRedisPassword(c.RedisPassword, c.RedisPasswordFile)
+	return username, password, err
+}
+
+// LookupRedisPassword resolves the Redis password, in order: the contents
+// of file, the literal password, $REDIS_PASSWORD, and the "redis-password"
+// credential passed by systemd's LoadCredential=. Empty means none.
+func LookupRedisPassword(password, file string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read Redis password file: %v", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if password != "" {
+		return password, nil
+	}
+	if env := os.Getenv("REDIS_PASSWORD"); env != "" {
+		return env, nil
+	}
+	if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+		if data, err := os.ReadFile(filepath.Join(dir, "redis-password")); err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+	return "", nil
 }
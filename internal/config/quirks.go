@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Quirk holds per-board flag defaults, selected by the board's model
+// string. Values are applied before the config file and the command line,
+// so both still override them.
+type Quirk struct {
+	Name  string
+	Match string // case-insensitive substring of the model string
+	Flags map[string]string
+}
+
+// builtinQuirks describes the boards this service is known to run on,
+// listing only values that differ from the flag defaults. The defaults are
+// the reference DBC's, so its entry only names the board; further
+// revisions can be added here or through -quirks-file.
+var builtinQuirks = []Quirk{
+	{Name: "dbc", Match: "dbc", Flags: map[string]string{}},
+}
+
+// modelPaths are tried in order for the board's model string: the
+// device tree on ARM boards, DMI elsewhere.
+var modelPaths = []string{
+	"/proc/device-tree/model",
+	"/sys/class/dmi/id/product_name",
+}
+
+// ReadModel returns the board model string from path, or from the first
+// readable entry of modelPaths if path is empty.
+func ReadModel(path string) (string, error) {
+	paths := modelPaths
+	if path != "" {
+		paths = []string{path}
+	}
+	var lastErr error
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		// The device tree string is NUL-terminated.
+		model := strings.TrimSpace(strings.TrimRight(string(data), "\x00"))
+		if model != "" {
+			return model, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("model string is empty")
+	}
+	return "", fmt.Errorf("failed to read board model: %v", lastErr)
+}
+
+// LoadQuirks reads a quirks file. Each "[match]" line starts a quirk for
+// models containing match; the name = value lines that follow are flag
+// values, as in a config file.
+func LoadQuirks(path string) ([]Quirk, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quirks: %v", err)
+	}
+	defer f.Close()
+
+	var quirks []Quirk
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			match := strings.TrimSpace(line[1 : len(line)-1])
+			if match == "" {
+				return nil, fmt.Errorf("%s:%d: empty model match", path, n)
+			}
+			quirks = append(quirks, Quirk{Name: match, Match: match, Flags: make(map[string]string)})
+			continue
+		}
+		if len(quirks) == 0 {
+			return nil, fmt.Errorf("%s:%d: expected [model] before flag values", path, n)
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected name = value", path, n)
+		}
+		name = strings.TrimSpace(name)
+		if name == "config" || name == "quirks-file" {
+			return nil, fmt.Errorf("%s:%d: %s cannot be set by a quirk", path, n, name)
+		}
+		quirks[len(quirks)-1].Flags[name] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return quirks, nil
+}
+
+// MatchQuirk returns the first quirk whose match occurs in model, or nil.
+func MatchQuirk(model string, quirks []Quirk) *Quirk {
+	model = strings.ToLower(model)
+	for i := range quirks {
+		if strings.Contains(model, strings.ToLower(quirks[i].Match)) {
+			return &quirks[i]
+		}
+	}
+	return nil
+}
+
+// applyQuirks looks up the board's quirk, from -quirks-file first and
+// then the built-in table, and sets its flag values. It reports which
+// quirk was applied in c.Quirk. A missing model string is not an error.
+func (c *Config) applyQuirks() error {
+	var quirks []Quirk
+	if c.QuirksFile != "" {
+		loaded, err := LoadQuirks(c.QuirksFile)
+		if err != nil {
+			return err
+		}
+		quirks = loaded
+	}
+	quirks = append(quirks, builtinQuirks...)
+
+	model, err := ReadModel(c.ModelPath)
+	if err != nil {
+		return nil
+	}
+	c.Model = model
+	q := MatchQuirk(model, quirks)
+	if q == nil {
+		return nil
+	}
+	for name, value := range q.Flags {
+		if err := c.set(name, value, "quirk "+q.Name); err != nil {
+			return fmt.Errorf("quirk %s: %v", q.Name, err)
+		}
+	}
+	c.Quirk = q.Name
+	return nil
+}
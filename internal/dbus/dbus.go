@@ -0,0 +1,159 @@
+package dbus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+)
+
+const (
+	busName   = "org.librescoot.Backlight1"
+	iface     = "org.librescoot.Backlight1"
+	objPath   = godbus.ObjectPath("/org/librescoot/Backlight1")
+	callLimit = 5 * time.Second
+)
+
+// Server exports the service on D-Bus as org.librescoot.Backlight1.
+type Server struct {
+	svc    *service.Service
+	logger *log.Logger
+	conn   *godbus.Conn
+	props  *prop.Properties
+	ctx    context.Context
+}
+
+// methods is the exported method set; kept separate from Server so that
+// only the D-Bus methods end up in the introspection data.
+type methods struct {
+	s *Server
+}
+
+// New connects to the system or session bus and claims the bus name. It
+// must be called before svc.Run so it can register for status changes.
+func New(ctx context.Context, svc *service.Service, logger *log.Logger, bus string) (*Server, error) {
+	var conn *godbus.Conn
+	var err error
+	switch bus {
+	case "system":
+		conn, err = godbus.ConnectSystemBus()
+	case "session":
+		conn, err = godbus.ConnectSessionBus()
+	default:
+		return nil, fmt.Errorf("unknown bus %q (expected system or session)", bus)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s bus: %v", bus, err)
+	}
+
+	s := &Server{svc: svc, logger: logger, conn: conn, ctx: ctx}
+	if err := s.export(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reply, err := conn.RequestName(busName, godbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to request name %s: %v", busName, err)
+	}
+	if reply != godbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, fmt.Errorf("name %s already taken", busName)
+	}
+
+	svc.OnStatusChange(s.update)
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	logger.Printf("D-Bus interface %s registered on %s bus", busName, bus)
+	return s, nil
+}
+
+func (s *Server) export() error {
+	m := methods{s: s}
+	if err := s.conn.Export(m, objPath, iface); err != nil {
+		return fmt.Errorf("failed to export methods: %v", err)
+	}
+
+	props, err := prop.Export(s.conn, objPath, prop.Map{
+		iface: {
+			"CurrentLevel": {Value: "", Emit: prop.EmitTrue},
+			"Brightness":   {Value: int32(0), Emit: prop.EmitTrue},
+			"Mode":         {Value: "auto", Emit: prop.EmitTrue},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export properties: %v", err)
+	}
+	s.props = props
+
+	node := &introspect.Node{
+		Name: string(objPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       iface,
+				Methods:    introspect.Methods(m),
+				Properties: props.Introspection(iface),
+			},
+		},
+	}
+	return s.conn.Export(introspect.NewIntrospectable(node), objPath, "org.freedesktop.DBus.Introspectable")
+}
+
+// update mirrors a status change into the exported properties; prop emits
+// PropertiesChanged only for values that actually changed.
+func (s *Server) update(st service.Status) {
+	s.setIfChanged("CurrentLevel", st.Level)
+	s.setIfChanged("Brightness", int32(st.Output))
+	s.setIfChanged("Mode", st.Mode)
+}
+
+func (s *Server) setIfChanged(name string, v interface{}) {
+	if s.props.GetMust(iface, name) != v {
+		s.props.SetMust(iface, name, v)
+	}
+}
+
+func (s *Server) callContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(s.ctx, callLimit)
+}
+
+func dbusError(err error) *godbus.Error {
+	if err == nil {
+		return nil
+	}
+	return godbus.MakeFailedError(err)
+}
+
+// SetLevel overrides the brightness with a named manual level until the
+// mode is changed.
+func (m methods) SetLevel(level string) *godbus.Error {
+	ctx, cancel := m.s.callContext()
+	defer cancel()
+	return dbusError(m.s.svc.SetOverrideLevel(ctx, level, 0))
+}
+
+// SetMode persists the backlight mode ("auto" or a manual level).
+func (m methods) SetMode(mode string) *godbus.Error {
+	ctx, cancel := m.s.callContext()
+	defer cancel()
+	return dbusError(m.s.svc.SetMode(ctx, mode))
+}
+
+// Boost raises the backlight to full brightness for the given seconds.
+func (m methods) Boost(seconds uint32) *godbus.Error {
+	ctx, cancel := m.s.callContext()
+	defer cancel()
+	return dbusError(m.s.svc.Boost(ctx, time.Duration(seconds)*time.Second))
+}
@@ -0,0 +1,139 @@
+// Package debug serves pprof and a JSON dump of the service internals on
+// an opt-in listener.
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+)
+
+// Server is the debug listener.
+type Server struct {
+	svc    *service.Service
+	logger *log.Logger
+	mux    *http.ServeMux
+}
+
+func New(svc *service.Service, logger *log.Logger) *Server {
+	s := &Server{svc: svc, logger: logger, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	s.mux.HandleFunc("/state", s.handleState)
+	s.mux.HandleFunc("/healthz", s.handleHealth(false))
+	s.mux.HandleFunc("/readyz", s.handleHealth(true))
+	return s
+}
+
+// ListenAndServe serves the debug endpoints on addr until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	// No write timeout: CPU profiles and traces stream for their duration.
+	srv := &http.Server{Handler: s.mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	s.logger.Printf("Debug server listening on %s", ln.Addr())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// livenessTimeout is how long the monitor loop may take to answer a health
+// check before the service counts as stuck.
+const livenessTimeout = 2 * time.Second
+
+type healthResponse struct {
+	Status string `json:"status"`
+	*service.Health
+	Error string `json:"error,omitempty"`
+}
+
+// handleHealth serves /healthz (the monitor loop answers) and, with ready
+// set, /readyz (it answers and Redis, the sensor and sysfs writes are all
+// healthy). Failures return 503.
+func (s *Server) handleHealth(ready bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), livenessTimeout)
+		defer cancel()
+
+		resp := healthResponse{Status: "ok"}
+		code := http.StatusOK
+		h, err := s.svc.Health(ctx)
+		switch {
+		case err != nil:
+			resp.Status, resp.Error = "stuck", err.Error()
+			code = http.StatusServiceUnavailable
+		case ready && !h.Ready():
+			resp.Status, resp.Health = "degraded", &h
+			code = http.StatusServiceUnavailable
+		default:
+			resp.Health = &h
+		}
+		writeJSON(w, code, resp)
+	}
+}
+
+// runtimeState is the memory picture asked for when chasing leaks.
+type runtimeState struct {
+	Goroutines int    `json:"goroutines"`
+	HeapAlloc  uint64 `json:"heap_alloc"`
+	HeapInuse  uint64 `json:"heap_inuse"`
+	HeapObjs   uint64 `json:"heap_objects"`
+	Sys        uint64 `json:"sys"`
+	NumGC      uint32 `json:"num_gc"`
+}
+
+type stateResponse struct {
+	service.DebugState
+	Runtime runtimeState `json:"runtime"`
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	st, err := s.svc.DebugState(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	resp := stateResponse{
+		DebugState: st,
+		Runtime: runtimeState{
+			Goroutines: runtime.NumGoroutine(),
+			HeapAlloc:  ms.HeapAlloc,
+			HeapInuse:  ms.HeapInuse,
+			HeapObjs:   ms.HeapObjects,
+			Sys:        ms.Sys,
+			NumGC:      ms.NumGC,
+		},
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,201 @@
+package diag
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	redisClient "github.com/librescoot/dbc-backlight-service/internal/redis"
+)
+
+// Entry is a single timestamped diagnostic record, stored JSON-encoded in
+// Redis by the running service.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Encode returns the JSON form of the entry as stored in Redis.
+func (e Entry) Encode() string {
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
+// Hardware holds sysfs backlight values read locally by the diag command.
+type Hardware struct {
+	Path          string `json:"path"`
+	Brightness    *int   `json:"brightness,omitempty"`
+	MaxBrightness *int   `json:"max_brightness,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Bundle is the support bundle attached to bug reports.
+type Bundle struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Version     string            `json:"version"`
+	Hostname    string            `json:"hostname,omitempty"`
+	Status      map[string]string `json:"status,omitempty"`
+	Mode        string            `json:"mode,omitempty"`
+	Hardware    Hardware          `json:"hardware"`
+	Config      json.RawMessage   `json:"config,omitempty"`
+	Faults      map[string]string `json:"faults,omitempty"`
+	Events      []Entry           `json:"events"`
+	Errors      []Entry           `json:"errors"`
+	Problems    []string          `json:"problems,omitempty"`
+}
+
+// Collect gathers the bundle. Failures to read individual parts are
+// recorded in Problems rather than aborting, since a bundle is most useful
+// precisely when something is broken.
+func Collect(ctx context.Context, redis *redisClient.Client, backlightPath, version string) *Bundle {
+	b := &Bundle{
+		GeneratedAt: time.Now(),
+		Version:     version,
+		Hardware:    readHardware(backlightPath),
+		Events:      []Entry{},
+		Errors:      []Entry{},
+	}
+	b.Hostname, _ = os.Hostname()
+
+	if err := redis.Ping(ctx); err != nil {
+		b.problem("redis unreachable: %v", err)
+		return b
+	}
+
+	if status, err := redis.GetDashboard(ctx); err != nil {
+		b.problem("failed to read dashboard: %v", err)
+	} else {
+		b.Status = status
+	}
+
+	if mode, err := redis.GetBacklightMode(ctx); err != nil {
+		b.problem("failed to read backlight mode: %v", err)
+	} else {
+		b.Mode = mode
+	}
+
+	if cfg, err := redis.GetEffectiveConfig(ctx); err != nil {
+		b.problem("failed to read effective config: %v", err)
+	} else if cfg != "" {
+		b.Config = json.RawMessage(cfg)
+	}
+
+	if faults, err := redis.GetFaults(ctx); err != nil {
+		b.problem("failed to read faults: %v", err)
+	} else if len(faults) > 0 {
+		b.Faults = faults
+	}
+
+	if events, err := redis.GetEvents(ctx); err != nil {
+		b.problem("failed to read events: %v", err)
+	} else {
+		b.Events = b.decode(events)
+	}
+
+	if errs, err := redis.GetErrors(ctx); err != nil {
+		b.problem("failed to read errors: %v", err)
+	} else {
+		b.Errors = b.decode(errs)
+	}
+
+	return b
+}
+
+func (b *Bundle) problem(format string, args ...interface{}) {
+	b.Problems = append(b.Problems, fmt.Sprintf(format, args...))
+}
+
+func (b *Bundle) decode(raw []string) []Entry {
+	entries := make([]Entry, 0, len(raw))
+	for _, r := range raw {
+		var e Entry
+		if err := json.Unmarshal([]byte(r), &e); err != nil {
+			b.problem("malformed entry %q: %v", r, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func readHardware(backlightPath string) Hardware {
+	hw := Hardware{Path: backlightPath}
+	v, err := readInt(backlightPath)
+	if err != nil {
+		hw.Error = err.Error()
+		return hw
+	}
+	hw.Brightness = &v
+	if max, err := readInt(filepath.Join(filepath.Dir(backlightPath), "max_brightness")); err == nil {
+		hw.MaxBrightness = &max
+	}
+	return hw
+}
+
+func readInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// Write writes the bundle to w as indented JSON.
+func (b *Bundle) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// WriteFile writes the bundle to path. Paths ending in .tar.gz or .tgz get a
+// gzipped tarball containing bundle.json; anything else gets plain JSON.
+func (b *Bundle) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if !strings.HasSuffix(path, ".tar.gz") && !strings.HasSuffix(path, ".tgz") {
+		if err := b.Write(f); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	hdr := &tar.Header{
+		Name:    "bundle.json",
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: b.GeneratedAt,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// File is an io.Writer appending to a log file that is rotated once it
+// grows past MaxSize bytes or has been written to for longer than MaxAge.
+// Rotated files are kept as path.1 (newest) to path.<Backups>.
+type File struct {
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	backups int
+	now     func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// OpenFile opens path for appending. A zero maxSize or maxAge disables
+// that rotation trigger.
+func OpenFile(path string, maxSize int64, maxAge time.Duration, backups int) (*File, error) {
+	f := &File{path: path, maxSize: maxSize, maxAge: maxAge, backups: backups, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	f.file, f.size, f.opened = file, info.Size(), f.now()
+	return nil
+}
+
+// Write appends one log message, rotating first if it would overflow the
+// current file. A single message is never split across files.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var rotateErr error
+	if f.due(int64(len(p))) {
+		rotateErr = f.rotate()
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+func (f *File) due(next int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.maxSize > 0 && f.size+next > f.maxSize {
+		return true
+	}
+	return f.maxAge > 0 && f.now().Sub(f.opened) >= f.maxAge
+}
+
+// rotate shifts path.N to path.N+1, dropping the oldest, moves the
+// current file to path.1 and starts a new one. If the current file cannot
+// be moved aside it is reopened, so logging continues in place.
+func (f *File) rotate() error {
+	f.file.Close()
+	os.Remove(f.backup(f.backups))
+	for i := f.backups - 1; i >= 1; i-- {
+		os.Rename(f.backup(i), f.backup(i+1))
+	}
+	if f.backups > 0 {
+		if err := os.Rename(f.path, f.backup(1)); err != nil {
+			if openErr := f.open(); openErr != nil {
+				return openErr
+			}
+			return fmt.Errorf("failed to rotate log file: %v", err)
+		}
+	} else {
+		os.Remove(f.path)
+	}
+	return f.open()
+}
+
+func (f *File) backup(n int) string { return fmt.Sprintf("%s.%d", f.path, n) }
+
+// Close closes the current log file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
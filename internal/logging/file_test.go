@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFileRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backlight.log")
+	f, err := OpenFile(path, 10, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, msg := range []string{"one\n", "two\n", "three\n", "four\n", "five\n", "six\n"} {
+		if _, err := f.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readLog(t, path); got != "six\n" {
+		t.Errorf("current log = %q", got)
+	}
+	if got := readLog(t, path+".1"); got != "four\nfive\n" {
+		t.Errorf("first backup = %q", got)
+	}
+	if got := readLog(t, path+".2"); got != "three\n" {
+		t.Errorf("second backup = %q", got)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, stat .3: %v", err)
+	}
+}
+
+func TestFileRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backlight.log")
+	now := time.Unix(0, 0)
+	f := &File{path: path, maxAge: time.Hour, backups: 1, now: func() time.Time { return now }}
+	if err := f.open(); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("old\n"))
+	now = now.Add(30 * time.Minute)
+	f.Write([]byte("still\n"))
+	now = now.Add(30 * time.Minute)
+	f.Write([]byte("new\n"))
+
+	if got := readLog(t, path); got != "new\n" {
+		t.Errorf("current log = %q", got)
+	}
+	if got := readLog(t, path+".1"); got != "old\nstill\n" {
+		t.Errorf("backup = %q", got)
+	}
+}
+
+func TestFileAppendsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backlight.log")
+	os.WriteFile(path, []byte("before\n"), 0644)
+	f, err := OpenFile(path, 1<<20, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("after\n"))
+	f.Close()
+	if got := readLog(t, path); got != "before\nafter\n" {
+		t.Errorf("log = %q", got)
+	}
+}
+
+func TestFileKeepsWritingWhenRotateFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backlight.log")
+	f, err := OpenFile(path, 10, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// A non-empty directory in place of the backup makes the rename fail.
+	os.MkdirAll(filepath.Join(path+".1", "keep"), 0755)
+
+	f.Write([]byte("one\n"))
+	f.Write([]byte("two\n"))
+	if _, err := f.Write([]byte("three\n")); err == nil {
+		t.Error("expected the rotate error to be reported")
+	}
+	if _, err := f.Write([]byte("four\n")); err == nil {
+		t.Error("expected the rotate error on the next write too")
+	}
+	if got := readLog(t, path); got != "one\ntwo\nthree\nfour\n" {
+		t.Errorf("current log = %q", got)
+	}
+}
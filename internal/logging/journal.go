@@ -0,0 +1,109 @@
+// Package logging provides log destinations for the service: the systemd
+// journal with per-message priorities and a rotated log file.
+package logging
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JournalSocket is where journald accepts native protocol datagrams.
+const JournalSocket = "/run/systemd/journal/socket"
+
+// Priority is a syslog priority as used by the journal's PRIORITY field.
+type Priority int
+
+const (
+	Err     Priority = 3
+	Warning Priority = 4
+	Info    Priority = 6
+	Debug   Priority = 7
+)
+
+// Classify derives a priority from a log message. The service marks its
+// messages by convention: "Warning: ..." and "Debug: ..." prefixes, and
+// "Failed ..." or "... failed: ..." for errors. Everything else is info.
+func Classify(msg string) Priority {
+	switch {
+	case strings.HasPrefix(msg, "Debug:"):
+		return Debug
+	case strings.HasPrefix(msg, "Warning:"):
+		return Warning
+	case strings.HasPrefix(msg, "Error"),
+		strings.HasPrefix(msg, "Failed"),
+		strings.HasPrefix(msg, "Refusing"),
+		strings.HasPrefix(msg, "Invalid"),
+		strings.Contains(msg, " failed: "):
+		return Err
+	}
+	return Info
+}
+
+// Journal is an io.Writer for log.Logger that sends each message to
+// journald with a PRIORITY field, so journalctl -p can filter them.
+// Messages that cannot be sent go to Fallback instead.
+type Journal struct {
+	Fallback io.Writer
+
+	mu         sync.Mutex
+	conn       *net.UnixConn
+	identifier string
+	buf        []byte
+}
+
+// DialJournal connects to the journal socket at path, JournalSocket if
+// empty. Messages are tagged with identifier as SYSLOG_IDENTIFIER.
+func DialJournal(path, identifier string) (*Journal, error) {
+	if path == "" {
+		path = JournalSocket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journal: %v", err)
+	}
+	return &Journal{conn: conn, identifier: identifier}, nil
+}
+
+// Write sends one log message. log.Logger calls it once per message.
+func (j *Journal) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.buf = j.buf[:0]
+	j.buf = appendField(j.buf, "PRIORITY", strconv.Itoa(int(Classify(msg))))
+	if j.identifier != "" {
+		j.buf = appendField(j.buf, "SYSLOG_IDENTIFIER", j.identifier)
+	}
+	j.buf = appendField(j.buf, "MESSAGE", msg)
+	if _, err := j.conn.Write(j.buf); err != nil {
+		if j.Fallback != nil {
+			return j.Fallback.Write(p)
+		}
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the journal connection.
+func (j *Journal) Close() error { return j.conn.Close() }
+
+// appendField encodes one field of the native journal protocol. Values
+// containing a newline use the length-prefixed binary form.
+func appendField(buf []byte, name, value string) []byte {
+	buf = append(buf, name...)
+	if !strings.Contains(value, "\n") {
+		buf = append(buf, '=')
+		buf = append(buf, value...)
+		return append(buf, '\n')
+	}
+	buf = append(buf, '\n')
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(value)))
+	buf = append(buf, value...)
+	return append(buf, '\n')
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want Priority
+	}{
+		{"Starting backlight service (poll=1s)", Info},
+		{"Warning: Failed to publish to Redis: timeout", Warning},
+		{"Failed to adjust backlight: permission denied", Err},
+		{"HTTP API failed: address in use", Err},
+		{"Refusing to start: lock held", Err},
+		{"Debug: lux=12.0 mode=auto -> target 4000", Debug},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.msg); got != tt.want {
+			t.Errorf("Classify(%q) = %d, want %d", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func listenJournal(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return path, conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestJournalSendsPriority(t *testing.T) {
+	path, server := listenJournal(t)
+	j, err := DialJournal(path, "dbc-backlight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	log.New(j, "", 0).Printf("Warning: sensor stale")
+	want := "PRIORITY=4\nSYSLOG_IDENTIFIER=dbc-backlight\nMESSAGE=Warning: sensor stale\n"
+	if got := receive(t, server); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestJournalMultilineMessage(t *testing.T) {
+	path, server := listenJournal(t)
+	j, err := DialJournal(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	log.New(j, "", 0).Printf("Curve:\n  0 -> 100")
+	got := receive(t, server)
+	want := "MESSAGE\n\x11\x00\x00\x00\x00\x00\x00\x00Curve:\n  0 -> 100\n"
+	if !strings.HasPrefix(got, "PRIORITY=6\n") || !strings.HasSuffix(got, want) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestJournalFallsBackWhenUnreachable(t *testing.T) {
+	path, server := listenJournal(t)
+	j, err := DialJournal(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	server.Close()
+
+	var out strings.Builder
+	j.Fallback = &out
+	log.New(j, "", 0).Printf("hello")
+	if out.String() != "hello\n" {
+		t.Errorf("fallback got %q", out.String())
+	}
+}
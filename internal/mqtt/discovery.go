@@ -0,0 +1,140 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+)
+
+// commandTimeout bounds a Home Assistant command waiting on the service.
+const commandTimeout = 5 * time.Second
+
+// haDevice groups the entities under one device in Home Assistant.
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// publishDiscovery announces a light entity (power and brightness) and an
+// "auto" switch. Turning the switch off, or setting a brightness, holds
+// the brightness as a local override until auto is switched back on.
+func (b *Bridge) publishDiscovery(c paho.Client) {
+	device := haDevice{
+		Identifiers:  []string{b.nodeID},
+		Name:         "Scooter dashboard",
+		Manufacturer: "LibreScoot",
+		Model:        "DBC",
+	}
+	// on_command_type "first" sends ON before any brightness, so a plain
+	// turn-on reaches onPower and a brightness still overrides after it.
+	light := map[string]interface{}{
+		"name":                     "Backlight",
+		"unique_id":                b.nodeID + "_backlight",
+		"device":                   device,
+		"availability_topic":       b.topic("availability"),
+		"command_topic":            b.topic("power/set"),
+		"state_topic":              b.topic("power"),
+		"brightness_command_topic": b.topic("brightness/set"),
+		"brightness_state_topic":   b.topic("brightness"),
+		"brightness_scale":         b.svc.MaxBrightness(),
+		"on_command_type":          "first",
+	}
+	auto := map[string]interface{}{
+		"name":               "Automatic backlight",
+		"unique_id":          b.nodeID + "_backlight_auto",
+		"device":             device,
+		"availability_topic": b.topic("availability"),
+		"command_topic":      b.topic("auto/set"),
+		"state_topic":        b.topic("auto"),
+	}
+
+	b.publishConfig(c, "light", "backlight", light)
+	b.publishConfig(c, "switch", "backlight_auto", auto)
+
+	c.Subscribe(b.topic("power/set"), 0, b.onPower)
+	c.Subscribe(b.topic("brightness/set"), 0, b.onBrightness)
+	c.Subscribe(b.topic("auto/set"), 0, b.onAuto)
+}
+
+func (b *Bridge) publishConfig(c paho.Client, component, object string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	topic := b.discoveryPrefix + "/" + component + "/" + b.nodeID + "/" + object + "/config"
+	c.Publish(topic, 1, true, data)
+}
+
+// publishHAState publishes the ON/OFF topics the discovery entities read.
+func (b *Bridge) publishHAState(st service.Status) {
+	power := "OFF"
+	if st.Enabled && st.Output > 0 {
+		power = "ON"
+	}
+	auto := "OFF"
+	if st.Mode == "auto" && st.Override == nil {
+		auto = "ON"
+	}
+	b.client.Publish(b.topic("power"), 0, true, power)
+	b.client.Publish(b.topic("auto"), 0, true, auto)
+}
+
+func (b *Bridge) onPower(_ paho.Client, msg paho.Message) {
+	ctx, cancel := context.WithTimeout(b.ctx, commandTimeout)
+	defer cancel()
+
+	var err error
+	switch strings.TrimSpace(string(msg.Payload())) {
+	case "ON":
+		err = b.svc.ClearOverride(ctx)
+	case "OFF":
+		err = b.svc.SetOverride(ctx, 0, 0)
+	default:
+		return
+	}
+	if err != nil {
+		b.logger.Printf("MQTT power command failed: %v", err)
+	}
+}
+
+func (b *Bridge) onBrightness(_ paho.Client, msg paho.Message) {
+	brightness, err := strconv.Atoi(strings.TrimSpace(string(msg.Payload())))
+	if err != nil {
+		b.logger.Printf("Ignoring MQTT brightness %q", msg.Payload())
+		return
+	}
+	ctx, cancel := context.WithTimeout(b.ctx, commandTimeout)
+	defer cancel()
+	if err := b.svc.SetOverride(ctx, brightness, 0); err != nil {
+		b.logger.Printf("MQTT brightness command failed: %v", err)
+	}
+}
+
+func (b *Bridge) onAuto(_ paho.Client, msg paho.Message) {
+	ctx, cancel := context.WithTimeout(b.ctx, commandTimeout)
+	defer cancel()
+
+	var err error
+	switch strings.TrimSpace(string(msg.Payload())) {
+	case "ON":
+		err = b.svc.SetMode(ctx, "auto")
+	case "OFF":
+		var st service.Status
+		if st, err = b.svc.Status(ctx); err == nil {
+			err = b.svc.SetOverride(ctx, st.Output, 0)
+		}
+	default:
+		return
+	}
+	if err != nil {
+		b.logger.Printf("MQTT auto command failed: %v", err)
+	}
+}
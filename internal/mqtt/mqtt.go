@@ -0,0 +1,180 @@
+// Package mqtt bridges the service to an MQTT broker: it can take lux
+// readings from a topic and publishes the backlight state.
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/internal/sensor"
+	"github.com/librescoot/dbc-backlight-service/internal/service"
+)
+
+const disconnectQuiesce = 250 // ms
+
+// Bridge is a connection to the MQTT broker.
+type Bridge struct {
+	// Lux is the lux source fed by -mqtt-lux-topic, or nil if unset.
+	Lux *sensor.Latest
+
+	client          paho.Client
+	prefix          string
+	discoveryPrefix string // empty disables Home Assistant discovery
+	settings        bool   // accept pushes on <prefix>/settings/set
+	nodeID          string
+	svc             *service.Service
+	logger          *log.Logger
+	ctx             context.Context
+}
+
+// New connects to cfg.MQTTBroker in the background, retrying until it is
+// reachable. It must be called before svc.Run so it can register for
+// status changes.
+func New(ctx context.Context, svc *service.Service, logger *log.Logger, cfg *config.Config) (*Bridge, error) {
+	if cfg.MQTTLuxTopic != "" && strings.ContainsAny(cfg.MQTTLuxTopic, "+#") {
+		return nil, fmt.Errorf("mqtt-lux-topic %q must not contain wildcards", cfg.MQTTLuxTopic)
+	}
+
+	b := &Bridge{
+		prefix:          strings.TrimSuffix(cfg.MQTTTopicPrefix, "/"),
+		discoveryPrefix: strings.TrimSuffix(cfg.MQTTDiscoveryPrefix, "/"),
+		nodeID:          nodeID(cfg.MQTTClientID),
+		settings:        cfg.SettingsKey != "",
+		svc:             svc,
+		logger:          logger,
+		ctx:             ctx,
+	}
+	if cfg.MQTTLuxTopic != "" {
+		b.Lux = &sensor.Latest{Name: "mqtt:" + cfg.MQTTLuxTopic, MaxAge: cfg.MQTTLuxMaxAge}
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.MQTTBroker).
+		SetClientID(cfg.MQTTClientID).
+		SetUsername(cfg.MQTTUsername).
+		SetPassword(cfg.MQTTPassword).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(10*time.Second).
+		SetOrderMatters(false).
+		SetWill(b.topic("availability"), "offline", 1, true).
+		SetOnConnectHandler(func(c paho.Client) {
+			logger.Printf("MQTT connected to %s", cfg.MQTTBroker)
+			c.Publish(b.topic("availability"), 1, true, "online")
+			if b.Lux != nil {
+				c.Subscribe(cfg.MQTTLuxTopic, 0, b.onLux)
+			}
+			if b.settings {
+				c.Subscribe(b.topic("settings/set"), 1, b.onSettings)
+			}
+			if b.discoveryPrefix != "" {
+				b.publishDiscovery(c)
+			}
+			// Retained state may be stale after a reconnect.
+			ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+			defer cancel()
+			if st, err := svc.Status(ctx); err == nil {
+				b.update(st)
+			}
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			logger.Printf("MQTT connection lost: %v", err)
+		})
+
+	b.client = paho.NewClient(opts)
+	b.client.Connect()
+
+	svc.OnStatusChange(b.update)
+	go func() {
+		<-ctx.Done()
+		if b.client.IsConnected() {
+			b.client.Publish(b.topic("availability"), 1, true, "offline").WaitTimeout(time.Second)
+		}
+		b.client.Disconnect(disconnectQuiesce)
+	}()
+
+	return b, nil
+}
+
+// nodeID turns the client ID into a Home Assistant node ID, which may only
+// contain letters, digits, '_' and '-'.
+func nodeID(clientID string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, clientID)
+}
+
+func (b *Bridge) topic(name string) string {
+	return b.prefix + "/" + name
+}
+
+// onLux accepts a bare number or a JSON object with a "lux" or
+// "illuminance" field.
+func (b *Bridge) onLux(_ paho.Client, msg paho.Message) {
+	lux, err := parseLux(msg.Payload())
+	if err != nil {
+		b.logger.Printf("Ignoring MQTT lux on %s: %v", msg.Topic(), err)
+		return
+	}
+	b.Lux.Set(lux)
+}
+
+func parseLux(payload []byte) (float64, error) {
+	s := strings.TrimSpace(string(payload))
+	if lux, err := strconv.ParseFloat(s, 64); err == nil {
+		return lux, nil
+	}
+	var obj map[string]float64
+	if err := json.Unmarshal([]byte(s), &obj); err != nil {
+		return 0, fmt.Errorf("invalid payload %q", s)
+	}
+	for _, key := range []string{"lux", "illuminance"} {
+		if lux, ok := obj[key]; ok {
+			return lux, nil
+		}
+	}
+	return 0, fmt.Errorf("no lux or illuminance field in %q", s)
+}
+
+// onSettings pushes a JSON object with "curve" and/or "manual-levels" to
+// -settings-key, where it is on probation like any other push.
+func (b *Bridge) onSettings(_ paho.Client, msg paho.Message) {
+	var fields map[string]string
+	if err := json.Unmarshal(msg.Payload(), &fields); err != nil || len(fields) == 0 {
+		b.logger.Printf("Ignoring MQTT settings %q", msg.Payload())
+		return
+	}
+	ctx, cancel := context.WithTimeout(b.ctx, commandTimeout)
+	defer cancel()
+	if err := b.svc.PushSettings(ctx, fields); err != nil {
+		b.logger.Printf("MQTT settings push failed: %v", err)
+	}
+}
+
+// update publishes the state as retained messages. It runs on the service
+// loop, so it never waits for the broker.
+func (b *Bridge) update(st service.Status) {
+	if !b.client.IsConnected() {
+		return
+	}
+	b.client.Publish(b.topic("brightness"), 0, true, strconv.Itoa(st.Output))
+	b.client.Publish(b.topic("level"), 0, true, st.Level)
+	b.client.Publish(b.topic("mode"), 0, true, st.Mode)
+	if data, err := json.Marshal(st); err == nil {
+		b.client.Publish(b.topic("state"), 0, true, data)
+	}
+	if b.discoveryPrefix != "" {
+		b.publishHAState(st)
+	}
+}
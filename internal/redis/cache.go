@@ -0,0 +1,136 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidateChannel is where Redis sends client tracking invalidations
+// for connections redirected to a RESP2 subscriber.
+const invalidateChannel = "__redis__:invalidate"
+
+// luxCache keeps the last illuminance value and only re-reads it from
+// Redis after the server reports the dashboard hash changed. It uses
+// RESP2 redirect mode: a dedicated subscriber receives the invalidations
+// and a sticky connection with CLIENT TRACKING does the reads.
+type luxCache struct {
+	main   *redis.Client
+	sub    *redis.Client
+	pubsub *redis.PubSub
+
+	// subID is the subscriber's client ID, refreshed on every reconnect
+	// so tracking can follow it.
+	subID atomic.Int64
+
+	mu      sync.Mutex
+	conn    *redis.Conn
+	tracked int64 // subscriber ID conn redirects to
+	valid   bool
+	values  []interface{}
+	err     error
+}
+
+// EnableLuxCache turns on client-side caching of the illuminance field.
+// Repeated reads of an unchanged value are then served locally.
+func (c *Client) EnableLuxCache(ctx context.Context) error {
+	lc := &luxCache{main: c.client}
+
+	opt := *c.client.Options()
+	opt.Protocol = 2
+	opt.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		id, err := cn.ClientID(ctx).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get client ID: %v", err)
+		}
+		lc.subID.Store(id)
+		return nil
+	}
+	lc.sub = redis.NewClient(&opt)
+	lc.pubsub = lc.sub.Subscribe(ctx, invalidateChannel)
+	if _, err := lc.pubsub.Receive(ctx); err != nil {
+		lc.close()
+		return fmt.Errorf("failed to subscribe to invalidations: %v", err)
+	}
+
+	go func() {
+		for range lc.pubsub.Channel() {
+			// The payload names the invalidated keys, or is empty on a
+			// flush; either way the cached fields are stale.
+			lc.invalidate()
+		}
+	}()
+
+	c.cache = lc
+	return nil
+}
+
+func (lc *luxCache) invalidate() {
+	lc.mu.Lock()
+	lc.valid = false
+	lc.mu.Unlock()
+}
+
+// get returns the cached HMGET of the dashboard lux fields, fetching it
+// if it was invalidated or tracking had to be (re)established.
+func (lc *luxCache) get(ctx context.Context) ([]interface{}, error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if err := lc.track(ctx); err != nil {
+		return nil, err
+	}
+	if lc.valid {
+		return lc.values, lc.err
+	}
+
+	values, err := lc.conn.HMGet(ctx, "dashboard", luxFields...).Result()
+	if err != nil && err != redis.Nil {
+		lc.drop()
+		return nil, err
+	}
+	lc.values, lc.err, lc.valid = values, err, true
+	return values, err
+}
+
+// track makes sure the read connection is tracking on behalf of the
+// current subscriber. A subscriber reconnect may have lost
+// invalidations, so the cache is dropped whenever tracking changes.
+func (lc *luxCache) track(ctx context.Context) error {
+	id := lc.subID.Load()
+	if lc.conn != nil && lc.tracked == id {
+		return nil
+	}
+	lc.drop()
+	conn := lc.main.Conn()
+	if err := conn.Do(ctx, "CLIENT", "TRACKING", "ON", "REDIRECT", id).Err(); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to enable client tracking: %v", err)
+	}
+	lc.conn = conn
+	lc.tracked = id
+	return nil
+}
+
+// drop forgets the cached value and the tracking connection; caller holds
+// mu.
+func (lc *luxCache) drop() {
+	lc.valid = false
+	if lc.conn != nil {
+		lc.conn.Close()
+		lc.conn = nil
+	}
+}
+
+func (lc *luxCache) close() {
+	lc.mu.Lock()
+	lc.drop()
+	lc.mu.Unlock()
+	if lc.pubsub != nil {
+		lc.pubsub.Close()
+	}
+	lc.sub.Close()
+}
@@ -0,0 +1,70 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StreamCommand is a control command read from the command stream.
+type StreamCommand struct {
+	ID      string
+	Command string
+}
+
+// CreateCommandGroup creates the consumer group on the command stream,
+// creating the stream too if needed. A new group starts at the beginning
+// of the stream, so commands queued before the first start still run; an
+// existing group is left alone so unacknowledged entries survive restarts.
+func (c *Client) CreateCommandGroup(ctx context.Context, stream, group string) error {
+	err := c.client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %v", err)
+	}
+	return nil
+}
+
+// ReadCommands reads up to count entries for consumer. With pending set
+// it returns entries already delivered to this consumer but never
+// acknowledged, without blocking; otherwise it waits up to block for new
+// ones. A timeout returns no entries and no error.
+func (c *Client) ReadCommands(ctx context.Context, stream, group, consumer string, pending bool, count int64, block time.Duration) ([]StreamCommand, error) {
+	id := ">"
+	if pending {
+		id = "0"
+		block = -1
+	}
+	res, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, id},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commands: %v", err)
+	}
+
+	var cmds []StreamCommand
+	for _, s := range res {
+		for _, msg := range s.Messages {
+			cmd, _ := msg.Values["command"].(string)
+			cmds = append(cmds, StreamCommand{ID: msg.ID, Command: cmd})
+		}
+	}
+	return cmds, nil
+}
+
+// AckCommand acknowledges a processed command stream entry.
+func (c *Client) AckCommand(ctx context.Context, stream, group, id string) error {
+	if err := c.client.XAck(ctx, stream, group, id).Err(); err != nil {
+		return fmt.Errorf("failed to acknowledge command %s: %v", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Fields of the dashboard hash describing the lux reading. The timestamp
+// and status are optional; illumination services that don't write them
+// leave the decisions based on them to the brightness value alone.
+const (
+	luxField          = "brightness"
+	luxTimestampField = "brightness:timestamp"
+	luxStatusField    = "brightness:status"
+)
+
+var luxFields = []string{luxField, luxTimestampField, luxStatusField}
+
+// Illuminance is a lux reading together with the metadata stored next to
+// it, so freshness and validity can be judged without another round trip.
+type Illuminance struct {
+	Lux     float64
+	Present bool      // the brightness field is set
+	Time    time.Time // when the reading was taken (zero = unknown)
+	Status  string    // sensor status as reported ("" = unknown)
+}
+
+// Age returns how old the reading is at now, or 0 if its time is unknown.
+func (il Illuminance) Age(now time.Time) time.Duration {
+	if il.Time.IsZero() {
+		return 0
+	}
+	return now.Sub(il.Time)
+}
+
+// parseIlluminance decodes an HMGET of luxFields.
+func parseIlluminance(values []interface{}) (Illuminance, error) {
+	var il Illuminance
+	if len(values) != len(luxFields) {
+		return il, fmt.Errorf("expected %d illuminance fields, got %d", len(luxFields), len(values))
+	}
+	if v, ok := values[0].(string); ok {
+		lux, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return il, fmt.Errorf("invalid illuminance value: %v", err)
+		}
+		il.Lux, il.Present = lux, true
+	}
+	if v, ok := values[1].(string); ok {
+		il.Time = parseTimestamp(v)
+	}
+	if v, ok := values[2].(string); ok {
+		il.Status = v
+	}
+	return il, nil
+}
+
+// parseTimestamp accepts Unix seconds, Unix milliseconds (with or without
+// a fraction) or RFC 3339. Anything else counts as unknown.
+func parseTimestamp(v string) time.Time {
+	if n, err := strconv.ParseFloat(v, 64); err == nil && n > 0 {
+		if n >= 1e12 {
+			return time.UnixMilli(int64(n))
+		}
+		sec := int64(n)
+		return time.Unix(sec, int64((n-float64(sec))*1e9))
+	}
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// GetIlluminance reads the lux value, its timestamp and the sensor status
+// from the dashboard hash in one HMGET. A missing value reads as 0 lux.
+func (c *Client) GetIlluminance(ctx context.Context) (Illuminance, error) {
+	var values []interface{}
+	var err error
+	if c.cache != nil {
+		values, err = c.cache.get(ctx)
+	} else {
+		values, err = c.client.HMGet(ctx, "dashboard", luxFields...).Result()
+	}
+	if err != nil && err != redis.Nil {
+		return Illuminance{}, fmt.Errorf("failed to get illuminance value: %v", err)
+	}
+	il, err := parseIlluminance(values)
+	if err != nil {
+		return il, err
+	}
+	if !il.Present {
+		c.logger.Printf("Illuminance value not found in Redis")
+	}
+	return il, nil
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseIlluminance(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []interface{}
+		want    Illuminance
+		wantErr bool
+	}{
+		{"float", []interface{}{"42.5", nil, nil}, Illuminance{Lux: 42.5, Present: true}, false},
+		{"integer", []interface{}{"100", nil, nil}, Illuminance{Lux: 100, Present: true}, false},
+		{"sub-lux", []interface{}{"0.25", nil, nil}, Illuminance{Lux: 0.25, Present: true}, false},
+		{"negative", []interface{}{"-10.5", nil, nil}, Illuminance{Lux: -10.5, Present: true}, false},
+		{"missing", []interface{}{nil, nil, nil}, Illuminance{}, false},
+		{"status", []interface{}{"3", nil, "error"}, Illuminance{Lux: 3, Present: true, Status: "error"}, false},
+		{"invalid", []interface{}{"abc", nil, nil}, Illuminance{}, true},
+		{"empty", []interface{}{"", nil, nil}, Illuminance{}, true},
+		{"short", []interface{}{"1"}, Illuminance{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseIlluminance(tt.values)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Unix(1700000000, 0)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"1700000000", want},
+		{"1700000000.5", want.Add(500 * time.Millisecond)},
+		{"1700000000000", want},
+		{want.UTC().Format(time.RFC3339), want},
+		{"yesterday", time.Time{}},
+		{"0", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseTimestamp(tt.value); !got.Equal(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIlluminanceAge(t *testing.T) {
+	now := time.Unix(1700000010, 0)
+	if age := (Illuminance{Time: time.Unix(1700000000, 0)}).Age(now); age != 10*time.Second {
+		t.Errorf("expected 10s, got %v", age)
+	}
+	if age := (Illuminance{}).Age(now); age != 0 {
+		t.Errorf("expected 0 for an unknown time, got %v", age)
+	}
+}
@@ -2,93 +2,787 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	eventsKey = "backlight:events"
+	errorsKey = "backlight:errors"
+	configKey = "backlight:config"
+	statusKey = "backlight:status"
+	faultsKey = "backlight:faults"
+	statsKey  = "backlight:stats"
+
+	capabilitiesKey = "backlight:capabilities"
+	suggestionKey   = "backlight:curve-suggestion"
+	learnedKey      = "backlight:learned"
+
+	// CommandChannel carries control commands such as "bias-up".
+	CommandChannel = "backlight:command"
+
+	maxEvents = 50
+	maxErrors = 20
+)
+
 type Client struct {
 	client *redis.Client
 	logger *log.Logger
+	cache  *luxCache // nil unless EnableLuxCache was called
+	hook   timeoutHook
+}
+
+// Options adjust the connection beyond what the URL specifies. Zero
+// values keep the URL's (or go-redis') defaults.
+type Options struct {
+	// ReadTimeout and WriteTimeout bound every Redis call so a hung
+	// connection can't stall the poll cycle.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	Username string
+	Password string
+	DB       int // -1 keeps the URL's database
+
+	// TLS client certificate and CA bundle. Setting any of them enables
+	// TLS, as does a rediss:// URL.
+	TLSCert string
+	TLSKey  string
+	TLSCA   string
 }
 
-func New(redisURL string, logger *log.Logger) (*Client, error) {
+func New(redisURL string, logger *log.Logger, opts Options) (*Client, error) {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid redis URL: %v", err)
 	}
+	if opts.ReadTimeout > 0 {
+		opt.ReadTimeout = opts.ReadTimeout
+	}
+	if opts.WriteTimeout > 0 {
+		opt.WriteTimeout = opts.WriteTimeout
+	}
+	opt.ContextTimeoutEnabled = true
+	if opts.Username != "" {
+		opt.Username = opts.Username
+	}
+	if opts.Password != "" {
+		opt.Password = opts.Password
+	}
+	if opts.DB >= 0 {
+		opt.DB = opts.DB
+	}
+	if opts.TLSCert != "" || opts.TLSKey != "" || opts.TLSCA != "" {
+		tlsConfig, err := loadTLS(opts, opt.TLSConfig, opt.Addr)
+		if err != nil {
+			return nil, err
+		}
+		opt.TLSConfig = tlsConfig
+	}
 
 	client := redis.NewClient(opt)
+	hook := timeoutHook{opts.ReadTimeout, opts.WriteTimeout}
+	client.AddHook(hook)
 	return &Client{
 		client: client,
 		logger: logger,
+		hook:   hook,
 	}, nil
 }
 
+// Reopen returns a new client with the same settings, for use after c was
+// closed. It connects lazily, on the first command.
+func (c *Client) Reopen() *Client {
+	opt := *c.client.Options()
+	client := redis.NewClient(&opt)
+	client.AddHook(c.hook)
+	return &Client{
+		client: client,
+		logger: c.logger,
+		hook:   c.hook,
+	}
+}
+
+// loadTLS builds the client TLS config from the certificate options,
+// starting from the URL's config if it already enabled TLS.
+func loadTLS(opts Options, base *tls.Config, addr string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	if cfg.ServerName == "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			cfg.ServerName = host
+		}
+	}
+	if opts.TLSCert != "" || opts.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCert, opts.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if opts.TLSCA != "" {
+		pem, err := os.ReadFile(opts.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.TLSCA)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
 func (c *Client) Ping(ctx context.Context) error {
 	return c.client.Ping(ctx).Err()
 }
 
-func (c *Client) GetIlluminanceValue(ctx context.Context) (float64, error) {
-	result, err := c.client.HGet(ctx, "dashboard", "brightness").Result()
+// GetBacklightValue returns the last brightness published to the
+// dashboard hash, or -1 if none is stored.
+func (c *Client) GetBacklightValue(ctx context.Context) (int, error) {
+	value, err := c.client.HGet(ctx, "dashboard", "backlight").Int()
+	if err == redis.Nil {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get backlight value: %v", err)
+	}
+	return value, nil
+}
+
+func (c *Client) GetBacklightEnabled(ctx context.Context) (bool, error) {
+	result, err := c.client.HGet(ctx, "dashboard", "backlight-enabled").Result()
 	if err != nil {
 		if err == redis.Nil {
-			c.logger.Printf("Illuminance value not found in Redis")
-			return 0, nil
+			return true, nil // default to enabled when key doesn't exist
+		}
+		return true, err
+	}
+	return result == "true", nil
+}
+
+// GetDashboardFlag reports whether field of the dashboard hash is set to
+// true, as the UI does with ready once it is up.
+func (c *Client) GetDashboardFlag(ctx context.Context, field string) (bool, error) {
+	result, err := c.client.HGet(ctx, "dashboard", field).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get dashboard %s: %v", field, err)
+	}
+	return result == "true", nil
+}
+
+func (c *Client) GetBacklightMode(ctx context.Context) (string, error) {
+	result, err := c.client.HGet(ctx, "settings", "dashboard.backlight-mode").Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "auto", nil // default to auto when key doesn't exist
 		}
-		return 0, fmt.Errorf("failed to get illuminance value: %v", err)
+		return "auto", err
 	}
+	return result, nil
+}
 
-	lux, err := strconv.ParseFloat(result, 64)
+// GetVehicleState returns the vehicle state (e.g. "parked", "stand-by",
+// "ready-to-drive"), or "" when it isn't known.
+func (c *Client) GetVehicleState(ctx context.Context) (string, error) {
+	result, err := c.client.HGet(ctx, "vehicle", "state").Result()
 	if err != nil {
-		return 0, fmt.Errorf("invalid illuminance value: %v", err)
+		if err == redis.Nil {
+			return "", nil
+		}
+		return "", err
 	}
+	return result, nil
+}
 
-	return lux, nil
+// GetBlinkerState returns the turn indicator state ("off", "left",
+// "right" or "both"), or "" when it isn't known.
+func (c *Client) GetBlinkerState(ctx context.Context) (string, error) {
+	return c.GetHashField(ctx, "vehicle", "blinker:state")
 }
 
-func (c *Client) SetBacklightValue(ctx context.Context, value int) error {
+// GetSpeed returns the engine-ecu speed field (km/h), or "" if unset.
+func (c *Client) GetSpeed(ctx context.Context) (string, error) {
+	return c.GetHashField(ctx, "engine-ecu", "speed")
+}
+
+// GetPowerState returns the power manager state (e.g. "running",
+// "suspending"), or "" if unset.
+func (c *Client) GetPowerState(ctx context.Context) (string, error) {
+	result, err := c.client.HGet(ctx, "power-manager", "state").Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return result, err
+}
+
+// GetHashField returns one field of a hash, or "" if it is unset.
+func (c *Client) GetHashField(ctx context.Context, key, field string) (string, error) {
+	result, err := c.client.HGet(ctx, key, field).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return result, err
+}
+
+// BootState is everything needed to make the first brightness decision.
+type BootState struct {
+	Illuminance
+	Enabled bool
+	Mode    string
+	Bias    int
+}
+
+// GetBootState fetches the illuminance, backlight-enabled override,
+// backlight mode and bias in a single round trip so the first hardware write at boot
+// is not delayed by three sequential requests.
+func (c *Client) GetBootState(ctx context.Context) (BootState, error) {
+	state := BootState{Enabled: true, Mode: "auto"}
+
 	pipe := c.client.Pipeline()
-	pipe.HSet(ctx, "dashboard", "backlight", value)
-	pipe.Publish(ctx, "dashboard", "backlight")
-	_, err := pipe.Exec(ctx)
+	luxCmd := pipe.HMGet(ctx, "dashboard", luxFields...)
+	enabledCmd := pipe.HGet(ctx, "dashboard", "backlight-enabled")
+	modeCmd := pipe.HGet(ctx, "settings", "dashboard.backlight-mode")
+	biasCmd := pipe.HGet(ctx, "settings", "dashboard.backlight-bias")
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return state, fmt.Errorf("failed to get boot state: %v", err)
+	}
+
+	il, err := parseIlluminance(luxCmd.Val())
+	if err != nil {
+		return state, err
+	}
+	state.Illuminance = il
+	if v, err := enabledCmd.Result(); err == nil {
+		state.Enabled = v == "true"
+	}
+	if v, err := modeCmd.Result(); err == nil {
+		state.Mode = v
+	}
+	if v, err := biasCmd.Int(); err == nil {
+		state.Bias = v
+	}
+	return state, nil
+}
+
+// CycleState is what each poll cycle reads: the illuminance and the
+// state that decides how it is applied.
+type CycleState struct {
+	Illuminance
+	Enabled      bool
+	Mode         string
+	VehicleState string
+	Backlight    int // dashboard backlight as stored, -1 if unset
+	Blinker      string
+	Speed        string // engine-ecu speed in km/h, "" if unset
+}
+
+// GetCycleState fetches the illuminance, backlight-enabled override,
+// backlight mode, vehicle and blinker state, speed and published backlight
+// in one round trip. Pub/sub still
+// delivers changes immediately; reading them here as well means a lost
+// notification is corrected on the next poll.
+func (c *Client) GetCycleState(ctx context.Context) (CycleState, error) {
+	state := CycleState{Enabled: true, Mode: "auto", Backlight: -1}
+
+	pipe := c.client.Pipeline()
+	luxCmd := pipe.HMGet(ctx, "dashboard", luxFields...)
+	enabledCmd := pipe.HGet(ctx, "dashboard", "backlight-enabled")
+	modeCmd := pipe.HGet(ctx, "settings", "dashboard.backlight-mode")
+	vehicleCmd := pipe.HGet(ctx, "vehicle", "state")
+	backlightCmd := pipe.HGet(ctx, "dashboard", "backlight")
+	blinkerCmd := pipe.HGet(ctx, "vehicle", "blinker:state")
+	speedCmd := pipe.HGet(ctx, "engine-ecu", "speed")
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return state, fmt.Errorf("failed to get illuminance value: %v", err)
+	}
+
+	il, err := parseIlluminance(luxCmd.Val())
 	if err != nil {
-		c.logger.Printf("Unable to set backlight value in Redis: %v", err)
+		return state, err
+	}
+	state.Illuminance = il
+	if !il.Present {
+		c.logger.Printf("Illuminance value not found in Redis")
+	}
+	if v, err := enabledCmd.Result(); err == nil {
+		state.Enabled = v == "true"
+	}
+	if v, err := modeCmd.Result(); err == nil {
+		state.Mode = v
+	}
+	if v, err := vehicleCmd.Result(); err == nil {
+		state.VehicleState = v
+	}
+	if v, err := backlightCmd.Int(); err == nil {
+		state.Backlight = v
+	}
+	if v, err := blinkerCmd.Result(); err == nil {
+		state.Blinker = v
+	}
+	if v, err := speedCmd.Result(); err == nil {
+		state.Speed = v
+	}
+	return state, nil
+}
+
+// CycleUpdate is what a poll cycle publishes. Negative fields are left
+// unchanged.
+type CycleUpdate struct {
+	Lux       float64
+	Backlight int
+	Percent   int // Backlight relative to max_brightness, 0-100
+}
+
+// PublishCycle writes and announces the cycle's lux and backlight values
+// in one round trip.
+func (c *Client) PublishCycle(ctx context.Context, u CycleUpdate) error {
+	pipe := c.client.Pipeline()
+	if u.Lux >= 0 {
+		pipe.HSet(ctx, "dashboard", "brightness", strconv.FormatFloat(u.Lux, 'f', 2, 64))
+		pipe.Publish(ctx, "dashboard", "brightness")
+	}
+	if u.Backlight >= 0 {
+		pipe.HSet(ctx, "dashboard", "backlight", u.Backlight, "backlight-percent", u.Percent)
+		pipe.Publish(ctx, "dashboard", "backlight")
+	}
+	if pipe.Len() == 0 {
+		return nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("cannot write to Redis: %v", err)
 	}
 	return nil
 }
 
-func (c *Client) SetIlluminanceValue(ctx context.Context, lux float64) error {
+// SetServiceState publishes whether auto-brightness is running ("online")
+// or has stopped ("offline") so the dashboard can tell the difference.
+func (c *Client) SetServiceState(ctx context.Context, state string) error {
 	pipe := c.client.Pipeline()
-	pipe.HSet(ctx, "dashboard", "brightness", fmt.Sprintf("%.2f", lux))
-	pipe.Publish(ctx, "dashboard", "brightness")
+	pipe.HSet(ctx, statusKey, "service", state)
+	pipe.Publish(ctx, statusKey, "service")
 	_, err := pipe.Exec(ctx)
 	return err
 }
 
-func (c *Client) GetBacklightEnabled(ctx context.Context) (bool, error) {
-	result, err := c.client.HGet(ctx, "dashboard", "backlight-enabled").Result()
+// GetServiceState returns the last published service state, or "" if the
+// service never ran.
+func (c *Client) GetServiceState(ctx context.Context) (string, error) {
+	result, err := c.client.HGet(ctx, statusKey, "service").Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return result, err
+}
+
+// SetActiveSource publishes which lux source currently drives the
+// backlight, so diagnostics can see fallbacks happen.
+func (c *Client) SetActiveSource(ctx context.Context, source string) error {
+	pipe := c.client.Pipeline()
+	pipe.HSet(ctx, statusKey, "source", source)
+	pipe.Publish(ctx, statusKey, "source")
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetSettingsState publishes the outcome of a settings push: probation,
+// accepted or rolled-back.
+func (c *Client) SetSettingsState(ctx context.Context, state string) error {
+	pipe := c.client.Pipeline()
+	pipe.HSet(ctx, statusKey, "settings", state)
+	pipe.Publish(ctx, statusKey, "settings")
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// rejectedSettingsKey holds the last settings profile rolled back after
+// probation.
+const rejectedSettingsKey = "backlight:settings-rejected"
+
+// SetSettings writes fields to the settings hash at key and announces
+// each on the channel of the same name, as the settings UI does.
+func (c *Client) SetSettings(ctx context.Context, key string, fields map[string]string) error {
+	pipe := c.client.Pipeline()
+	for name, value := range fields {
+		pipe.HSet(ctx, key, name, value)
+		pipe.Publish(ctx, key, name)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ClearSettings removes fields from the settings hash at key and
+// announces each on the channel of the same name.
+func (c *Client) ClearSettings(ctx context.Context, key string, fields ...string) error {
+	pipe := c.client.Pipeline()
+	pipe.HDel(ctx, key, fields...)
+	for _, name := range fields {
+		pipe.Publish(ctx, key, name)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetRejectedSettings returns the curve and manual levels of the last
+// profile rolled back, empty if there is none.
+func (c *Client) GetRejectedSettings(ctx context.Context) (curve, levels string, err error) {
+	fields, err := c.client.HGetAll(ctx, rejectedSettingsKey).Result()
+	if err != nil {
+		return "", "", err
+	}
+	return fields["curve"], fields["manual-levels"], nil
+}
+
+// SetRejectedSettings stores the profile just rolled back.
+func (c *Client) SetRejectedSettings(ctx context.Context, curve, levels string) error {
+	return c.client.HSet(ctx, rejectedSettingsKey, "curve", curve, "manual-levels", levels).Err()
+}
+
+// SetOscillation publishes the oscillation guard's state: normal, widen
+// or freeze.
+func (c *Client) SetOscillation(ctx context.Context, state string) error {
+	pipe := c.client.Pipeline()
+	pipe.HSet(ctx, statusKey, "oscillation", state)
+	pipe.Publish(ctx, statusKey, "oscillation")
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetConnection publishes the service's view of Redis and sensor health,
+// "ok", "degraded" or "down", with the time it took effect in Unix
+// milliseconds.
+func (c *Client) SetConnection(ctx context.Context, state string, since time.Time) error {
+	pipe := c.client.Pipeline()
+	pipe.HSet(ctx, statusKey, "connection", state, "connection-since", since.UnixMilli())
+	pipe.Publish(ctx, statusKey, "connection")
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// PublishRide appends a ride summary to stream, capped like the fault
+// stream.
+func (c *Client) PublishRide(ctx context.Context, stream string, fields map[string]interface{}) error {
+	return c.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: 1000,
+		Approx: true,
+		Values: fields,
+	}).Err()
+}
+
+// FaultEvent is a fault being raised or cleared, in the shape shared with
+// other librescoot services' fault reporting.
+type FaultEvent struct {
+	Code        int       `json:"code"`
+	Severity    string    `json:"severity"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	Time        time.Time `json:"time"`
+}
+
+// PublishFault records a fault change: active faults are kept in the
+// backlight:faults hash keyed by code, and every change is appended to the
+// shared fault stream for the dashboard and telemetry.
+func (c *Client) PublishFault(ctx context.Context, stream string, f FaultEvent) error {
+	code := strconv.Itoa(f.Code)
+	action := "clear"
+	if f.Active {
+		action = "set"
+	}
+
+	pipe := c.client.Pipeline()
+	if f.Active {
+		data, _ := json.Marshal(f)
+		pipe.HSet(ctx, faultsKey, code, string(data))
+	} else {
+		pipe.HDel(ctx, faultsKey, code)
+	}
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: 1000,
+		Approx: true,
+		Values: map[string]interface{}{
+			"group":       "dbc-backlight",
+			"code":        code,
+			"severity":    f.Severity,
+			"description": f.Description,
+			"action":      action,
+			"timestamp":   f.Time.UnixMilli(),
+		},
+	})
+	pipe.Publish(ctx, faultsKey, code)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetFaults returns the active faults keyed by code.
+func (c *Client) GetFaults(ctx context.Context) (map[string]string, error) {
+	return c.client.HGetAll(ctx, faultsKey).Result()
+}
+
+// PushEvent records a diagnostic event (e.g. a brightness transition) in a
+// capped list so that `dbc-backlight diag` can include recent history.
+func (c *Client) PushEvent(ctx context.Context, entry string) error {
+	return c.pushCapped(ctx, eventsKey, entry, maxEvents)
+}
+
+// PushError records a diagnostic error entry in a capped list.
+func (c *Client) PushError(ctx context.Context, entry string) error {
+	return c.pushCapped(ctx, errorsKey, entry, maxErrors)
+}
+
+func (c *Client) pushCapped(ctx context.Context, key, entry string, max int64) error {
+	pipe := c.client.Pipeline()
+	pipe.LPush(ctx, key, entry)
+	pipe.LTrim(ctx, key, 0, max-1)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetEvents returns the recorded diagnostic events, newest first.
+func (c *Client) GetEvents(ctx context.Context) ([]string, error) {
+	return c.client.LRange(ctx, eventsKey, 0, -1).Result()
+}
+
+// GetErrors returns the recorded diagnostic errors, newest first.
+func (c *Client) GetErrors(ctx context.Context) ([]string, error) {
+	return c.client.LRange(ctx, errorsKey, 0, -1).Result()
+}
+
+// SetStats writes the service counters to backlight:stats and refreshes
+// the hash TTL.
+func (c *Client) SetStats(ctx context.Context, fields map[string]interface{}, ttl time.Duration) error {
+	pipe := c.client.Pipeline()
+	pipe.HSet(ctx, statsKey, fields)
+	pipe.Expire(ctx, statsKey, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set stats: %v", err)
+	}
+	return nil
+}
+
+// PublishJSON stores data under key with a TTL and publishes it on the
+// channel of the same name.
+func (c *Client) PublishJSON(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	pipe := c.client.Pipeline()
+	pipe.Set(ctx, key, data, ttl)
+	pipe.Publish(ctx, key, data)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to publish %s: %v", key, err)
+	}
+	return nil
+}
+
+// SetAlive stores the current time in Unix milliseconds at key, expiring
+// after ttl.
+func (c *Client) SetAlive(ctx context.Context, key string, ttl time.Duration) error {
+	return c.client.Set(ctx, key, time.Now().UnixMilli(), ttl).Err()
+}
+
+// ClearAlive deletes the heartbeat key on a clean exit.
+func (c *Client) ClearAlive(ctx context.Context, key string) error {
+	return c.client.Del(ctx, key).Err()
+}
+
+// AddLuxHistogram adds seconds to the buckets of the lux histogram in the
+// hash at key.
+func (c *Client) AddLuxHistogram(ctx context.Context, key string, seconds map[string]float64) error {
+	pipe := c.client.Pipeline()
+	for field, secs := range seconds {
+		pipe.HIncrByFloat(ctx, key, field, secs)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update lux histogram: %v", err)
+	}
+	return nil
+}
+
+// GetLuxHistogram returns the buckets of the lux histogram at key.
+func (c *Client) GetLuxHistogram(ctx context.Context, key string) (map[string]string, error) {
+	return c.client.HGetAll(ctx, key).Result()
+}
+
+// SetCurveSuggestion stores a suggested -curve for review and publishes
+// it on the channel of the same name.
+func (c *Client) SetCurveSuggestion(ctx context.Context, curve string) error {
+	pipe := c.client.Pipeline()
+	pipe.Set(ctx, suggestionKey, curve, 0)
+	pipe.Publish(ctx, suggestionKey, curve)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetLearned returns the learned curve offsets and the curve they were
+// learned on, both empty when nothing has been learned.
+func (c *Client) GetLearned(ctx context.Context) (curve, offsets string, err error) {
+	fields, err := c.client.HGetAll(ctx, learnedKey).Result()
+	if err != nil {
+		return "", "", err
+	}
+	return fields["curve"], fields["offsets"], nil
+}
+
+// SetLearned stores learned curve offsets with the curve they apply to.
+func (c *Client) SetLearned(ctx context.Context, curve, offsets string) error {
+	return c.client.HSet(ctx, learnedKey, "curve", curve, "offsets", offsets).Err()
+}
+
+// ClearLearned forgets the learned curve offsets.
+func (c *Client) ClearLearned(ctx context.Context) error {
+	return c.client.Del(ctx, learnedKey).Err()
+}
+
+// SetTheme publishes the dark/light UI theme hint in the dashboard hash.
+func (c *Client) SetTheme(ctx context.Context, theme string) error {
+	pipe := c.client.Pipeline()
+	pipe.HSet(ctx, "dashboard", "theme", theme)
+	pipe.Publish(ctx, "dashboard", "theme")
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetCapabilities stores what the running service supports, for settings
+// UIs to render their options from.
+func (c *Client) SetCapabilities(ctx context.Context, data string) error {
+	return c.client.Set(ctx, capabilitiesKey, data, 0).Err()
+}
+
+// SetEffectiveConfig stores the running service's effective configuration.
+func (c *Client) SetEffectiveConfig(ctx context.Context, data string) error {
+	return c.client.Set(ctx, configKey, data, 0).Err()
+}
+
+func (c *Client) GetEffectiveConfig(ctx context.Context) (string, error) {
+	result, err := c.client.Get(ctx, configKey).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return result, err
+}
+
+func (c *Client) GetDashboard(ctx context.Context) (map[string]string, error) {
+	return c.client.HGetAll(ctx, "dashboard").Result()
+}
+
+// GetSettings returns all fields of a settings hash such as
+// settings:backlight.
+func (c *Client) GetSettings(ctx context.Context, key string) (map[string]string, error) {
+	return c.client.HGetAll(ctx, key).Result()
+}
+
+// GetManualBrightness returns the raw brightness written by the dashboard
+// UI for manual mode, or -1 when none is set.
+func (c *Client) GetManualBrightness(ctx context.Context) (int, error) {
+	result, err := c.client.HGet(ctx, "settings", "dashboard.backlight-brightness").Result()
 	if err != nil {
 		if err == redis.Nil {
-			return true, nil // default to enabled when key doesn't exist
+			return -1, nil
 		}
-		return true, err
+		return -1, err
 	}
-	return result == "true", nil
+	v, err := strconv.Atoi(result)
+	if err != nil || v < 0 {
+		return -1, fmt.Errorf("invalid manual brightness %q", result)
+	}
+	return v, nil
 }
 
-func (c *Client) GetBacklightMode(ctx context.Context) (string, error) {
-	result, err := c.client.HGet(ctx, "settings", "dashboard.backlight-mode").Result()
+// SetManualBrightness writes the manual-mode brightness setting.
+func (c *Client) SetManualBrightness(ctx context.Context, brightness int) error {
+	pipe := c.client.Pipeline()
+	pipe.HSet(ctx, "settings", "dashboard.backlight-brightness", brightness)
+	pipe.Publish(ctx, "settings", "dashboard.backlight-brightness")
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetBias returns the persisted user brightness bias (0 when unset).
+func (c *Client) GetBias(ctx context.Context) (int, error) {
+	result, err := c.client.HGet(ctx, "settings", "dashboard.backlight-bias").Result()
 	if err != nil {
 		if err == redis.Nil {
-			return "auto", nil // default to auto when key doesn't exist
+			return 0, nil
 		}
-		return "auto", err
+		return 0, err
 	}
-	return result, nil
+	v, err := strconv.Atoi(result)
+	if err != nil {
+		return 0, fmt.Errorf("invalid backlight bias %q", result)
+	}
+	return v, nil
+}
+
+// SetBias persists the user brightness bias in the settings hash, which the
+// settings service saves across reboots.
+func (c *Client) SetBias(ctx context.Context, bias int) error {
+	pipe := c.client.Pipeline()
+	pipe.HSet(ctx, "settings", "dashboard.backlight-bias", bias)
+	pipe.Publish(ctx, "settings", "dashboard.backlight-bias")
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetBacklightMode writes the backlight mode setting and notifies
+// subscribers, like any other settings-hash writer.
+func (c *Client) SetBacklightMode(ctx context.Context, mode string) error {
+	pipe := c.client.Pipeline()
+	pipe.HSet(ctx, "settings", "dashboard.backlight-mode", mode)
+	pipe.Publish(ctx, "settings", "dashboard.backlight-mode")
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// EnableKeyspaceEvents makes sure the server publishes keyspace
+// notifications for hash commands ("K" plus "h"), keeping any event
+// classes already enabled.
+func (c *Client) EnableKeyspaceEvents(ctx context.Context) error {
+	current, err := c.client.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return fmt.Errorf("failed to read notify-keyspace-events: %v", err)
+	}
+	flags := current["notify-keyspace-events"]
+	want := flags
+	if !strings.Contains(want, "K") {
+		want += "K"
+	}
+	if !strings.ContainsAny(want, "hA") {
+		want += "h"
+	}
+	if want == flags {
+		return nil
+	}
+	if err := c.client.ConfigSet(ctx, "notify-keyspace-events", want).Err(); err != nil {
+		return fmt.Errorf("failed to set notify-keyspace-events: %v", err)
+	}
+	return nil
+}
+
+// KeyspaceChannel returns the keyspace notification channel for key in
+// the client's database.
+func (c *Client) KeyspaceChannel(key string) string {
+	return fmt.Sprintf("__keyspace@%d__:%s", c.client.Options().DB, key)
+}
+
+// LuxCached reports whether illuminance reads are served by the client
+// tracking cache.
+func (c *Client) LuxCached() bool {
+	return c.cache != nil
 }
 
 func (c *Client) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
@@ -96,5 +790,8 @@ func (c *Client) Subscribe(ctx context.Context, channels ...string) *redis.PubSu
 }
 
 func (c *Client) Close() error {
+	if c.cache != nil {
+		c.cache.close()
+	}
 	return c.client.Close()
 }
@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// writeCommands get the write timeout; everything else counts as a read.
+var writeCommands = map[string]bool{
+	"hset": true, "hdel": true, "set": true, "expire": true,
+	"publish": true, "lpush": true, "ltrim": true, "xadd": true,
+	"xack": true, "xgroup": true,
+}
+
+// blockingCommands wait server-side for as long as the caller asked;
+// go-redis already extends their socket deadline by the block time.
+var blockingCommands = map[string]bool{
+	"xreadgroup": true,
+}
+
+// timeoutHook gives each command, and each pipeline as a whole, its own
+// deadline on top of whatever the caller's context allows.
+type timeoutHook struct {
+	read, write time.Duration
+}
+
+func (h timeoutHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h timeoutHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if blockingCommands[cmd.Name()] {
+			return next(ctx, cmd)
+		}
+		ctx, cancel := h.bound(ctx, h.timeoutFor(cmd))
+		defer cancel()
+		return next(ctx, cmd)
+	}
+}
+
+func (h timeoutHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		var d time.Duration
+		for _, cmd := range cmds {
+			d += h.timeoutFor(cmd)
+		}
+		ctx, cancel := h.bound(ctx, d)
+		defer cancel()
+		return next(ctx, cmds)
+	}
+}
+
+func (h timeoutHook) timeoutFor(cmd redis.Cmder) time.Duration {
+	if writeCommands[cmd.Name()] {
+		return h.write
+	}
+	return h.read
+}
+
+func (h timeoutHook) bound(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,50 @@
+package sensor
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// CANConfig describes where the lux value sits in a CAN frame.
+type CANConfig struct {
+	Interface string
+	ID        uint32 // frame ID; IDs above 0x7FF are matched as extended
+	Offset    int    // first data byte of the value
+	Size      int    // 1, 2 or 4 bytes, unsigned
+	BigEndian bool
+	Scale     float64 // lux per raw unit
+}
+
+func (c CANConfig) validate() error {
+	switch c.Size {
+	case 1, 2, 4:
+	default:
+		return fmt.Errorf("can-size must be 1, 2 or 4, got %d", c.Size)
+	}
+	if c.Offset < 0 || c.Offset+c.Size > 8 {
+		return fmt.Errorf("can-offset %d with size %d does not fit an 8 byte frame", c.Offset, c.Size)
+	}
+	return nil
+}
+
+// decode extracts the scaled lux value from a frame payload.
+func (c CANConfig) decode(data []byte) (float64, error) {
+	if c.Offset+c.Size > len(data) {
+		return 0, fmt.Errorf("frame has %d data bytes, need %d", len(data), c.Offset+c.Size)
+	}
+	b := data[c.Offset : c.Offset+c.Size]
+	var order binary.ByteOrder = binary.LittleEndian
+	if c.BigEndian {
+		order = binary.BigEndian
+	}
+	var raw uint32
+	switch c.Size {
+	case 1:
+		raw = uint32(b[0])
+	case 2:
+		raw = uint32(order.Uint16(b))
+	case 4:
+		raw = order.Uint32(b)
+	}
+	return float64(raw) * c.Scale, nil
+}
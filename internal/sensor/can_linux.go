@@ -0,0 +1,105 @@
+//go:build linux
+
+package sensor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// canFrameSize is sizeof(struct can_frame).
+const canFrameSize = 16
+
+// CAN is a lux source reading frames from a SocketCAN interface.
+type CAN struct {
+	*Latest
+	cfg    CANConfig
+	file   *os.File
+	logger *log.Logger
+}
+
+// OpenCAN binds a raw CAN socket filtered to cfg.ID and starts reading
+// frames in the background. Readings older than maxAge count as failures.
+func OpenCAN(cfg CANConfig, maxAge time.Duration, logger *log.Logger) (*CAN, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	ifi, err := net.InterfaceByName(cfg.Interface)
+	if err != nil {
+		return nil, fmt.Errorf("CAN interface %s: %v", cfg.Interface, err)
+	}
+
+	fd, err := unix.Socket(unix.AF_CAN, unix.SOCK_RAW, unix.CAN_RAW)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CAN socket: %v", err)
+	}
+
+	id, mask := cfg.ID, uint32(unix.CAN_SFF_MASK|unix.CAN_EFF_FLAG)
+	if cfg.ID > unix.CAN_SFF_MASK {
+		id |= unix.CAN_EFF_FLAG
+		mask = unix.CAN_EFF_MASK | unix.CAN_EFF_FLAG
+	}
+	filter := []unix.CanFilter{{Id: id, Mask: mask}}
+	if err := unix.SetsockoptCanRawFilter(fd, unix.SOL_CAN_RAW, unix.CAN_RAW_FILTER, filter); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set CAN filter: %v", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrCAN{Ifindex: ifi.Index}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind CAN socket to %s: %v", cfg.Interface, err)
+	}
+	// Non-blocking so the runtime poller can interrupt reads on Close.
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set CAN socket non-blocking: %v", err)
+	}
+
+	c := &CAN{
+		Latest: &Latest{Name: fmt.Sprintf("can:%s:%#x", cfg.Interface, cfg.ID), MaxAge: maxAge},
+		cfg:    cfg,
+		file:   os.NewFile(uintptr(fd), cfg.Interface),
+		logger: logger,
+	}
+	go c.read()
+	return c, nil
+}
+
+func (c *CAN) read() {
+	frame := make([]byte, canFrameSize)
+	for {
+		n, err := c.file.Read(frame)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				c.logger.Printf("CAN read on %s stopped: %v", c.cfg.Interface, err)
+			}
+			return
+		}
+		if n < canFrameSize {
+			continue
+		}
+		if binary.NativeEndian.Uint32(frame[0:4])&unix.CAN_EFF_MASK != c.cfg.ID {
+			continue
+		}
+		length := int(frame[4])
+		if length > 8 {
+			length = 8
+		}
+		lux, err := c.cfg.decode(frame[8 : 8+length])
+		if err != nil {
+			continue
+		}
+		c.Set(lux)
+	}
+}
+
+// Close stops reading and releases the socket.
+func (c *CAN) Close() error {
+	return c.file.Close()
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package sensor
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// CAN is a lux source reading frames from a SocketCAN interface.
+type CAN struct {
+	*Latest
+}
+
+// OpenCAN is only supported on Linux.
+func OpenCAN(cfg CANConfig, maxAge time.Duration, logger *log.Logger) (*CAN, error) {
+	return nil, fmt.Errorf("SocketCAN is only supported on Linux")
+}
+
+func (c *CAN) Close() error { return nil }
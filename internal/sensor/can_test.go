@@ -0,0 +1,38 @@
+package sensor
+
+import "testing"
+
+func TestCANDecode(t *testing.T) {
+	data := []byte{0xAA, 0x34, 0x12, 0x00}
+	tests := []struct {
+		cfg  CANConfig
+		want float64
+	}{
+		{CANConfig{Offset: 1, Size: 2, Scale: 1}, 0x1234},
+		{CANConfig{Offset: 1, Size: 2, BigEndian: true, Scale: 1}, 0x3412},
+		{CANConfig{Offset: 0, Size: 1, Scale: 0.5}, 85},
+		{CANConfig{Offset: 0, Size: 4, Scale: 1}, 0x1234AA},
+	}
+	for _, tt := range tests {
+		got, err := tt.cfg.decode(data)
+		if err != nil || got != tt.want {
+			t.Errorf("%+v: got %v, %v, want %v", tt.cfg, got, err, tt.want)
+		}
+	}
+}
+
+func TestCANDecodeShortFrame(t *testing.T) {
+	cfg := CANConfig{Offset: 2, Size: 2, Scale: 1}
+	if _, err := cfg.decode([]byte{1, 2, 3}); err == nil {
+		t.Error("expected error for short frame")
+	}
+}
+
+func TestCANConfigValidate(t *testing.T) {
+	if err := (CANConfig{Offset: 7, Size: 2}).validate(); err == nil {
+		t.Error("expected error for value past the frame end")
+	}
+	if err := (CANConfig{Size: 3}).validate(); err == nil {
+		t.Error("expected error for size 3")
+	}
+}
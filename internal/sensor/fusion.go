@@ -0,0 +1,94 @@
+package sensor
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Fusion modes.
+const (
+	FuseMax     = "max"     // the brighter reading; a shadowed sensor reads low
+	FuseAverage = "average" // Weight*A + (1-Weight)*B
+	FuseReject  = "reject"  // the mean while both agree, else the one closer to the last result
+)
+
+// Fusion combines two sensors, such as ALS devices on either side of the
+// cockpit, into one reading. If one sensor fails the other is used alone.
+type Fusion struct {
+	A, B     Source
+	Mode     string
+	Weight   float64 // weight of A in FuseAverage, 0..1
+	MaxRatio float64 // readings further apart than this disagree in FuseReject
+
+	last float64
+	has  bool
+}
+
+// NewFusion returns a Fusion of a and b, validating the mode and its
+// parameters.
+func NewFusion(a, b Source, mode string, weight, maxRatio float64) (*Fusion, error) {
+	switch mode {
+	case FuseMax:
+	case FuseAverage:
+		if weight < 0 || weight > 1 {
+			return nil, fmt.Errorf("weight must be between 0 and 1, got %v", weight)
+		}
+	case FuseReject:
+		if maxRatio <= 1 {
+			return nil, fmt.Errorf("max ratio must be above 1, got %v", maxRatio)
+		}
+	default:
+		return nil, fmt.Errorf("unknown fusion mode %q (expected max, average or reject)", mode)
+	}
+	return &Fusion{A: a, B: b, Mode: mode, Weight: weight, MaxRatio: maxRatio}, nil
+}
+
+func (f *Fusion) ReadLux(ctx context.Context) (float64, error) {
+	a, errA := f.A.ReadLux(ctx)
+	b, errB := f.B.ReadLux(ctx)
+	var lux float64
+	switch {
+	case errA != nil && errB != nil:
+		return 0, fmt.Errorf("%s: %v; %s: %v", f.A, errA, f.B, errB)
+	case errA != nil:
+		lux = b
+	case errB != nil:
+		lux = a
+	default:
+		lux = f.fuse(a, b)
+	}
+	f.last, f.has = lux, true
+	return lux, nil
+}
+
+func (f *Fusion) fuse(a, b float64) float64 {
+	switch f.Mode {
+	case FuseAverage:
+		return f.Weight*a + (1-f.Weight)*b
+	case FuseReject:
+		if ratio(a, b) <= f.MaxRatio {
+			return (a + b) / 2
+		}
+		if !f.has {
+			return math.Max(a, b)
+		}
+		// A hand over one sensor makes it jump while the other stays
+		// consistent with what both read before.
+		if ratio(a, f.last) <= ratio(b, f.last) {
+			return a
+		}
+		return b
+	}
+	return math.Max(a, b)
+}
+
+// ratio returns how many times larger the larger of a and b is, offset by
+// 1 lux so that readings near zero don't count as far apart.
+func ratio(a, b float64) float64 {
+	return (math.Max(a, b) + 1) / (math.Min(a, b) + 1)
+}
+
+func (f *Fusion) String() string {
+	return fmt.Sprintf("%s of %s and %s", f.Mode, f.A, f.B)
+}
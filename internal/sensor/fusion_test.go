@@ -0,0 +1,69 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFusionModes(t *testing.T) {
+	tests := []struct {
+		mode string
+		a, b float64
+		want float64
+	}{
+		{FuseMax, 30, 4, 30},
+		{FuseAverage, 30, 10, 25}, // weight 0.75
+		{FuseReject, 20, 24, 22},  // agree: mean
+		{FuseReject, 30, 4, 30},   // disagree, no history: brighter
+	}
+	for _, tt := range tests {
+		f, err := NewFusion(&sequence{values: []float64{tt.a}}, &sequence{values: []float64{tt.b}}, tt.mode, 0.75, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := f.ReadLux(context.Background()); err != nil || got != tt.want {
+			t.Errorf("%s(%v, %v) = %v, %v, want %v", tt.mode, tt.a, tt.b, got, err, tt.want)
+		}
+	}
+}
+
+func TestFusionRejectKeepsConsistentSensor(t *testing.T) {
+	// Both sensors agree on a dim street, then a hand shadows A.
+	a := &sequence{values: []float64{8, 1}}
+	b := &sequence{values: []float64{9, 9}}
+	f, _ := NewFusion(a, b, FuseReject, 0.5, 3)
+	f.ReadLux(context.Background())
+	if got, _ := f.ReadLux(context.Background()); got != 9 {
+		t.Errorf("got %v, want 9 from the unshadowed sensor", got)
+	}
+}
+
+func TestFusionFallsBackToWorkingSensor(t *testing.T) {
+	a := &sequence{values: []float64{0}, errs: []error{errors.New("gone")}}
+	b := &sequence{values: []float64{12}}
+	f, _ := NewFusion(a, b, FuseAverage, 0.5, 0)
+	if got, err := f.ReadLux(context.Background()); err != nil || got != 12 {
+		t.Errorf("got %v, %v, want 12", got, err)
+	}
+	b.errs = []error{errors.New("gone")}
+	if _, err := f.ReadLux(context.Background()); err == nil {
+		t.Error("want an error with both sensors failing")
+	}
+}
+
+func TestNewFusionValidates(t *testing.T) {
+	src := &sequence{values: []float64{1}}
+	for _, tt := range []struct {
+		mode             string
+		weight, maxRatio float64
+	}{
+		{"min", 0.5, 3},
+		{FuseAverage, 1.5, 3},
+		{FuseReject, 0.5, 1},
+	} {
+		if _, err := NewFusion(src, src, tt.mode, tt.weight, tt.maxRatio); err == nil {
+			t.Errorf("NewFusion(%q, %v, %v) succeeded, want an error", tt.mode, tt.weight, tt.maxRatio)
+		}
+	}
+}
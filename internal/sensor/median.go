@@ -0,0 +1,58 @@
+package sensor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Median takes Samples readings from Source, Interval apart, and returns
+// their median. Sampling a few milliseconds apart within one poll breaks up
+// the aliasing between a slow poll and PWM-dimmed street lighting, without
+// the delay a longer smoothing window would add.
+type Median struct {
+	Source   Source
+	Samples  int
+	Interval time.Duration
+
+	buf []float64
+}
+
+// ReadLux returns the median of the readings that succeeded, or the last
+// error if none did.
+func (m *Median) ReadLux(ctx context.Context) (float64, error) {
+	m.buf = m.buf[:0]
+	var lastErr error
+	for i := 0; i < m.Samples; i++ {
+		if i > 0 && m.Interval > 0 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(m.Interval):
+			}
+		}
+		lux, err := m.Source.ReadLux(ctx)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		m.buf = append(m.buf, lux)
+	}
+	if len(m.buf) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no samples")
+		}
+		return 0, lastErr
+	}
+	sort.Float64s(m.buf)
+	n := len(m.buf)
+	if n%2 == 1 {
+		return m.buf[n/2], nil
+	}
+	return (m.buf[n/2-1] + m.buf[n/2]) / 2, nil
+}
+
+func (m *Median) String() string {
+	return fmt.Sprintf("%s (median of %d)", m.Source, m.Samples)
+}
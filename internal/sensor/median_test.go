@@ -0,0 +1,55 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// sequence returns its readings in turn, failing where errs is set.
+type sequence struct {
+	values []float64
+	errs   []error
+	i      int
+}
+
+func (s *sequence) ReadLux(ctx context.Context) (float64, error) {
+	i := s.i % len(s.values)
+	s.i++
+	if s.errs != nil && s.errs[i] != nil {
+		return 0, s.errs[i]
+	}
+	return s.values[i], nil
+}
+
+func (s *sequence) String() string { return "sequence" }
+
+func TestMedianRejectsFlickerSamples(t *testing.T) {
+	// A pulsed streetlight caught at its peak once in five samples.
+	m := &Median{Source: &sequence{values: []float64{3, 3.2, 40, 2.9, 3.1}}, Samples: 5}
+	got, err := m.ReadLux(context.Background())
+	if err != nil || got != 3.1 {
+		t.Errorf("got %v, %v, want 3.1", got, err)
+	}
+}
+
+func TestMedianEvenSampleCount(t *testing.T) {
+	m := &Median{Source: &sequence{values: []float64{1, 4, 2, 3}}, Samples: 4}
+	if got, _ := m.ReadLux(context.Background()); got != 2.5 {
+		t.Errorf("got %v, want 2.5", got)
+	}
+}
+
+func TestMedianSkipsFailedReads(t *testing.T) {
+	failed := errors.New("busy")
+	src := &sequence{values: []float64{5, 0, 7}, errs: []error{nil, failed, nil}}
+	m := &Median{Source: src, Samples: 3}
+	if got, err := m.ReadLux(context.Background()); err != nil || got != 6 {
+		t.Errorf("got %v, %v, want 6", got, err)
+	}
+
+	m = &Median{Source: &sequence{values: []float64{0}, errs: []error{failed}}, Samples: 3}
+	if _, err := m.ReadLux(context.Background()); err != failed {
+		t.Errorf("expected the read error, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package sensor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority reads from the first healthy of several sources, listed most
+// preferred first. A source whose read fails is unhealthy and skipped for
+// Retry, so a dead sensor costs one failed read per Retry rather than one
+// per poll; after that it is tried again and takes over if it recovered.
+type Priority struct {
+	Sources []Source
+	Retry   time.Duration
+
+	mu      sync.Mutex
+	now     func() time.Time
+	skip    []time.Time // per source: unhealthy until
+	lastErr []error
+	active  int // index of the source of the last reading (-1 = none)
+}
+
+// NewPriority returns a Priority over sources in the given order.
+func NewPriority(retry time.Duration, sources ...Source) *Priority {
+	return &Priority{
+		Sources: sources,
+		Retry:   retry,
+		now:     time.Now,
+		skip:    make([]time.Time, len(sources)),
+		lastErr: make([]error, len(sources)),
+		active:  -1,
+	}
+}
+
+// ReadLux returns the reading of the first healthy source. When every
+// source fails or is being skipped, the error lists why each one is down.
+func (p *Priority) ReadLux(ctx context.Context) (float64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	for i, src := range p.Sources {
+		if now.Before(p.skip[i]) {
+			continue
+		}
+		lux, err := src.ReadLux(ctx)
+		if err != nil {
+			p.lastErr[i] = err
+			p.skip[i] = now.Add(p.Retry)
+			continue
+		}
+		p.lastErr[i] = nil
+		p.active = i
+		return lux, nil
+	}
+	p.active = -1
+	reasons := make([]string, len(p.Sources))
+	for i, src := range p.Sources {
+		reasons[i] = fmt.Sprintf("%s: %v", src, p.lastErr[i])
+	}
+	return 0, fmt.Errorf("no healthy lux source (%s)", strings.Join(reasons, "; "))
+}
+
+// Active returns the source the last reading came from, or nil if the
+// last read failed.
+func (p *Priority) Active() Source {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active < 0 {
+		return nil
+	}
+	return p.Sources[p.active]
+}
+
+func (p *Priority) String() string {
+	names := make([]string, len(p.Sources))
+	for i, src := range p.Sources {
+		names[i] = src.String()
+	}
+	return strings.Join(names, " > ")
+}
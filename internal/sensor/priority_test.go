@@ -0,0 +1,76 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// switchable is a source whose health the test controls.
+type switchable struct {
+	name  string
+	lux   float64
+	down  bool
+	reads int
+}
+
+func (s *switchable) ReadLux(ctx context.Context) (float64, error) {
+	s.reads++
+	if s.down {
+		return 0, errors.New("down")
+	}
+	return s.lux, nil
+}
+
+func (s *switchable) String() string { return s.name }
+
+func TestPriorityPrefersFirstHealthySource(t *testing.T) {
+	can := &switchable{name: "can", lux: 10}
+	redis := &switchable{name: "redis", lux: 20}
+	p := NewPriority(time.Second, can, redis)
+
+	if got, err := p.ReadLux(context.Background()); err != nil || got != 10 || p.Active() != can {
+		t.Fatalf("got %v, %v from %v, want 10 from can", got, err, p.Active())
+	}
+	can.down = true
+	if got, err := p.ReadLux(context.Background()); err != nil || got != 20 || p.Active() != redis {
+		t.Fatalf("got %v, %v from %v, want 20 from redis", got, err, p.Active())
+	}
+}
+
+func TestPrioritySkipsUnhealthySourceUntilRetry(t *testing.T) {
+	now := time.Unix(0, 0)
+	can := &switchable{name: "can", lux: 10, down: true}
+	redis := &switchable{name: "redis", lux: 20}
+	p := NewPriority(5*time.Second, can, redis)
+	p.now = func() time.Time { return now }
+
+	p.ReadLux(context.Background())
+	can.down = false
+	for i := 0; i < 4; i++ {
+		now = now.Add(time.Second)
+		if got, _ := p.ReadLux(context.Background()); got != 20 {
+			t.Fatalf("read %d: got %v, want 20 while can is skipped", i, got)
+		}
+	}
+	if can.reads != 1 {
+		t.Errorf("can read %d times while skipped, want 1", can.reads)
+	}
+	now = now.Add(time.Second)
+	if got, _ := p.ReadLux(context.Background()); got != 10 || p.Active() != can {
+		t.Errorf("got %v from %v after retry, want 10 from can", got, p.Active())
+	}
+}
+
+func TestPriorityAllDown(t *testing.T) {
+	p := NewPriority(time.Second, &switchable{name: "can", down: true}, &switchable{name: "redis", down: true})
+	_, err := p.ReadLux(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "can: down") || !strings.Contains(err.Error(), "redis: down") {
+		t.Errorf("got %v, want both sources listed", err)
+	}
+	if p.Active() != nil {
+		t.Errorf("active = %v, want none", p.Active())
+	}
+}
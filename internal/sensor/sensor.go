@@ -0,0 +1,68 @@
+// Package sensor provides ambient light sources other than the Redis
+// dashboard hash.
+package sensor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source returns the current ambient light in lux.
+type Source interface {
+	ReadLux(ctx context.Context) (float64, error)
+	String() string
+}
+
+// File reads lux from a sysfs attribute such as an IIO
+// in_illuminance_input.
+type File struct {
+	Path string
+}
+
+func (f *File) ReadLux(ctx context.Context) (float64, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read sensor: %v", err)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+}
+
+func (f *File) String() string { return f.Path }
+
+// Latest is a source fed by pushed readings (MQTT, CAN). ReadLux returns
+// the most recent value, or an error once it is older than MaxAge.
+type Latest struct {
+	Name   string
+	MaxAge time.Duration
+
+	mu  sync.Mutex
+	lux float64
+	at  time.Time
+}
+
+// Set records a new reading.
+func (l *Latest) Set(lux float64) {
+	l.mu.Lock()
+	l.lux = lux
+	l.at = time.Now()
+	l.mu.Unlock()
+}
+
+func (l *Latest) ReadLux(ctx context.Context) (float64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.at.IsZero() {
+		return 0, fmt.Errorf("no reading from %s yet", l.Name)
+	}
+	if l.MaxAge > 0 && time.Since(l.at) > l.MaxAge {
+		return 0, fmt.Errorf("last reading from %s is %v old", l.Name, time.Since(l.at).Round(time.Second))
+	}
+	return l.lux, nil
+}
+
+func (l *Latest) String() string { return l.Name }
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// alarmState is the attention pulse driven by the Redis alarm flag. Owned
+// by the monitor goroutine.
+type alarmState struct {
+	active bool
+	high   bool // whether the last pulse write was the high level
+	ticker *time.Ticker
+}
+
+// pulseC returns the pulse ticker's channel, or nil while no alarm is
+// active so the monitor's select ignores it.
+func (a *alarmState) pulseC() <-chan time.Time {
+	if a.ticker == nil {
+		return nil
+	}
+	return a.ticker.C
+}
+
+// alarmActive reports whether value is one of -alarm-values.
+func (s *Service) alarmActive(value string) bool {
+	for _, v := range strings.Fields(s.Config.AlarmValues) {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshAlarm re-reads the alarm flag and starts or stops pulsing.
+func (s *Service) refreshAlarm(ctx context.Context) {
+	if s.Config.AlarmKey == "" {
+		return
+	}
+	value, err := s.Redis.GetHashField(ctx, s.Config.AlarmKey, s.Config.AlarmField)
+	if err != nil {
+		s.recordError(ctx, "Failed to read alarm state: %v", err)
+		return
+	}
+	active := s.alarmActive(value)
+	if active == s.alarm.active {
+		return
+	}
+
+	if active {
+		s.alarm.active = true
+		s.alarm.high = false
+		s.alarm.ticker = time.NewTicker(s.Config.AlarmPeriod / 2)
+		s.recordEvent(ctx, "alarm", "Alarm %s: pulsing backlight", value)
+		s.pulseAlarm(ctx)
+		return
+	}
+
+	s.alarm.ticker.Stop()
+	s.alarm = alarmState{}
+	s.recordEvent(ctx, "alarm", "Alarm cleared")
+	if s.backlightDisabled {
+		err := s.Backlight.ForceOff()
+		s.noteWrite(ctx, err)
+		if err != nil {
+			s.recordError(ctx, "Failed to force backlight off: %v", err)
+		}
+		return
+	}
+	s.adjustBacklight(ctx)
+}
+
+// pulseAlarm toggles between -alarm-low and -alarm-high. The pulse
+// ignores backlight-enabled: the point is to be seen.
+func (s *Service) pulseAlarm(ctx context.Context) {
+	s.alarm.high = !s.alarm.high
+	value := s.Config.AlarmLow
+	if s.alarm.high {
+		value = s.Config.AlarmHigh
+		if value <= 0 {
+			value = s.maxBrightness()
+		}
+	}
+	err := s.Backlight.SetOutput(value)
+	s.noteWrite(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to pulse backlight: %v", err)
+	}
+	s.syncFollowers(ctx)
+	s.notifyStatus()
+}
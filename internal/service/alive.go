@@ -0,0 +1,13 @@
+package service
+
+import "context"
+
+// beat refreshes -alive-key. It runs on the control loop, so the key
+// expires when the loop hangs even though the process is still up.
+func (s *Service) beat(ctx context.Context) {
+	err := s.Redis.SetAlive(ctx, s.Config.AliveKey, s.Config.AliveTTL)
+	s.noteRedis(ctx, err)
+	if err != nil && s.Config.Debug {
+		s.Logger.Printf("Debug: Failed to refresh %s: %v", s.Config.AliveKey, err)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+func (s *Service) nudgeBias(ctx context.Context, delta int) error {
+	return s.storeBias(ctx, s.Backlight.Bias()+delta)
+}
+
+// storeBias clamps, applies and persists the bias. The settings write
+// echoes back on the settings channel; applyBias ignores the repeat.
+func (s *Service) storeBias(ctx context.Context, bias int) error {
+	bias = s.clampBias(bias)
+	s.applyBias(ctx, bias)
+	if err := s.Redis.SetBias(ctx, bias); err != nil {
+		return fmt.Errorf("failed to persist bias: %v", err)
+	}
+	return nil
+}
+
+func (s *Service) clampBias(bias int) int {
+	limit := s.Config.BiasLimit
+	if bias > limit {
+		return limit
+	}
+	if bias < -limit {
+		return -limit
+	}
+	return bias
+}
+
+func (s *Service) applyBias(ctx context.Context, bias int) {
+	if bias == s.Backlight.Bias() {
+		return
+	}
+	s.Backlight.SetBias(bias)
+	s.recordEvent(ctx, "bias", "Brightness bias: %+d", bias)
+}
+
+// refreshBias re-reads the persisted bias from the settings hash.
+func (s *Service) refreshBias(ctx context.Context) {
+	bias, err := s.Redis.GetBias(ctx)
+	if err != nil {
+		s.recordError(ctx, "Failed to read backlight bias: %v", err)
+		return
+	}
+	s.applyBias(ctx, s.clampBias(bias))
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// The turn indicators reflect off the windscreen and pulse the ambient
+// light sensor, which can bump the level at night. While they flash, and
+// for -blinker-holdoff after, readings above the current estimate are held
+// at the estimate; drops still pass so entering a tunnel isn't delayed.
+
+// applyBlinker records the blinker state from the vehicle hash.
+func (s *Service) applyBlinker(state string) {
+	blinking := state != "" && state != "off"
+	if s.blinking && !blinking {
+		s.blinkerOffAt = time.Now()
+	}
+	s.blinking = blinking
+}
+
+// refreshBlinker re-reads the blinker state.
+func (s *Service) refreshBlinker(ctx context.Context) {
+	state, err := s.Redis.GetBlinkerState(ctx)
+	if err != nil {
+		s.recordError(ctx, "Failed to read blinker state: %v", err)
+		return
+	}
+	s.applyBlinker(state)
+}
+
+// maskBlinker clamps lux to the smoothed estimate while the blinkers may
+// be lighting the sensor.
+func (s *Service) maskBlinker(lux float64) float64 {
+	if !s.Config.BlinkerMask {
+		return lux
+	}
+	if !s.blinking && time.Since(s.blinkerOffAt) >= s.Config.BlinkerHoldoff {
+		return lux
+	}
+	if est := s.Backlight.SmoothedLux(); est >= 0 && lux > est {
+		return est
+	}
+	return lux
+}
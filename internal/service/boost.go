@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// interactionBoost lifts a dark parked panel to -interaction-level for
+// -interaction-boost after a button press, a turn signal or the
+// kickstand, so a glance at the parked scooter shows the dashboard.
+// Afterwards the output ramps back to whatever it would otherwise be.
+type interactionBoost struct {
+	until    time.Time // zero = not boosted
+	decaying bool      // the boost ended, the output is ramping back
+}
+
+// boostOnInteraction starts a boost, or extends the running one. It only
+// starts one while parked with the output at the lowest manual level or
+// below, or still on its way back from the last boost.
+func (s *Service) boostOnInteraction(ctx context.Context, reason string) {
+	if s.Config.InteractionBoost <= 0 || !s.parkedStates[s.vehicleState] {
+		return
+	}
+	level, ok := s.manualLevels[s.Config.InteractionLevel]
+	if !ok {
+		return
+	}
+	until := time.Now().Add(s.Config.InteractionBoost)
+	if !s.boost.until.IsZero() {
+		s.boost.until = until
+		return
+	}
+	if names := s.sortedLevels(); !s.boost.decaying && len(names) > 0 && s.Backlight.Output() > s.manualLevels[names[0]] {
+		return
+	}
+	if s.Backlight.Output() >= level {
+		return
+	}
+	s.boost = interactionBoost{until: until}
+	s.recordEvent(ctx, "boost", "Boosting to %s for %v (%s)", s.Config.InteractionLevel, s.Config.InteractionBoost, reason)
+	s.adjustBacklight(ctx)
+}
+
+// boostTarget returns the brightness to hold while boosted, or false
+// once the boost has ended or the scooter is no longer parked.
+func (s *Service) boostTarget(ctx context.Context) (int, bool) {
+	if s.boost.until.IsZero() {
+		if s.boost.decaying && s.Backlight.Output() == s.Backlight.Target() {
+			s.boost.decaying = false
+		}
+		return 0, false
+	}
+	level, ok := s.manualLevels[s.Config.InteractionLevel]
+	if !ok || !s.parkedStates[s.vehicleState] || time.Now().After(s.boost.until) {
+		s.boost = interactionBoost{decaying: true}
+		s.recordEvent(ctx, "boost", "Boost ended, returning to the normal brightness")
+		return 0, false
+	}
+	return level, true
+}
+
+// boosting reports whether a boost, or the ramp back from one, needs the
+// loop to keep polling even where parked polling is slower or paused.
+func (s *Service) boosting() bool {
+	return !s.boost.until.IsZero() || s.boost.decaying
+}
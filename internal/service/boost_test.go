@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBoostTarget(t *testing.T) {
+	s := testService(t, "-interaction-boost", "20s", "-manual-levels", "low:1000 medium:4000 high:8000")
+	s.vehicleState = "parked"
+	ctx := context.Background()
+
+	if _, ok := s.boostTarget(ctx); ok || s.boosting() {
+		t.Fatal("expected no boost to start with")
+	}
+
+	s.boost = interactionBoost{until: time.Now().Add(10 * time.Second)}
+	if level, ok := s.boostTarget(ctx); level != 4000 || !ok {
+		t.Errorf("expected the interaction level while boosted, got %d, %v", level, ok)
+	}
+
+	s.boost.until = time.Now().Add(-time.Second)
+	if _, ok := s.boostTarget(ctx); ok || !s.boost.decaying || !s.boosting() {
+		t.Errorf("expected an expired boost to decay, got %+v", s.boost)
+	}
+
+	s.Backlight.ApplyManual(1000)
+	if _, ok := s.boostTarget(ctx); ok || s.boost.decaying || s.boosting() {
+		t.Errorf("expected the decay to end once the output settled, got %+v", s.boost)
+	}
+
+	s.boost = interactionBoost{until: time.Now().Add(10 * time.Second)}
+	s.vehicleState = "ready-to-drive"
+	if _, ok := s.boostTarget(ctx); ok || !s.boost.decaying {
+		t.Errorf("expected leaving the parked states to end the boost, got %+v", s.boost)
+	}
+}
+
+func TestBoostOnInteractionGuards(t *testing.T) {
+	s := testService(t, "-interaction-boost", "20s", "-manual-levels", "low:1000 medium:4000 high:8000")
+	ctx := context.Background()
+
+	s.vehicleState = "ready-to-drive"
+	s.Backlight.ApplyManual(0)
+	s.boostOnInteraction(ctx, "test")
+	if !s.boost.until.IsZero() {
+		t.Error("expected no boost while riding")
+	}
+
+	s.vehicleState = "parked"
+	s.Backlight.ApplyManual(8000)
+	s.boostOnInteraction(ctx, "test")
+	if !s.boost.until.IsZero() {
+		t.Error("expected no boost above the lowest level")
+	}
+
+	until := time.Now().Add(time.Second)
+	s.boost = interactionBoost{until: until}
+	s.boostOnInteraction(ctx, "test")
+	if !s.boost.until.After(until) {
+		t.Error("expected a running boost to be extended")
+	}
+}
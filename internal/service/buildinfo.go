@@ -0,0 +1,22 @@
+package service
+
+import "strings"
+
+// BuildInfo identifies the running binary. The fields are injected at
+// link time; see the Makefile.
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"build_date,omitempty"`
+}
+
+func (b BuildInfo) String() string {
+	parts := []string{b.Version}
+	if b.Commit != "" {
+		parts = append(parts, "commit "+b.Commit)
+	}
+	if b.Date != "" {
+		parts = append(parts, "built "+b.Date)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,25 @@
+package service
+
+import "context"
+
+// tickBurnIn lets -burnin-level protection act between control writes,
+// and logs when a static level starts or stops being reduced.
+func (s *Service) tickBurnIn(ctx context.Context) {
+	if s.sleeping || s.hibernating {
+		return
+	}
+	err := s.burnIn.Tick()
+	s.noteWrite(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to write burn-in adjustment: %v", err)
+		return
+	}
+	if reduced := s.burnIn.Reduced(); reduced != s.burnInReduced {
+		s.burnInReduced = reduced
+		if reduced {
+			s.recordEvent(ctx, "burnin", "Brightness %d unchanged for %v, reducing by %.0f%%", s.Backlight.Output(), s.Config.BurnInAfter, s.Config.BurnInReduce*100)
+		} else {
+			s.Logger.Printf("Burn-in reduction lifted")
+		}
+	}
+}
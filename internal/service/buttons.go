@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// buttonCombo is a set of buttons that must all be held for the long-press
+// duration to trigger an action.
+type buttonCombo []string
+
+// parseCombo parses "name" or "name1+name2"; empty disables the action.
+func parseCombo(s string) buttonCombo {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "+")
+}
+
+// buttonWatcher turns press/release events from the buttons channel into
+// long-press actions. It is only used from the subscriber goroutine; the
+// actions themselves run on the monitor goroutine via Service.do.
+type buttonWatcher struct {
+	held    map[string]bool
+	combos  []buttonCombo
+	actions []func()
+	timers  []*time.Timer
+	hold    time.Duration
+}
+
+func newButtonWatcher(hold time.Duration) *buttonWatcher {
+	return &buttonWatcher{held: make(map[string]bool), hold: hold}
+}
+
+func (w *buttonWatcher) add(combo buttonCombo, action func()) {
+	if len(combo) == 0 {
+		return
+	}
+	w.combos = append(w.combos, combo)
+	w.actions = append(w.actions, action)
+	w.timers = append(w.timers, nil)
+}
+
+func (w *buttonWatcher) enabled() bool {
+	return len(w.combos) > 0
+}
+
+// handle processes a "name:on" / "name:off" event. Names may contain
+// colons themselves (e.g. "brake:left:on").
+func (w *buttonWatcher) handle(event string) {
+	i := strings.LastIndex(event, ":")
+	if i < 0 {
+		return
+	}
+	name, state := event[:i], event[i+1:]
+	switch state {
+	case "on":
+		w.held[name] = true
+	case "off":
+		delete(w.held, name)
+	default:
+		return
+	}
+
+	for i, combo := range w.combos {
+		if w.allHeld(combo) {
+			if w.timers[i] == nil {
+				w.timers[i] = time.AfterFunc(w.hold, w.actions[i])
+			}
+		} else if w.timers[i] != nil {
+			w.timers[i].Stop()
+			w.timers[i] = nil
+		}
+	}
+}
+
+func (w *buttonWatcher) allHeld(combo buttonCombo) bool {
+	for _, name := range combo {
+		if !w.held[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// stepLevel overrides the brightness with the next manual level above
+// (dir > 0) or below (dir < 0) the current output, for -step-timeout.
+func (s *Service) stepLevel(ctx context.Context, dir int) error {
+	names := s.sortedLevels()
+	current := s.Backlight.Target()
+	pick := ""
+	if dir > 0 {
+		for _, name := range names {
+			if s.manualLevels[name] > current {
+				pick = name
+				break
+			}
+		}
+	} else {
+		for i := len(names) - 1; i >= 0; i-- {
+			if s.manualLevels[names[i]] < current {
+				pick = names[i]
+				break
+			}
+		}
+	}
+	if pick == "" {
+		return fmt.Errorf("no level beyond brightness %d", current)
+	}
+
+	s.setLevelOverride(ctx, pick, s.Config.StepTimeout)
+	return nil
+}
+
+// buttonStep returns a long-press action stepping the level in dir.
+func (s *Service) buttonStep(ctx context.Context, dir int) func() {
+	return func() {
+		s.do(ctx, func(ctx context.Context) {
+			if err := s.stepLevel(ctx, dir); err != nil && s.Config.Debug {
+				s.Logger.Printf("Debug: Button step ignored: %v", err)
+			}
+		})
+	}
+}
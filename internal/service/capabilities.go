@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// capabilities describes what the running service supports, so the
+// dashboard settings UI doesn't have to hard-code the levels.
+type capabilities struct {
+	BuildInfo
+	Levels        []capabilityLevel `json:"levels"`
+	Modes         []string          `json:"modes"`
+	MaxBrightness int               `json:"max_brightness"`
+	Curve         []backlight.Point `json:"curve"`
+	Profile       capabilityProfile `json:"profile"`
+}
+
+// capabilityProfile is the active curve and manual-levels profile as
+// configured: from the flags or pushed through -settings-key.
+type capabilityProfile struct {
+	Source       string `json:"source"` // "flags" or "settings"
+	Curve        string `json:"curve"`
+	ManualLevels string `json:"manual_levels"`
+	Probation    bool   `json:"probation"`
+}
+
+type capabilityLevel struct {
+	Name       string `json:"name"`
+	Brightness int    `json:"brightness"`
+}
+
+// sortedLevels returns the manual level names from dimmest to brightest.
+func (s *Service) sortedLevels() []string {
+	names := make([]string, 0, len(s.manualLevels))
+	for name := range s.manualLevels {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return s.manualLevels[names[i]] < s.manualLevels[names[j]]
+	})
+	return names
+}
+
+// publishCapabilities writes the supported levels and modes to
+// backlight:capabilities.
+func (s *Service) publishCapabilities(ctx context.Context) {
+	caps := capabilities{
+		BuildInfo:     s.build,
+		Modes:         []string{modeAuto, modeManual},
+		MaxBrightness: s.MaxBrightness(),
+		Curve:         s.Backlight.Curve(),
+		Profile: capabilityProfile{
+			Source:       "flags",
+			Curve:        s.remote.curve,
+			ManualLevels: s.remote.levels,
+			Probation:    s.probation.active,
+		},
+	}
+	if s.remote != (remoteConfig{curve: s.Config.Curve, levels: s.Config.ManualLevels}) {
+		caps.Profile.Source = "settings"
+	}
+	for _, name := range s.sortedLevels() {
+		caps.Levels = append(caps.Levels, capabilityLevel{name, s.manualLevels[name]})
+		caps.Modes = append(caps.Modes, name)
+	}
+	caps.Modes = append(caps.Modes, modeFixed+"<level|value>")
+
+	data, err := json.Marshal(caps)
+	if err != nil {
+		return
+	}
+	if err := s.Redis.SetCapabilities(ctx, string(data)); err != nil {
+		s.Logger.Printf("Warning: Failed to publish capabilities: %v", err)
+	}
+}
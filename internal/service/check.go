@@ -0,0 +1,232 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/internal/sensor"
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+	"github.com/librescoot/dbc-backlight-service/pkg/filter"
+)
+
+// settings are the parsed flags New builds the service from.
+type settings struct {
+	units     backlight.Units
+	curve     []backlight.Point
+	levels    map[string]int
+	fades     []backlight.TransitionFade
+	followers []*backlight.Follower
+	fallback  schedule
+	shutdown  shutdownPolicy
+	estimator filter.Estimator
+}
+
+// validate parses and checks every setting New depends on, without
+// touching Redis or any device. It returns every problem found; New
+// stops at the first, Check reports them all.
+func validate(cfg *config.Config) (settings, []error) {
+	var v settings
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	var err error
+
+	if v.units, err = UnitsFor(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	if v.curve, err = backlight.ParseCurveIn(cfg.Curve, v.units); err != nil {
+		fail("invalid curve: %v", err)
+	}
+	if v.levels, err = parseManualLevels(cfg, v.units, cfg.ManualLevels); err != nil {
+		errs = append(errs, err)
+	}
+	if v.levels != nil {
+		if v.fades, err = parseTransitionFades(cfg.TransitionFades, v.levels); err != nil {
+			errs = append(errs, err)
+		}
+		if _, ok := v.levels[cfg.InteractionLevel]; cfg.InteractionBoost > 0 && !ok {
+			fail("invalid interaction-level: unknown level %q", cfg.InteractionLevel)
+		}
+		if cfg.ThemeLevel != "" {
+			s := &Service{Config: cfg, manualLevels: v.levels, units: v.units}
+			if _, ok := s.fixedLevel(modeFixed + cfg.ThemeLevel); !ok {
+				fail("invalid theme-level %q: not a level name or brightness", cfg.ThemeLevel)
+			}
+		}
+	}
+	if v.followers, err = backlight.ParseFollowers(cfg.FollowLEDs, cfg.LEDsDir); err != nil {
+		fail("invalid follow-leds: %v", err)
+	}
+	if err := validStartupPolicy(cfg.StartupPolicy); err != nil {
+		fail("invalid startup-policy: %v", err)
+	}
+	if err := validOscillationAction(cfg.OscillationAction); err != nil {
+		fail("invalid oscillation-action: %v", err)
+	}
+	if cfg.SpeedReference > 0 && (cfg.SpeedMinFactor <= 0 || cfg.SpeedMaxFactor < cfg.SpeedMinFactor) {
+		fail("invalid speed-min-factor/speed-max-factor: need 0 < min <= max")
+	}
+	if cfg.AlarmKey != "" && cfg.AlarmPeriod <= 0 {
+		fail("invalid alarm-period: must be positive")
+	}
+	if cfg.BurnInLevel > 0 && (cfg.BurnInReduce < 0 || cfg.BurnInReduce >= 1) {
+		fail("invalid burnin-reduce: must be between 0 and 1, got %v", cfg.BurnInReduce)
+	}
+	if cfg.HeadlightKey != "" && cfg.HeadlightLuxScale <= 0 {
+		fail("invalid headlight-lux-scale: must be positive, got %v", cfg.HeadlightLuxScale)
+	}
+	if cfg.HeadlightLuxOffset < 0 {
+		fail("invalid headlight-lux-offset: must not be negative, got %v", cfg.HeadlightLuxOffset)
+	}
+	if cfg.RampRate < 0 || cfg.RampRate > 1 {
+		fail("invalid ramp-rate: must be between 0 and 1, got %v", cfg.RampRate)
+	}
+	if cfg.LearnRate < 0 || cfg.LearnRate > 1 {
+		fail("invalid learn-rate: must be between 0 and 1, got %v", cfg.LearnRate)
+	}
+	if v.fallback, err = parseSchedule(cfg.FallbackSchedule, v.units); err != nil {
+		fail("invalid fallback-schedule: %v", err)
+	}
+	if v.shutdown, err = parseShutdownPolicy(cfg.ShutdownPolicy, v.units); err != nil {
+		fail("invalid shutdown-brightness: %v", err)
+	}
+	if v.estimator, err = filter.New(cfg.LuxFilter, cfg.LuxAlpha, cfg.ProcessNoise, cfg.MeasurementNoise); err != nil {
+		fail("invalid lux-filter: %v", err)
+	}
+	if cfg.HookCommand != "" && cfg.HookTimeout <= 0 {
+		fail("invalid hook-timeout: must be positive")
+	}
+	if cfg.SensorFusion != "" && (cfg.SensorPath == "" || cfg.SensorPath2 == "") {
+		fail("invalid sensor-fusion: needs -sensor-path and -sensor-path2")
+	}
+	if cfg.SensorSources != "" {
+		var iio, can sensor.Source
+		if cfg.SensorPath != "" {
+			iio = &sensor.File{Path: cfg.SensorPath}
+		}
+		if cfg.CANInterface != "" {
+			can = &sensor.Latest{Name: cfg.CANInterface}
+		}
+		s := &Service{Config: cfg, schedule: v.fallback}
+		if err := s.setupPriority(cfg.SensorSources, iio, can); err != nil {
+			fail("invalid sensor-sources: %v", err)
+		} else if s.mqttSource != nil && cfg.MQTTLuxTopic == "" {
+			fail("sensor-sources lists mqtt but -mqtt-lux-topic is not set")
+		}
+	}
+	return v, errs
+}
+
+// Check validates cfg the way New would, plus the cross-field and device
+// checks New leaves to the first write, without connecting to Redis or
+// writing to any device. It returns every problem found.
+func Check(cfg *config.Config) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	v, errs := validate(cfg)
+	curve, levels, fallback := v.curve, v.levels, v.fallback
+
+	// Brightness values above -max-brightness would be clamped or
+	// rejected by the driver.
+	if max := cfg.MaxBrightness; max > 0 {
+		for _, p := range curve {
+			if p.Brightness > max {
+				fail("curve point %v:%d is above max-brightness %d", p.Lux, p.Brightness, max)
+			}
+		}
+		for name, b := range levels {
+			if b > max {
+				fail("manual level %s:%d is above max-brightness %d", name, b, max)
+			}
+		}
+		for _, e := range fallback {
+			if e.brightness > max {
+				fail("fallback-schedule entry %d:%d is above max-brightness %d", e.hour, e.brightness, max)
+			}
+		}
+	}
+
+	if cfg.SensorPath != "" {
+		if err := readable(cfg.SensorPath); err != nil {
+			fail("sensor-path: %v", err)
+		}
+	}
+	if cfg.SensorFusion != "" && cfg.SensorPath != "" && cfg.SensorPath2 != "" {
+		f := &sensor.File{Path: cfg.SensorPath}
+		if _, err := sensor.NewFusion(f, f, cfg.SensorFusion, cfg.FusionWeight, cfg.FusionMaxRatio); err != nil {
+			fail("invalid sensor-fusion: %v", err)
+		}
+		if err := readable(cfg.SensorPath2); err != nil {
+			fail("sensor-path2: %v", err)
+		}
+	}
+	if cfg.CANInterface != "" {
+		if _, err := net.InterfaceByName(cfg.CANInterface); err != nil {
+			fail("can-interface: %v", err)
+		}
+	}
+
+	// With -allow-missing-hardware a missing device falls back to memory.
+	switch cfg.Sink {
+	case "sysfs":
+		if err := writable(cfg.SysBacklightPath); err != nil && !(cfg.AllowMissingHW && missing(cfg.SysBacklightPath)) {
+			fail("backlight-path: %v", err)
+		}
+	case "pwm":
+		if info, err := os.Stat(cfg.PWMChip); err != nil {
+			if !(cfg.AllowMissingHW && os.IsNotExist(err)) {
+				fail("pwm-chip: %v", err)
+			}
+		} else if !info.IsDir() {
+			fail("pwm-chip: %s is not a directory", cfg.PWMChip)
+		}
+	case "i2c":
+		if err := writable(cfg.I2CBus); err != nil && !(cfg.AllowMissingHW && missing(cfg.I2CBus)) {
+			fail("i2c-bus: %v", err)
+		}
+	default:
+		fail("invalid sink %q (expected sysfs, pwm or i2c)", cfg.Sink)
+	}
+	if cfg.FBBlank != "" {
+		if err := writable(cfg.FBBlank); err != nil {
+			fail("fb-blank: %v", err)
+		}
+	}
+	// A FIFO would block opening without a reader, and has none yet.
+	if cfg.MirrorPath != "" && !backlight.IsFIFO(cfg.MirrorPath) {
+		if err := writable(cfg.MirrorPath); err != nil {
+			fail("mirror-path: %v", err)
+		}
+	}
+	return errs
+}
+
+// readable reports whether path can be opened for reading.
+func readable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writable reports whether path can be opened for writing. Opening a
+// sysfs attribute without writing to it changes nothing.
+func writable(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// missing reports whether path does not exist.
+func missing(path string) bool {
+	_, err := os.Stat(path)
+	return os.IsNotExist(err)
+}
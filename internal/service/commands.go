@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandConsumer is this service's consumer name in the command stream
+// group. It is fixed so entries left pending by a crash are picked up
+// again after the restart; -lock-file keeps it to one instance.
+const commandConsumer = "backlight-service"
+
+const (
+	commandBlock         = 5 * time.Second // longest wait for a new entry
+	commandRetryInterval = 5 * time.Second // delay after a stream error
+)
+
+// handleCommand runs a control command received on the command channel or
+// stream:
+//
+//	bias-up, bias-down   nudge the bias by one -bias-step
+//	bias-reset           clear the bias
+//	bias <delta>         nudge the bias by a raw delta, e.g. "bias -200"
+//	set-level <level> [duration]
+//	                     override with a manual level, e.g. "set-level high 5m"
+//	boost [duration]     override with the maximum brightness
+//	clear                drop the local override
+//	mode <mode>          persist a backlight mode, as the settings menu does
+//	reload               re-read all settings from Redis and adjust
+//	profile curve|manual-levels <value>
+//	                     push a settings profile field to -settings-key
+//	profile default      drop both fields and return to the flags
+//	suggest-curve        store a curve fitted to the lux histogram for review
+//	learn-reset          forget the curve offsets learned from corrections
+func (s *Service) handleCommand(ctx context.Context, cmd string) error {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty command")
+	}
+
+	switch fields[0] {
+	case "bias-up":
+		return s.nudgeBias(ctx, s.Config.BiasStep)
+	case "bias-down":
+		return s.nudgeBias(ctx, -s.Config.BiasStep)
+	case "bias-reset":
+		return s.storeBias(ctx, 0)
+	case "bias":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: bias <delta>")
+		}
+		delta, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("invalid bias delta %q", fields[1])
+		}
+		return s.nudgeBias(ctx, delta)
+	case "set-level":
+		if len(fields) < 2 || len(fields) > 3 {
+			return fmt.Errorf("usage: set-level <level> [duration]")
+		}
+		if _, ok := s.manualLevels[fields[1]]; !ok {
+			return fmt.Errorf("unknown level %q", fields[1])
+		}
+		d, err := commandDuration(fields[2:])
+		if err != nil {
+			return err
+		}
+		s.setLevelOverride(ctx, fields[1], d)
+		return nil
+	case "boost":
+		if len(fields) > 2 {
+			return fmt.Errorf("usage: boost [duration]")
+		}
+		d, err := commandDuration(fields[1:])
+		if err != nil {
+			return err
+		}
+		s.setOverride(ctx, s.maxBrightness(), d)
+		return nil
+	case "clear":
+		s.clearOverride(ctx)
+		return nil
+	case "mode":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: mode <mode>")
+		}
+		if !s.validMode(fields[1]) {
+			return fmt.Errorf("unknown mode %q", fields[1])
+		}
+		if err := s.Redis.SetBacklightMode(ctx, fields[1]); err != nil {
+			return fmt.Errorf("failed to set backlight mode: %v", err)
+		}
+		if s.applyMode(ctx, fields[1]) {
+			s.adjustBacklight(ctx)
+		}
+		s.clearOverride(ctx)
+		return nil
+	case "reload":
+		s.refreshSettings(ctx)
+		s.adjustBacklight(ctx)
+		return nil
+	case "profile":
+		return s.profileCommand(ctx, fields[1:])
+	case "suggest-curve":
+		return s.suggestCurve(ctx)
+	case "learn-reset":
+		return s.forgetLearned(ctx)
+	}
+	return fmt.Errorf("unknown command %q", fields[0])
+}
+
+// profileCommand writes a curve or manual-levels override to -settings-key,
+// or clears both, and applies the result at once; a pushed profile goes
+// through probation like one from the settings UI.
+func (s *Service) profileCommand(ctx context.Context, args []string) error {
+	key := s.Config.SettingsKey
+	if key == "" {
+		return fmt.Errorf("profile needs -settings-key")
+	}
+	var err error
+	switch {
+	case len(args) == 1 && args[0] == "default":
+		err = s.Redis.ClearSettings(ctx, key, "curve", "manual-levels")
+	case len(args) >= 2 && (args[0] == "curve" || args[0] == "manual-levels"):
+		err = s.Redis.SetSettings(ctx, key, map[string]string{args[0]: strings.Join(args[1:], " ")})
+	default:
+		return fmt.Errorf("usage: profile curve|manual-levels <value> or profile default")
+	}
+	s.noteRedis(ctx, err)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", key, err)
+	}
+	s.refreshRemoteConfig(ctx)
+	return nil
+}
+
+// commandDuration parses an optional duration argument; none means until
+// cleared.
+func commandDuration(args []string) (time.Duration, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", args[0])
+	}
+	return d, nil
+}
+
+// consumeCommandStream runs commands from -command-stream. Unlike the
+// pub/sub channel, entries wait in the stream while the service is down,
+// and an entry is only acknowledged once it has run, so a command in
+// flight during a restart runs after it. Commands that fail are still
+// acknowledged; retrying them would fail the same way.
+func (s *Service) consumeCommandStream(ctx context.Context) {
+	stream, group := s.Config.CommandStream, s.Config.CommandGroup
+	needGroup, pending := true, true
+	for ctx.Err() == nil {
+		if needGroup {
+			if err := s.Redis.CreateCommandGroup(ctx, stream, group); err != nil {
+				s.Logger.Printf("Warning: %v", err)
+				s.waitCommandRetry(ctx)
+				continue
+			}
+			needGroup = false
+		}
+
+		cmds, err := s.Redis.ReadCommands(ctx, stream, group, commandConsumer, pending, 10, commandBlock)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// The stream may have been deleted along with its group.
+			s.Logger.Printf("Warning: %v", err)
+			needGroup = true
+			s.waitCommandRetry(ctx)
+			continue
+		}
+		if pending && len(cmds) == 0 {
+			pending = false
+			continue
+		}
+		for _, c := range cmds {
+			err := s.do(ctx, func(ctx context.Context) {
+				if err := s.handleCommand(ctx, c.Command); err != nil {
+					s.recordError(ctx, "Command %q failed: %v", c.Command, err)
+				}
+			})
+			if err != nil {
+				return // shutting down; leave the entry pending
+			}
+			if err := s.Redis.AckCommand(ctx, stream, group, c.ID); err != nil {
+				s.Logger.Printf("Warning: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Service) waitCommandRetry(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(commandRetryInterval):
+	}
+}
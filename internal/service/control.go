@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// Status is a snapshot of the service state for control interfaces.
+type Status struct {
+	Mode         string            `json:"mode"`
+	Level        string            `json:"level"`
+	Enabled      bool              `json:"enabled"`
+	VehicleState string            `json:"vehicle_state,omitempty"`
+	Source       string            `json:"source,omitempty"`
+	Lux          float64           `json:"lux"`
+	SmoothedLux  float64           `json:"smoothed_lux"`
+	Confidence   float64           `json:"confidence"`
+	Target       int               `json:"target"`
+	Output       int               `json:"output"`
+	Override     *OverrideStatus   `json:"override,omitempty"`
+	Curve        []backlight.Point `json:"curve"`
+	ManualLevels map[string]int    `json:"manual_levels"`
+}
+
+// OverrideStatus describes an active local brightness override.
+type OverrideStatus struct {
+	Brightness int        `json:"brightness"`
+	Until      *time.Time `json:"until,omitempty"`
+}
+
+// override is a temporary brightness set through a control interface,
+// bypassing both the curve and the Redis backlight mode.
+type override struct {
+	active     bool
+	brightness int
+	level      string    // manual level name, if set by level
+	until      time.Time // zero means until cleared
+}
+
+// maxBrightness returns the highest brightness the service is configured
+// to use, for boosts.
+func (s *Service) maxBrightness() int {
+	return curveMax(s.Backlight.Curve(), levelMax(s.manualLevels))
+}
+
+// curveMax returns the highest brightness in curve, or max if that is
+// higher.
+func curveMax(curve []backlight.Point, max int) int {
+	for _, p := range curve {
+		if p.Brightness > max {
+			max = p.Brightness
+		}
+	}
+	return max
+}
+
+// levelMax returns the highest brightness in levels.
+func levelMax(levels map[string]int) int {
+	max := 0
+	for _, b := range levels {
+		if b > max {
+			max = b
+		}
+	}
+	return max
+}
+
+// DebugState is Status plus the internals useful when chasing a bug.
+type DebugState struct {
+	Status
+	Samples   []Sample          `json:"samples"`
+	Writes    int64             `json:"writes"`
+	LastWrite time.Time         `json:"last_write"`
+	Faults    []int             `json:"faults"`
+	Restarts  int64             `json:"restarts"`
+	Config    map[string]string `json:"config"`
+}
+
+// DebugState returns a snapshot of the service internals.
+func (s *Service) DebugState(ctx context.Context) (DebugState, error) {
+	var st DebugState
+	err := s.do(ctx, func(ctx context.Context) {
+		st = DebugState{
+			Status:    s.status(),
+			Samples:   s.samples.all(),
+			Writes:    s.Backlight.Writes(),
+			LastWrite: s.Backlight.LastWrite(),
+			Faults:    s.faults.activeCodes(),
+			Restarts:  s.stats.restarts,
+			Config:    s.Config.Values(),
+		}
+	})
+	return st, err
+}
+
+// MaxBrightness returns the panel's max_brightness, or the highest
+// configured brightness if sysfs does not report one. It is safe to call
+// from any goroutine.
+func (s *Service) MaxBrightness() int {
+	if max := s.Backlight.MaxBrightness(); max > 0 {
+		return max
+	}
+	return curveMax(s.Backlight.Curve(), int(s.levelMax.Load()))
+}
+
+// do runs fn on the monitor goroutine, which owns all mutable service state,
+// and waits for it to complete.
+func (s *Service) do(ctx context.Context, fn func(ctx context.Context)) error {
+	done := make(chan struct{})
+	cmd := func(loopCtx context.Context) {
+		fn(loopCtx)
+		close(done)
+	}
+	select {
+	case s.cmdCh <- cmd:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status returns a snapshot of the current state.
+func (s *Service) Status(ctx context.Context) (Status, error) {
+	var st Status
+	err := s.do(ctx, func(ctx context.Context) {
+		st = s.status()
+	})
+	return st, err
+}
+
+func (s *Service) status() Status {
+	st := Status{
+		Mode:         s.backlightMode,
+		Level:        s.effectiveLevel(),
+		Enabled:      !s.backlightDisabled,
+		VehicleState: s.vehicleState,
+		Source:       s.activeSource,
+		Lux:          s.lastLux,
+		SmoothedLux:  s.Backlight.SmoothedLux(),
+		Confidence:   s.Backlight.Confidence(),
+		Target:       s.Backlight.Target(),
+		Output:       s.Backlight.Output(),
+		Curve:        s.Backlight.Curve(),
+		ManualLevels: s.manualLevels,
+	}
+	if s.override.active {
+		st.Override = &OverrideStatus{Brightness: s.override.brightness}
+		if !s.override.until.IsZero() {
+			until := s.override.until
+			st.Override.Until = &until
+		}
+	}
+	return st
+}
+
+// effectiveLevel names what currently drives the brightness: "alarm"
+// while pulsing, "off" while blanked, the override or manual level name,
+// "override" for a raw override, or "auto".
+func (s *Service) effectiveLevel() string {
+	switch {
+	case s.alarm.active:
+		return "alarm"
+	case s.backlightDisabled:
+		return "off"
+	case s.override.active && s.override.level != "":
+		return s.override.level
+	case s.override.active:
+		return "override"
+	}
+	if _, pinned := s.modeTarget(); pinned {
+		return s.backlightMode
+	}
+	return "auto"
+}
+
+// OnStatusChange registers fn to be called on the monitor goroutine
+// whenever the mode, level, enablement or brightness changes. fn must not
+// block. Register listeners before calling Run.
+func (s *Service) OnStatusChange(fn func(Status)) {
+	s.statusListeners = append(s.statusListeners, fn)
+}
+
+// statusKey is the part of Status whose change is worth notifying about.
+type statusKey struct {
+	mode, level    string
+	enabled        bool
+	target, output int
+}
+
+func (s *Service) notifyStatus() {
+	if len(s.statusListeners) == 0 {
+		return
+	}
+	st := s.status()
+	key := statusKey{st.Mode, st.Level, st.Enabled, st.Target, st.Output}
+	if key == s.lastNotified {
+		return
+	}
+	s.lastNotified = key
+	for _, fn := range s.statusListeners {
+		fn(st)
+	}
+}
+
+// SetOverride pins the brightness to a raw value for d (0 = until cleared)
+// and applies it immediately.
+func (s *Service) SetOverride(ctx context.Context, brightness int, d time.Duration) error {
+	if brightness < 0 {
+		return fmt.Errorf("brightness must not be negative")
+	}
+	return s.do(ctx, func(ctx context.Context) {
+		s.setOverride(ctx, brightness, d)
+	})
+}
+
+// setOverride pins a raw brightness; monitor goroutine only.
+func (s *Service) setOverride(ctx context.Context, brightness int, d time.Duration) {
+	s.override = override{active: true, brightness: brightness}
+	if d > 0 {
+		s.override.until = time.Now().Add(d)
+	}
+	s.recordEvent(ctx, "override", "Local override: brightness %d for %v", brightness, d)
+	s.adjustBacklight(ctx)
+}
+
+// SetOverrideLevel overrides the brightness with a named manual level.
+func (s *Service) SetOverrideLevel(ctx context.Context, level string, d time.Duration) error {
+	var err error
+	if derr := s.do(ctx, func(ctx context.Context) {
+		if _, ok := s.manualLevels[level]; !ok {
+			err = fmt.Errorf("unknown level %q", level)
+			return
+		}
+		s.setLevelOverride(ctx, level, d)
+	}); derr != nil {
+		return derr
+	}
+	return err
+}
+
+// setLevelOverride overrides with a known manual level; monitor goroutine
+// only.
+func (s *Service) setLevelOverride(ctx context.Context, level string, d time.Duration) {
+	s.learnCorrection(ctx, s.manualLevels[level])
+	s.override = override{active: true, brightness: s.manualLevels[level], level: level}
+	if d > 0 {
+		s.override.until = time.Now().Add(d)
+	}
+	s.recordEvent(ctx, "override", "Local override: level %s for %v", level, d)
+	s.adjustBacklight(ctx)
+}
+
+// Boost raises the backlight to its maximum brightness for d.
+func (s *Service) Boost(ctx context.Context, d time.Duration) error {
+	return s.do(ctx, func(ctx context.Context) {
+		s.setOverride(ctx, s.maxBrightness(), d)
+	})
+}
+
+// SetMode persists a backlight mode (see modeTarget) to the
+// settings hash, exactly as the dashboard settings menu does, and drops any
+// local override so the new mode takes effect.
+func (s *Service) SetMode(ctx context.Context, mode string) error {
+	var valid bool
+	if err := s.do(ctx, func(context.Context) {
+		valid = s.validMode(mode)
+	}); err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("unknown mode %q", mode)
+	}
+	if err := s.Redis.SetBacklightMode(ctx, mode); err != nil {
+		return fmt.Errorf("failed to set backlight mode: %v", err)
+	}
+	return s.ClearOverride(ctx)
+}
+
+// ClearOverride returns to the Redis-selected mode.
+func (s *Service) ClearOverride(ctx context.Context) error {
+	return s.do(ctx, s.clearOverride)
+}
+
+// clearOverride drops any local override; monitor goroutine only.
+func (s *Service) clearOverride(ctx context.Context) {
+	if s.override.active {
+		s.override = override{}
+		s.recordEvent(ctx, "override", "Local override cleared")
+		s.adjustBacklight(ctx)
+	}
+}
+
+// checkExternalWrite compares the stored dashboard backlight with what
+// this service last published. A difference means another writer, such as
+// the UI slider, set it; that value is kept as an override for
+// -ui-override instead of being overwritten on the next cycle.
+func (s *Service) checkExternalWrite(ctx context.Context, value int) {
+	if s.Config.UIOverride <= 0 || value < 0 || s.lastPublishedBrightness < 0 {
+		return
+	}
+	if value == s.lastPublishedBrightness {
+		return
+	}
+	s.lastPublishedBrightness = value
+	if s.backlightDisabled {
+		return
+	}
+	s.learnCorrection(ctx, value)
+	s.override = override{
+		active:     true,
+		brightness: value,
+		until:      time.Now().Add(s.Config.UIOverride),
+	}
+	s.recordEvent(ctx, "override", "External backlight write: brightness %d for %v", value, s.Config.UIOverride)
+}
+
+// overrideBrightness returns the active local override, expiring it when
+// its time is up.
+func (s *Service) overrideBrightness(ctx context.Context) (int, bool) {
+	if !s.override.active {
+		return 0, false
+	}
+	if !s.override.until.IsZero() && time.Now().After(s.override.until) {
+		s.override = override{}
+		s.recordEvent(ctx, "override", "Local override expired")
+		return 0, false
+	}
+	return s.override.brightness, true
+}
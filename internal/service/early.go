@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// earlyReadTimeout bounds the sensor read of WriteEarly.
+const earlyReadTimeout = time.Second
+
+// EarlyWrite is the brightness WriteEarly put on the panel.
+type EarlyWrite struct {
+	Brightness int
+	At         time.Time
+	From       string // "sensor" or "level-file"
+}
+
+// WriteEarly sets the panel from cfg alone, before the lock, the device
+// wait and Redis: to the curve value of one -sensor-path reading, or to
+// the level saved in -level-file when no sensor is set. It returns nil
+// when neither is available, when -startup-fade or -wait-for-ui keep the
+// panel dark, or when the device can't be written yet; the first write
+// is then left to Run.
+func WriteEarly(cfg *config.Config, logger *log.Logger) *EarlyWrite {
+	if cfg.StartupFade > 0 || cfg.WaitForUI != "" || (cfg.SensorPath == "" && cfg.LevelFile == "") {
+		return nil
+	}
+	v, errs := validate(cfg)
+	if len(errs) > 0 {
+		return nil // New reports it
+	}
+	if cfg.Sink == "sysfs" {
+		if _, err := os.Stat(cfg.SysBacklightPath); err != nil {
+			return nil // not probed yet; -device-wait covers it
+		}
+	}
+	sink, err := NewSink(cfg, logger)
+	if err != nil {
+		return nil
+	}
+	bcfg := managerConfig(cfg, v.curve, v.levels, v.fades, v.estimator, logger)
+	m, err := backlight.NewManager(sink, bcfg)
+	if err != nil {
+		return nil
+	}
+
+	e := &EarlyWrite{From: "level-file"}
+	if cfg.SensorPath != "" {
+		e.From = "sensor"
+		ctx, cancel := context.WithTimeout(context.Background(), earlyReadTimeout)
+		lux, err := iioSource(cfg, cfg.SensorPath).ReadLux(ctx)
+		cancel()
+		if err != nil {
+			logger.Printf("Early write skipped: %v", err)
+			return nil
+		}
+		s := &Service{Config: cfg}
+		if err := m.AdjustBacklight(s.correctLux(lux)); err != nil {
+			logger.Printf("Early write failed: %v", err)
+			return nil
+		}
+	} else {
+		level, err := readLevelFile(cfg.LevelFile)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logger.Printf("Early write skipped: %v", err)
+			}
+			return nil
+		}
+		if err := m.SetOutput(level); err != nil {
+			logger.Printf("Early write failed: %v", err)
+			return nil
+		}
+	}
+	e.Brightness, e.At = m.Output(), time.Now()
+	return e
+}
+
+func readLevelFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	level, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || level < 0 {
+		return 0, fmt.Errorf("invalid level in %s: %q", path, strings.TrimSpace(string(data)))
+	}
+	return level, nil
+}
+
+// saveLevel writes the current output to -level-file for WriteEarly.
+func (s *Service) saveLevel() {
+	if s.Config.LevelFile == "" || s.Backlight.Output() < 0 {
+		return
+	}
+	if err := os.WriteFile(s.Config.LevelFile, []byte(strconv.Itoa(s.Backlight.Output())+"\n"), 0644); err != nil {
+		s.Logger.Printf("Warning: Failed to save brightness: %v", err)
+	}
+}
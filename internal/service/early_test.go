@@ -0,0 +1,68 @@
+package service
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeEarly runs WriteEarly against a temporary brightness file and
+// returns the result and the file's contents.
+func writeEarly(t *testing.T, args ...string) (*EarlyWrite, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "brightness")
+	if err := os.WriteFile(path, []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t, append([]string{"-backlight-path", path, "-max-brightness", "10240"}, args...)...)
+	e := WriteEarly(cfg, log.New(io.Discard, "", 0))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, strings.TrimSpace(string(data))
+}
+
+func TestWriteEarlyFromSensor(t *testing.T) {
+	sensor := filepath.Join(t.TempDir(), "lux")
+	if err := os.WriteFile(sensor, []byte("0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e, written := writeEarly(t, "-sensor-path", sensor, "-curve", "0:1000 100:9000")
+	if e == nil || e.Brightness != 1000 || e.From != "sensor" || written != "1000" {
+		t.Errorf("expected the curve value 1000 from the sensor, got %+v and %q written", e, written)
+	}
+}
+
+func TestWriteEarlyFromLevelFile(t *testing.T) {
+	level := filepath.Join(t.TempDir(), "level")
+	if e, written := writeEarly(t, "-level-file", level); e != nil || written != "0" {
+		t.Errorf("expected no write without a saved level, got %+v and %q written", e, written)
+	}
+
+	if err := os.WriteFile(level, []byte("4200\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e, written := writeEarly(t, "-level-file", level)
+	if e == nil || e.Brightness != 4200 || e.From != "level-file" || written != "4200" {
+		t.Errorf("expected the saved level 4200, got %+v and %q written", e, written)
+	}
+	if e, written := writeEarly(t, "-level-file", level, "-startup-fade", "1s"); e != nil || written != "0" {
+		t.Errorf("expected -startup-fade to keep the panel dark, got %+v and %q written", e, written)
+	}
+}
+
+func TestSaveLevel(t *testing.T) {
+	level := filepath.Join(t.TempDir(), "level")
+	s := testService(t, "-level-file", level)
+	if err := s.Backlight.SetOutput(3100); err != nil {
+		t.Fatal(err)
+	}
+	s.saveLevel()
+	if got, err := readLevelFile(level); err != nil || got != 3100 {
+		t.Errorf("expected 3100 saved, got %d, %v", got, err)
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	redisClient "github.com/librescoot/dbc-backlight-service/internal/redis"
+)
+
+// faultDef is a fault this service can raise.
+type faultDef struct {
+	code        int
+	severity    string
+	description string
+}
+
+var (
+	faultBacklightWrite   = faultDef{1, "error", "Backlight brightness file is not writable"}
+	faultSensorStale      = faultDef{2, "warning", "No usable illuminance reading"}
+	faultRedisUnreachable = faultDef{3, "warning", "Redis is unreachable"}
+	faultSensorStuck      = faultDef{4, "warning", "Illuminance reading is stuck"}
+)
+
+// ErrRedisUnreachable is returned by Run once Redis calls have failed for
+// longer than -redis-give-up.
+var ErrRedisUnreachable = errors.New("redis unreachable")
+
+// ConfigError reports a setting that is invalid, as opposed to a failure
+// at runtime that a restart may cure.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+// HardwareError reports a backlight device that is missing or can't be
+// driven, as opposed to a configuration mistake.
+type HardwareError struct {
+	Err error
+}
+
+func (e *HardwareError) Error() string {
+	return e.Err.Error()
+}
+
+// maxPendingFaults bounds the fault changes kept while Redis is down.
+const maxPendingFaults = 20
+
+// faultState tracks active faults and changes not yet published. Changes
+// raised while Redis is down (including the Redis fault itself) are queued
+// and published once it is reachable again, so the history stays complete.
+type faultState struct {
+	active         map[int]bool
+	pending        []redisClient.FaultEvent
+	lastLuxOK      time.Time
+	luxFailing     bool
+	lastLux        float64
+	luxChanged     time.Time // when lastLux was first read
+	luxSeen        bool
+	redisDownSince time.Time
+
+	// connection is the state behind the connection field of
+	// backlight:status; published is what Redis last accepted.
+	connection      string
+	connectionSince time.Time
+	published       string
+}
+
+func newFaultState() *faultState {
+	return &faultState{
+		active:    make(map[int]bool),
+		lastLuxOK: time.Now(),
+	}
+}
+
+// activeCodes returns the active fault codes in ascending order.
+func (f *faultState) activeCodes() []int {
+	codes := make([]int, 0, len(f.active))
+	for code := range f.active {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+func (s *Service) setFault(ctx context.Context, f faultDef) {
+	if s.faults.active[f.code] {
+		return
+	}
+	s.faults.active[f.code] = true
+	s.Logger.Printf("Fault %d raised: %s", f.code, f.description)
+	s.queueFault(ctx, f, true)
+}
+
+func (s *Service) clearFault(ctx context.Context, f faultDef) {
+	if !s.faults.active[f.code] {
+		return
+	}
+	delete(s.faults.active, f.code)
+	s.Logger.Printf("Fault %d cleared: %s", f.code, f.description)
+	s.queueFault(ctx, f, false)
+}
+
+func (s *Service) queueFault(ctx context.Context, f faultDef, active bool) {
+	s.faults.pending = append(s.faults.pending, redisClient.FaultEvent{
+		Code:        f.code,
+		Severity:    f.severity,
+		Description: f.description,
+		Active:      active,
+		Time:        time.Now(),
+	})
+	if n := len(s.faults.pending); n > maxPendingFaults {
+		s.faults.pending = s.faults.pending[n-maxPendingFaults:]
+	}
+	s.flushFaults(ctx)
+}
+
+// flushFaults publishes queued fault changes in order, stopping at the
+// first failure.
+func (s *Service) flushFaults(ctx context.Context) {
+	for len(s.faults.pending) > 0 {
+		if err := s.Redis.PublishFault(ctx, s.Config.FaultStream, s.faults.pending[0]); err != nil {
+			return
+		}
+		s.faults.pending = s.faults.pending[1:]
+	}
+}
+
+// noteLux tracks lux read outcomes and raises the stale-sensor fault once
+// reads have failed for longer than -fault-timeout, or a nonzero reading
+// hasn't changed for -stale-timeout. Darkness reads a steady 0, so 0 never
+// counts as unchanged.
+func (s *Service) noteLux(ctx context.Context, lux float64, err error) {
+	now := time.Now()
+	s.faults.luxFailing = err != nil
+	if err == nil {
+		s.faults.lastLuxOK = now
+		if !s.faults.luxSeen || lux != s.faults.lastLux || lux == 0 {
+			s.faults.lastLux, s.faults.luxChanged, s.faults.luxSeen = lux, now, true
+		}
+		if timeout := s.Config.StaleTimeout; timeout > 0 && now.Sub(s.faults.luxChanged) > timeout {
+			s.setFault(ctx, faultSensorStale)
+		} else {
+			s.clearFault(ctx, faultSensorStale)
+		}
+	} else if now.Sub(s.faults.lastLuxOK) > s.Config.FaultTimeout {
+		s.setFault(ctx, faultSensorStale)
+	}
+	s.noteConnection(ctx)
+}
+
+// noteRedis tracks Redis call outcomes and raises the unreachable fault
+// once calls have failed for longer than -fault-timeout.
+func (s *Service) noteRedis(ctx context.Context, err error) {
+	if err == nil {
+		s.faults.redisDownSince = time.Time{}
+		s.clearFault(ctx, faultRedisUnreachable)
+		s.flushFaults(ctx)
+		s.noteConnection(ctx)
+		return
+	}
+	now := time.Now()
+	if s.faults.redisDownSince.IsZero() {
+		s.faults.redisDownSince = now
+	}
+	if now.Sub(s.faults.redisDownSince) > s.Config.FaultTimeout {
+		s.setFault(ctx, faultRedisUnreachable)
+	}
+	s.noteConnection(ctx)
+	if giveUp := s.Config.RedisGiveUp; giveUp > 0 && now.Sub(s.faults.redisDownSince) > giveUp && s.stop != nil && s.fatal == nil {
+		s.Logger.Printf("Redis unreachable for %v, giving up", now.Sub(s.faults.redisDownSince).Round(time.Second))
+		s.fatal = ErrRedisUnreachable
+		s.stop()
+	}
+}
+
+// connectionState sums up Redis and sensor health: "down" while a
+// sensor or Redis fault is raised, "degraded" while calls or reads are
+// failing but not yet for -fault-timeout, "ok" otherwise.
+func (s *Service) connectionState() string {
+	switch {
+	case s.faults.active[faultRedisUnreachable.code],
+		s.faults.active[faultSensorStale.code],
+		s.faults.active[faultSensorStuck.code]:
+		return "down"
+	case !s.faults.redisDownSince.IsZero(), s.faults.luxFailing:
+		return "degraded"
+	}
+	return "ok"
+}
+
+// noteConnection records changes of the connection state and publishes
+// the current one while Redis is reachable. A state Redis missed, such
+// as "down" during an outage, is replaced by the state at reconnection.
+func (s *Service) noteConnection(ctx context.Context) {
+	state := s.connectionState()
+	if state != s.faults.connection {
+		if s.faults.connection != "" {
+			s.Logger.Printf("Connection %s (was %s)", state, s.faults.connection)
+		}
+		s.faults.connection = state
+		s.faults.connectionSince = time.Now()
+	}
+	if state == s.faults.published || !s.faults.redisDownSince.IsZero() {
+		return
+	}
+	if err := s.Redis.SetConnection(ctx, state, s.faults.connectionSince); err != nil {
+		if s.Config.Debug {
+			s.Logger.Printf("Debug: Failed to publish connection state: %v", err)
+		}
+		return
+	}
+	s.faults.published = state
+}
+
+// noteWrite raises or clears the backlight write fault. Repeated failed
+// writes also roll back settings still on probation.
+func (s *Service) noteWrite(ctx context.Context, err error) {
+	if err != nil {
+		s.setFault(ctx, faultBacklightWrite)
+	} else {
+		s.clearFault(ctx, faultBacklightWrite)
+	}
+	s.noteProbationWrite(ctx, err)
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"strings"
+)
+
+// refreshHeadlight re-reads the headlight state. With the headlight on the
+// rider is out at night, and the sensor may be picking up stray light from
+// the headlight itself, so lux is scaled by -headlight-lux-scale and the
+// stray light, -headlight-lux-offset, taken off: the curve settles lower
+// and every threshold sits higher in raw lux.
+func (s *Service) refreshHeadlight(ctx context.Context) {
+	if s.Config.HeadlightKey == "" {
+		return
+	}
+	value, err := s.Redis.GetHashField(ctx, s.Config.HeadlightKey, s.Config.HeadlightField)
+	if err != nil {
+		s.recordError(ctx, "Failed to read headlight state: %v", err)
+		return
+	}
+	on := false
+	for _, v := range strings.Fields(s.Config.HeadlightOnValues) {
+		if v == value {
+			on = true
+		}
+	}
+	if on == s.headlightOn {
+		return
+	}
+	s.headlightOn = on
+	if s.Config.Debug {
+		s.Logger.Printf("Debug: Headlight: %s", value)
+	}
+	s.adjustBacklight(ctx)
+}
+
+// headlightLux applies -headlight-lux-scale and -headlight-lux-offset
+// while the headlight is on.
+func (s *Service) headlightLux(lux float64) float64 {
+	if !s.headlightOn {
+		return lux
+	}
+	lux = lux*s.Config.HeadlightLuxScale - s.Config.HeadlightLuxOffset
+	if lux < 0 {
+		return 0
+	}
+	return lux
+}
@@ -0,0 +1,23 @@
+package service
+
+import "testing"
+
+func TestHeadlightLux(t *testing.T) {
+	s := testService(t, "-headlight-key", "vehicle", "-headlight-lux-scale", "0.5", "-headlight-lux-offset", "2")
+	tests := []struct {
+		on   bool
+		lux  float64
+		want float64
+	}{
+		{on: false, lux: 10, want: 10},
+		{on: true, lux: 10, want: 3},
+		{on: true, lux: 3, want: 0},
+		{on: true, lux: 0, want: 0},
+	}
+	for _, tt := range tests {
+		s.headlightOn = tt.on
+		if got := s.headlightLux(tt.lux); got != tt.want {
+			t.Errorf("headlightLux(%v) with headlight on=%v = %v, want %v", tt.lux, tt.on, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Health reports the inputs and outputs the service depends on.
+type Health struct {
+	RedisOK        bool       `json:"redis_ok"`
+	RedisDownSince *time.Time `json:"redis_down_since,omitempty"`
+	LastLux        time.Time  `json:"last_lux"`
+	SensorOK       bool       `json:"sensor_ok"`
+	LastWrite      time.Time  `json:"last_write"`
+	WriteOK        bool       `json:"write_ok"`
+}
+
+// Ready reports whether every dependency is healthy.
+func (h Health) Ready() bool {
+	return h.RedisOK && h.SensorOK && h.WriteOK
+}
+
+// Health returns the current health. An error means the monitor loop did
+// not answer before ctx expired, i.e. the service is stuck.
+func (s *Service) Health(ctx context.Context) (Health, error) {
+	var h Health
+	err := s.do(ctx, func(ctx context.Context) {
+		h = Health{
+			RedisOK:   s.faults.redisDownSince.IsZero(),
+			LastLux:   s.faults.lastLuxOK,
+			SensorOK:  !s.faults.active[faultSensorStale.code],
+			LastWrite: s.Backlight.LastWrite(),
+			WriteOK:   !s.faults.active[faultBacklightWrite.code],
+		}
+		if !h.RedisOK {
+			since := s.faults.redisDownSince
+			h.RedisDownSince = &since
+		}
+	})
+	return h, err
+}
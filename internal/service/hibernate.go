@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// hibernateRetry is how long to wait before retrying the wake-up watch
+// after a Redis error.
+const hibernateRetry = 10 * time.Second
+
+// enterHibernate turns the backlight off and asks Run to end the session;
+// monitor goroutine only.
+func (s *Service) enterHibernate(ctx context.Context, state string) {
+	s.sleeping = true
+	s.hibernating = true
+	s.recordEvent(ctx, "power", "Power state %s: hibernating", state)
+	if err := s.Backlight.ForceOff(); err != nil {
+		s.recordError(ctx, "Failed to turn backlight off for hibernation: %v", err)
+	}
+	s.signal(s.hibernateCh)
+}
+
+// hibernate closes the Redis connections and blocks until the power
+// manager leaves the hibernate states, holding only a single subscriber
+// connection meanwhile. It reports false if ctx was cancelled first.
+func (s *Service) hibernate(ctx context.Context) bool {
+	s.Redis.Close()
+	s.Redis = s.Redis.Reopen()
+	s.Logger.Printf("Hibernating, waiting for wake-up")
+
+	for {
+		woke, err := s.waitForWake(ctx)
+		if woke {
+			break
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		s.Logger.Printf("Warning: Failed to watch power state: %v", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(hibernateRetry):
+		}
+	}
+
+	s.sleeping = false
+	s.hibernating = false
+	if err := s.Backlight.Resync(); err != nil {
+		s.Logger.Printf("Warning: Failed to re-read backlight after hibernation: %v", err)
+	}
+	s.Logger.Printf("Woke from hibernation")
+	return true
+}
+
+// waitForWake subscribes to power manager changes and returns once the
+// state is no longer a hibernate state.
+func (s *Service) waitForWake(ctx context.Context) (bool, error) {
+	pubsub := s.Redis.Subscribe(ctx, "power-manager")
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return false, err
+	}
+
+	// Check once after subscribing so a wake-up in between isn't missed.
+	ch := pubsub.Channel()
+	for {
+		state, err := s.Redis.GetPowerState(ctx)
+		if err != nil {
+			return false, err
+		}
+		if !s.hibernateStates[state] {
+			return true, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-ch:
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// histogramMaxGap caps the time one reading is credited with, so a stall
+// in polling doesn't book minutes to a stale lux.
+const histogramMaxGap = time.Minute
+
+// luxHistogram accumulates riding time at each lux until the next flush
+// to -lux-histogram-key. Time in the parked states doesn't count: the
+// curve suggestion is for the rider's routes, not the garage.
+type luxHistogram struct {
+	pending backlight.LuxHistogram
+	lux     float64   // last reading
+	since   time.Time // when it was taken (zero = none yet)
+}
+
+// accrueLux credits the time since the previous reading to its lux, then
+// notes lux.
+func (s *Service) accrueLux(lux float64) {
+	if s.Config.LuxHistogramKey == "" {
+		return
+	}
+	h := &s.histogram
+	now := time.Now()
+	if !h.since.IsZero() && !s.parkedStates[s.vehicleState] {
+		if gap := now.Sub(h.since); gap > 0 {
+			if h.pending == nil {
+				h.pending = make(backlight.LuxHistogram)
+			}
+			h.pending.Add(h.lux, math.Min(gap.Seconds(), histogramMaxGap.Seconds()))
+		}
+	}
+	h.lux, h.since = lux, now
+}
+
+// flushHistogram adds the pending time to the histogram in Redis. On a
+// failure it stays pending for the next flush.
+func (s *Service) flushHistogram(ctx context.Context) {
+	if len(s.histogram.pending) == 0 {
+		return
+	}
+	fields := make(map[string]float64, len(s.histogram.pending))
+	for b, secs := range s.histogram.pending {
+		fields[backlight.HistogramField(b)] = secs
+	}
+	err := s.Redis.AddLuxHistogram(ctx, s.Config.LuxHistogramKey, fields)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		if s.Config.Debug {
+			s.Logger.Printf("Debug: %v", err)
+		}
+		return
+	}
+	s.histogram.pending = nil
+}
+
+// suggestCurve derives a curve from the lux histogram (see
+// backlight.SuggestCurve) and stores it in backlight:curve-suggestion for
+// a maintainer to review. Nothing is applied.
+func (s *Service) suggestCurve(ctx context.Context) error {
+	if s.Config.LuxHistogramKey == "" {
+		return fmt.Errorf("no lux histogram without -lux-histogram-key")
+	}
+	s.flushHistogram(ctx)
+	fields, err := s.Redis.GetLuxHistogram(ctx, s.Config.LuxHistogramKey)
+	if err != nil {
+		return fmt.Errorf("failed to read lux histogram: %v", err)
+	}
+	h, err := backlight.ParseLuxHistogram(fields)
+	if err != nil {
+		return err
+	}
+	points, err := backlight.SuggestCurve(s.Backlight.Curve(), h, s.Config.LogLux)
+	if err != nil {
+		return err
+	}
+	curve := backlight.FormatCurve(points)
+	if err := s.Redis.SetCurveSuggestion(ctx, curve); err != nil {
+		return fmt.Errorf("failed to store curve suggestion: %v", err)
+	}
+	s.recordEvent(ctx, "suggest", "Suggested curve from %.1fh of riding: %s", h.Total()/3600, curve)
+	return nil
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hookQueue bounds how many transitions may wait for a slow hook before
+// new ones are dropped.
+const hookQueue = 8
+
+// hookEvent is one level transition handed to the hook command.
+type hookEvent struct {
+	level, previous string
+	mode            string
+	lux             float64
+	brightness      int
+}
+
+// hooks runs -hook-command on level transitions.
+type hooks struct {
+	command string
+	levels  map[string]bool // only run on entering these (empty = all)
+	timeout time.Duration
+	last    string
+	ch      chan hookEvent
+}
+
+func newHooks(command, levels string, timeout time.Duration) *hooks {
+	h := &hooks{
+		command: command,
+		levels:  make(map[string]bool),
+		timeout: timeout,
+		ch:      make(chan hookEvent, hookQueue),
+	}
+	for _, level := range strings.Fields(levels) {
+		h.levels[level] = true
+	}
+	return h
+}
+
+// nearestLevel returns the manual level whose brightness is closest to
+// brightness, preferring the dimmer one on a tie.
+func (s *Service) nearestLevel(brightness int) string {
+	best, bestDist := "", -1
+	for _, name := range s.sortedLevels() {
+		dist := s.manualLevels[name] - brightness
+		if dist < 0 {
+			dist = -dist
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = name, dist
+		}
+	}
+	return best
+}
+
+// transitionLevel is the level reported to hooks: the effective level,
+// with "auto" resolved to the manual level nearest the target.
+func (s *Service) transitionLevel(st Status) string {
+	if st.Level == "auto" && st.Target >= 0 {
+		return s.nearestLevel(st.Target)
+	}
+	return st.Level
+}
+
+// queueHook is a status listener that hands level changes to runHooks.
+func (s *Service) queueHook(st Status) {
+	level := s.transitionLevel(st)
+	if level == s.hooks.last {
+		return
+	}
+	ev := hookEvent{
+		level:      level,
+		previous:   s.hooks.last,
+		mode:       st.Mode,
+		lux:        st.Lux,
+		brightness: st.Target,
+	}
+	s.hooks.last = level
+	if len(s.hooks.levels) > 0 && !s.hooks.levels[level] {
+		return
+	}
+	select {
+	case s.hooks.ch <- ev:
+	default:
+		s.Logger.Printf("Warning: Hook queue full, skipping transition to %s", level)
+	}
+}
+
+// runHooks runs the hook command for each queued transition, one at a
+// time so they finish in order.
+func (s *Service) runHooks(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-s.hooks.ch:
+			s.runHook(ctx, ev)
+		}
+	}
+}
+
+func (s *Service) runHook(ctx context.Context, ev hookEvent) {
+	ctx, cancel := context.WithTimeout(ctx, s.hooks.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", s.hooks.command)
+	cmd.Env = append(os.Environ(),
+		"BACKLIGHT_LEVEL="+ev.level,
+		"BACKLIGHT_PREVIOUS_LEVEL="+ev.previous,
+		"BACKLIGHT_MODE="+ev.mode,
+		"BACKLIGHT_LUX="+strconv.FormatFloat(ev.lux, 'f', -1, 64),
+		"BACKLIGHT_BRIGHTNESS="+strconv.Itoa(ev.brightness),
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		s.Logger.Printf("Warning: Hook for level %s failed: %v: %s", ev.level, err, strings.TrimSpace(string(out)))
+		return
+	}
+	if s.Config.Debug {
+		s.Logger.Printf("Debug: Hook for level %s ran: %s", ev.level, strings.TrimSpace(string(out)))
+	}
+}
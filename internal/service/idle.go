@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// idleDim steps the panel down while the scooter is parked with nobody
+// looking at it: after -idle-dim without interaction, one manual level
+// every -idle-step down to the lowest, then off.
+type idleDim struct {
+	lastActivity time.Time
+	dimmed       bool
+	value        int       // brightness held while dimmed
+	lastStep     time.Time // when value was last lowered
+	curve        int       // curve brightness when dimming started, to spot lux changes
+}
+
+// noteActivity restarts the idle timer, restoring the brightness if the
+// panel was dimmed.
+func (s *Service) noteActivity(ctx context.Context, reason string) {
+	s.idle.lastActivity = time.Now()
+	if !s.idle.dimmed {
+		return
+	}
+	s.idle.dimmed = false
+	s.recordEvent(ctx, "idle", "Idle dimming ended (%s)", reason)
+	s.signal(s.adjustCh)
+}
+
+// idleTarget returns the brightness to hold while idle, or false if the
+// panel isn't idle. A curve change bigger than the deadband counts as
+// activity: the light around the scooter changed, and so may whoever is
+// looking at it.
+func (s *Service) idleTarget(ctx context.Context, lux float64) (int, bool) {
+	if s.Config.IdleDim <= 0 {
+		return 0, false
+	}
+	now := time.Now()
+	if s.idle.lastActivity.IsZero() {
+		s.idle.lastActivity = now
+	}
+	if !s.parkedStates[s.vehicleState] {
+		if s.idle.dimmed {
+			s.noteActivity(ctx, "vehicle "+s.vehicleState)
+		}
+		s.idle.lastActivity = now
+		return 0, false
+	}
+	curve := s.Backlight.Interpolate(lux)
+	if s.idle.dimmed {
+		if d := curve - s.idle.curve; d > s.Backlight.Deadband() || -d > s.Backlight.Deadband() {
+			s.noteActivity(ctx, "lux changed")
+			return 0, false
+		}
+		if now.Sub(s.idle.lastStep) < s.Config.IdleStep {
+			return s.idle.value, true
+		}
+	} else if now.Sub(s.idle.lastActivity) < s.Config.IdleDim {
+		return 0, false
+	}
+
+	from := s.Backlight.Output()
+	if s.idle.dimmed {
+		from = s.idle.value
+	}
+	next, ok := s.levelBelow(from)
+	if !ok {
+		if !s.Config.IdleOff || from == 0 {
+			// Nothing lower to step to.
+			return s.idle.value, s.idle.dimmed
+		}
+		next = 0
+	}
+	if s.charging(ctx) {
+		s.noteActivity(ctx, "charging")
+		return 0, false
+	}
+	if !s.idle.dimmed {
+		s.idle.dimmed = true
+		s.idle.curve = curve
+	}
+	s.idle.value = next
+	s.idle.lastStep = now
+	s.recordEvent(ctx, "idle", "Parked and idle for %v, dimming to %d", now.Sub(s.idle.lastActivity).Round(time.Second), next)
+	return next, true
+}
+
+// idleWait returns how long until idleTarget has its next step to take,
+// or false if no step is pending. Polling is kept up for it, since parked
+// polling may be paused or slower than -idle-step.
+func (s *Service) idleWait() (time.Duration, bool) {
+	if s.Config.IdleDim <= 0 || !s.parkedStates[s.vehicleState] || s.idle.lastActivity.IsZero() {
+		return 0, false
+	}
+	due := s.idle.lastActivity.Add(s.Config.IdleDim)
+	from := s.Backlight.Output()
+	if s.idle.dimmed {
+		due = s.idle.lastStep.Add(s.Config.IdleStep)
+		from = s.idle.value
+	}
+	if _, ok := s.levelBelow(from); !ok && (!s.Config.IdleOff || from == 0) {
+		return 0, false
+	}
+	wait := time.Until(due)
+	if wait <= 0 {
+		// Overdue, so something else holds the panel; check back at
+		// the normal rate rather than spinning.
+		wait = s.Config.PollingTime
+	}
+	return wait, true
+}
+
+// levelBelow returns the highest manual level below brightness.
+func (s *Service) levelBelow(brightness int) (int, bool) {
+	levels := s.sortedLevels()
+	for i := len(levels) - 1; i >= 0; i-- {
+		if b := s.manualLevels[levels[i]]; b < brightness {
+			return b, true
+		}
+	}
+	return 0, false
+}
+
+// charging reports whether -charging-key says the scooter is on the
+// charger, where the rider is likely nearby and watching.
+func (s *Service) charging(ctx context.Context) bool {
+	if s.Config.ChargingKey == "" {
+		return false
+	}
+	value, err := s.Redis.GetHashField(ctx, s.Config.ChargingKey, s.Config.ChargingField)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to read charging state: %v", err)
+		return false
+	}
+	for _, v := range strings.Fields(s.Config.ChargingValues) {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIdleWait(t *testing.T) {
+	s := testService(t, "-idle-dim", "5m", "-idle-step", "30s", "-pause-when-parked", "-heartbeat-interval", "0", "-manual-levels", "low:1000 high:8000")
+	s.vehicleState = "parked"
+	s.Backlight.ApplyManual(8000)
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		activity time.Time
+		dimmed   bool
+		value    int
+		lastStep time.Time
+		want     time.Duration // 0 means no step pending
+	}{
+		{name: "counting down to dimming", activity: now.Add(-4 * time.Minute), want: time.Minute},
+		{name: "between steps", activity: now.Add(-6 * time.Minute), dimmed: true, value: 1000, lastStep: now.Add(-10 * time.Second), want: 20 * time.Second},
+		{name: "overdue step", activity: now.Add(-6 * time.Minute), dimmed: true, value: 1000, lastStep: now.Add(-time.Minute), want: s.Config.PollingTime},
+		{name: "already off", activity: now.Add(-10 * time.Minute), dimmed: true, value: 0, lastStep: now},
+	}
+	for _, tt := range tests {
+		s.idle = idleDim{lastActivity: tt.activity, dimmed: tt.dimmed, value: tt.value, lastStep: tt.lastStep}
+		wait, ok := s.idleWait()
+		if tt.want == 0 {
+			if ok {
+				t.Errorf("%s: expected no pending step, got %v", tt.name, wait)
+			}
+			continue
+		}
+		if !ok || wait > tt.want || wait < tt.want-time.Second {
+			t.Errorf("%s: idleWait = %v, %v, want about %v", tt.name, wait, ok, tt.want)
+		}
+		if got := s.nextPollInterval(); got > tt.want || got < tt.want-time.Second {
+			t.Errorf("%s: paused parked polling should wake for the step, got %v", tt.name, got)
+		}
+	}
+
+	s.vehicleState = "ready-to-drive"
+	if _, ok := s.idleWait(); ok {
+		t.Error("expected no idle step outside the parked states")
+	}
+}
+
+func TestIdleTarget(t *testing.T) {
+	s := testService(t, "-idle-dim", "5m", "-idle-step", "30s", "-manual-levels", "low:1000 high:8000")
+	s.Backlight.ApplyManual(8000)
+	ctx := context.Background()
+
+	s.vehicleState = "ready-to-drive"
+	if _, idle := s.idleTarget(ctx, 10); idle {
+		t.Fatal("expected no dimming while riding")
+	}
+
+	s.vehicleState = "parked"
+	if _, idle := s.idleTarget(ctx, 10); idle {
+		t.Fatal("expected no dimming before -idle-dim has passed")
+	}
+
+	steps := []struct {
+		name   string
+		age    time.Duration // how long ago the last step (or activity) was
+		want   int
+		dimmed bool
+	}{
+		{name: "first step", age: 6 * time.Minute, want: 1000, dimmed: true},
+		{name: "holds between steps", age: 10 * time.Second, want: 1000, dimmed: true},
+		{name: "turns off after the lowest level", age: 31 * time.Second, want: 0, dimmed: true},
+		{name: "stays off", age: time.Minute, want: 0, dimmed: true},
+	}
+	for _, st := range steps {
+		if s.idle.dimmed {
+			s.idle.lastStep = time.Now().Add(-st.age)
+		} else {
+			s.idle.lastActivity = time.Now().Add(-st.age)
+		}
+		level, idle := s.idleTarget(ctx, 10)
+		if level != st.want || idle != st.dimmed {
+			t.Errorf("%s: idleTarget = %d, %v, want %d, %v", st.name, level, idle, st.want, st.dimmed)
+		}
+	}
+
+	if _, idle := s.idleTarget(ctx, 80); idle || s.idle.dimmed {
+		t.Error("expected a large lux change to end dimming")
+	}
+}
+
+func TestIdleTargetStopsAtLowestLevel(t *testing.T) {
+	s := testService(t, "-idle-dim", "5m", "-idle-off=false", "-manual-levels", "low:1000 high:8000")
+	s.Backlight.ApplyManual(8000)
+	s.vehicleState = "parked"
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		s.idle.lastActivity = time.Now().Add(-6 * time.Minute)
+		s.idle.lastStep = time.Now().Add(-time.Minute)
+		if level, idle := s.idleTarget(ctx, 10); level != 1000 || !idle {
+			t.Errorf("step %d: idleTarget = %d, %v, want 1000, true", i, level, idle)
+		}
+	}
+
+	s.vehicleState = "ready-to-drive"
+	if _, idle := s.idleTarget(ctx, 10); idle || s.idle.dimmed {
+		t.Error("expected leaving the parked states to end dimming")
+	}
+}
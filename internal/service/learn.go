@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// learnCorrection takes a manual correction in auto mode, a level picked
+// by button, command or API or a write from the UI slider, as the
+// rider's preference at the current lux and moves the curve there by
+// -learn-rate (see backlight.Manager.Learn). Corrections on top of
+// another override, idle dimming or a boost say nothing about the curve
+// and are ignored.
+func (s *Service) learnCorrection(ctx context.Context, brightness int) {
+	if s.Config.LearnRate <= 0 || s.backlightMode != "auto" || s.override.active ||
+		s.idle.dimmed || !s.boost.until.IsZero() || s.fallback {
+		return
+	}
+	target := s.Backlight.Target()
+	offsets, ok := s.Backlight.Learn(brightness, s.Config.LearnRate, s.Config.LearnLimit)
+	if !ok {
+		return
+	}
+	s.recordEvent(ctx, "learn", "Learned from a correction to %d at %.1f lux (target %d): curve offsets %s",
+		brightness, s.Backlight.SmoothedLux(), target, formatOffsets(offsets))
+	curve := backlight.FormatCurve(s.Backlight.Curve())
+	err := s.Redis.SetLearned(ctx, curve, formatOffsets(offsets))
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to store learned curve: %v", err)
+	}
+}
+
+// loadLearned applies the stored offsets if they were learned on the
+// curve now in use; offsets for another curve are left alone, in case it
+// comes back.
+func (s *Service) loadLearned(ctx context.Context) {
+	if s.Config.LearnRate <= 0 {
+		return
+	}
+	curve, value, err := s.Redis.GetLearned(ctx)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to read learned curve: %v", err)
+		return
+	}
+	if value == "" || curve != backlight.FormatCurve(s.Backlight.Curve()) {
+		return
+	}
+	offsets, err := parseOffsets(value)
+	if err == nil {
+		err = s.Backlight.SetCurveOffsets(offsets)
+	}
+	if err != nil {
+		s.recordError(ctx, "Invalid learned curve: %v", err)
+		return
+	}
+	s.Logger.Printf("Applied learned curve offsets %s", value)
+}
+
+// forgetLearned drops the learned offsets, here and in Redis.
+func (s *Service) forgetLearned(ctx context.Context) error {
+	s.Backlight.SetCurveOffsets(nil)
+	if err := s.Redis.ClearLearned(ctx); err != nil {
+		return fmt.Errorf("failed to clear learned curve: %v", err)
+	}
+	s.recordEvent(ctx, "learn", "Learned curve offsets cleared")
+	s.adjustBacklight(ctx)
+	return nil
+}
+
+func formatOffsets(offsets []int) string {
+	parts := make([]string, len(offsets))
+	for i, v := range offsets {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, " ")
+}
+
+func parseOffsets(s string) ([]int, error) {
+	fields := strings.Fields(s)
+	offsets := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset %q", f)
+		}
+		offsets[i] = v
+	}
+	return offsets, nil
+}
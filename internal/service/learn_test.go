@@ -0,0 +1,35 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOffsets(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []int
+	}{
+		{"", []int{}},
+		{"0 -120 35", []int{0, -120, 35}},
+		{"  5\t6 ", []int{5, 6}},
+	}
+	for _, tt := range tests {
+		got, err := parseOffsets(tt.value)
+		if err != nil {
+			t.Errorf("parseOffsets(%q): %v", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseOffsets(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+		if again, _ := parseOffsets(formatOffsets(got)); !reflect.DeepEqual(again, got) {
+			t.Errorf("formatOffsets(%v) does not round-trip: %v", got, again)
+		}
+	}
+	for _, value := range []string{"1 two 3", "1.5", "0x10"} {
+		if _, err := parseOffsets(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
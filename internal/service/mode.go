@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"strings"
+)
+
+// Backlight modes from the settings hash besides the named manual levels:
+// "auto" follows the lux curve, "manual" applies the brightness the UI
+// writes to dashboard.backlight-brightness and never overrides it, and
+// "fixed:<level>" pins a named level or raw brightness value.
+const (
+	modeAuto   = "auto"
+	modeManual = "manual"
+	modeFixed  = "fixed:"
+)
+
+// modeTarget returns the brightness the current mode pins the backlight to,
+// or false when the lux curve should drive it.
+func (s *Service) modeTarget() (int, bool) {
+	mode := s.backlightMode
+	if level, ok := s.manualLevels[mode]; ok {
+		return level, true
+	}
+	if mode == modeManual {
+		if s.manualBrightness >= 0 {
+			return s.manualBrightness, true
+		}
+		// Nothing written by the UI yet: hold whatever is on the panel.
+		return s.Backlight.Output(), s.Backlight.Output() >= 0
+	}
+	if strings.HasPrefix(mode, modeFixed) {
+		if level, ok := s.fixedLevel(mode); ok {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// fixedLevel resolves "fixed:<level>" to a named level or a brightness in
+// any of the configured units.
+func (s *Service) fixedLevel(mode string) (int, bool) {
+	name := strings.TrimPrefix(mode, modeFixed)
+	if level, ok := s.manualLevels[name]; ok {
+		return level, true
+	}
+	if v, err := s.units.Raw(name); err == nil {
+		return v, true
+	}
+	return 0, false
+}
+
+// validMode reports whether mode is one the service understands.
+func (s *Service) validMode(mode string) bool {
+	if mode == modeAuto || mode == modeManual {
+		return true
+	}
+	if _, ok := s.manualLevels[mode]; ok {
+		return true
+	}
+	if strings.HasPrefix(mode, modeFixed) {
+		_, ok := s.fixedLevel(mode)
+		return ok
+	}
+	return false
+}
+
+// refreshManualBrightness re-reads the UI-written brightness for manual mode.
+func (s *Service) refreshManualBrightness(ctx context.Context) {
+	brightness, err := s.Redis.GetManualBrightness(ctx)
+	if err != nil {
+		s.recordError(ctx, "Failed to read manual brightness: %v", err)
+		return
+	}
+	if brightness != s.manualBrightness {
+		s.manualBrightness = brightness
+		if s.backlightMode == modeManual {
+			s.recordEvent(ctx, "mode", "Manual brightness: %d", brightness)
+		}
+	}
+}
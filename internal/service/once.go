@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// CycleError reports which step of a control cycle failed: "read" (no lux
+// reading, nothing written), "write" (the backlight could not be set) or
+// "publish" (the backlight was set but Redis was not updated).
+type CycleError struct {
+	Step string
+	Err  error
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.Step, e.Err)
+}
+
+// RunOnce performs a single read, adjust and publish cycle and returns,
+// for udev rules, cron jobs and scripts that don't want a daemon. There is
+// no ramp: the first decision is written straight away.
+func (s *Service) RunOnce(ctx context.Context) error {
+	defer func() { s.Redis.Close() }()
+	return s.adjustBacklight(ctx)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// oscillationWindow is the span the transition rate is measured over.
+const oscillationWindow = time.Minute
+
+// oscillationWiden is the deadband multiplier applied while oscillating.
+const oscillationWiden = 4
+
+// oscillationGuard counts reversals of the target's direction over the
+// last minute. A curve whose thresholds sit on the ambient level can
+// otherwise strobe the display for as long as the rider stays put, while
+// the many small steps of one smoothed approach to a new level are fine.
+type oscillationGuard struct {
+	times    []time.Time // reversals within oscillationWindow, oldest first
+	engaged  bool
+	since    time.Time
+	deadband int // deadband before engaging, restored on release
+}
+
+// validOscillationAction checks -oscillation-action.
+func validOscillationAction(a string) error {
+	switch a {
+	case "widen", "freeze":
+		return nil
+	}
+	return fmt.Errorf("expected widen or freeze, got %q", a)
+}
+
+// direction is the sign of the last target change: 1 up, -1 down, 0 none
+// yet.
+type direction int
+
+// reversed records a target change of delta and reports whether it went
+// the other way from the previous change.
+func (d *direction) reversed(delta int) bool {
+	if delta == 0 {
+		return false
+	}
+	dir := direction(1)
+	if delta < 0 {
+		dir = -1
+	}
+	rev := *d != 0 && dir != *d
+	*d = dir
+	return rev
+}
+
+// noteReversal records a reversal of the target's direction and engages
+// the guard once there have been more than -oscillation-rate of them in a
+// minute.
+func (s *Service) noteReversal(ctx context.Context) {
+	g := &s.oscillation
+	if !g.note(time.Now(), s.Config.OscillationRate) {
+		return
+	}
+	action := g.engage(s.Backlight, s.Config.OscillationAction)
+	s.recordEvent(ctx, "oscillation", "Warning: brightness reversed direction %d times in the last minute, %s; check the curve thresholds", len(g.times), action)
+	s.publishOscillation(ctx, s.Config.OscillationAction)
+}
+
+// checkOscillation releases the guard once it has been engaged for a full
+// window and the reversal rate has dropped to half the limit.
+func (s *Service) checkOscillation(ctx context.Context) {
+	g := &s.oscillation
+	if !g.release(time.Now(), s.Config.OscillationRate) {
+		return
+	}
+	g.disengage(s.Backlight)
+	s.recordEvent(ctx, "oscillation", "Brightness reversals back to %d in the last minute, restoring normal control", len(g.times))
+	s.publishOscillation(ctx, "normal")
+}
+
+// note records a reversal at now and reports whether the guard engages
+// with it: more than rate reversals in the window (rate <= 0 = off).
+func (g *oscillationGuard) note(now time.Time, rate int) bool {
+	if rate <= 0 {
+		return false
+	}
+	g.times = append(g.prune(now), now)
+	if g.engaged || len(g.times) <= rate {
+		return false
+	}
+	g.engaged = true
+	g.since = now
+	return true
+}
+
+// release reports whether an engaged guard is released at now.
+func (g *oscillationGuard) release(now time.Time, rate int) bool {
+	if !g.engaged {
+		return false
+	}
+	g.times = g.prune(now)
+	if now.Sub(g.since) < oscillationWindow || len(g.times) > rate/2 {
+		return false
+	}
+	g.engaged = false
+	return true
+}
+
+// engage applies -oscillation-action to m and describes what it did.
+func (g *oscillationGuard) engage(m *backlight.Manager, action string) string {
+	g.deadband = m.Deadband()
+	if action == "freeze" {
+		m.SetHold(true)
+		return fmt.Sprintf("freezing brightness at %d", m.Target())
+	}
+	m.SetDeadband(g.deadband * oscillationWiden)
+	return fmt.Sprintf("widening deadband to %d", g.deadband*oscillationWiden)
+}
+
+// disengage restores normal control of m.
+func (g *oscillationGuard) disengage(m *backlight.Manager) {
+	m.SetHold(false)
+	m.SetDeadband(g.deadband)
+}
+
+// prune drops reversals older than oscillationWindow.
+func (g *oscillationGuard) prune(now time.Time) []time.Time {
+	i := 0
+	for i < len(g.times) && now.Sub(g.times[i]) > oscillationWindow {
+		i++
+	}
+	return g.times[i:]
+}
+
+// publishOscillation publishes the guard's state as the oscillation field
+// of backlight:status.
+func (s *Service) publishOscillation(ctx context.Context, state string) {
+	err := s.Redis.SetOscillation(ctx, state)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.Logger.Printf("Warning: Failed to publish oscillation state: %v", err)
+	}
+}
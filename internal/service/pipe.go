@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// pipeDecision is one line of pipe mode output.
+type pipeDecision struct {
+	Time       float64 `json:"t"`   // seconds since the first reading
+	Lux        float64 `json:"lux"` // after -lux-scale and -lux-offset
+	Smoothed   float64 `json:"smoothed"`
+	Target     int     `json:"target"`
+	Brightness int     `json:"brightness"`
+	Level      string  `json:"level"`
+	Write      bool    `json:"write"`                 // the reading caused a backlight write
+	Guard      bool    `json:"oscillation,omitempty"` // the oscillation guard is engaged
+}
+
+// RunPipe feeds the brightness engine, configured from cfg as the service
+// would be, with lux readings from r and writes a JSON decision per
+// reading to w. The oscillation guard runs as in the service, and learned
+// are the curve offsets from backlight:learned ("" = none), since they
+// live in Redis. No Redis or backlight device is involved. Each line is
+// "lux", taken one -polling-time after the previous reading, or "seconds
+// lux", taken that long after the start; blank lines and lines starting
+// with # are skipped.
+func RunPipe(cfg *config.Config, learned string, r io.Reader, w io.Writer, logger *log.Logger) error {
+	v, errs := validate(cfg)
+	if len(errs) > 0 {
+		return &ConfigError{errs[0]}
+	}
+	bcfg := managerConfig(cfg, v.curve, v.levels, v.fades, v.estimator, logger)
+	bcfg.BlankPath = ""
+	m, err := backlight.NewManager(backlight.NewMemorySink(memoryMax(cfg)), bcfg)
+	if err != nil {
+		return fmt.Errorf("invalid backlight configuration: %v", err)
+	}
+	if learned != "" {
+		offsets, err := parseOffsets(learned)
+		if err == nil {
+			err = m.SetCurveOffsets(offsets)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid learned offsets: %v", err)
+		}
+	}
+
+	start := time.Now()
+	now := start
+	m.SetClock(func() time.Time { return now })
+	s := &Service{Config: cfg, manualLevels: v.levels, units: v.units}
+	var guard oscillationGuard
+	var dir direction
+	lastTarget := -1
+	enc := json.NewEncoder(w)
+
+	scanner := bufio.NewScanner(r)
+	read := false
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		next := now.Sub(start)
+		if read {
+			next += cfg.PollingTime
+		}
+		at, lux, err := parsePipeLine(fields, next)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if start.Add(at).Before(now) {
+			return fmt.Errorf("line %d: time %v is before the previous reading", line, at)
+		}
+		now = start.Add(at)
+		read = true
+
+		lux = s.correctLux(lux)
+		writes := m.Writes()
+		if err := m.AdjustBacklight(lux); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		// The same reversal count and release as the service's monitor
+		// loop, on the pipe's clock.
+		if target := m.Target(); target != lastTarget {
+			if lastTarget >= 0 && dir.reversed(target-lastTarget) && guard.note(now, cfg.OscillationRate) {
+				guard.engage(m, cfg.OscillationAction)
+			}
+			lastTarget = target
+		}
+		if guard.release(now, cfg.OscillationRate) {
+			guard.disengage(m)
+		}
+
+		brightness := m.Output()
+		if err := enc.Encode(pipeDecision{
+			Time:       at.Seconds(),
+			Lux:        lux,
+			Smoothed:   m.SmoothedLux(),
+			Target:     m.Target(),
+			Brightness: brightness,
+			Level:      s.outputBand(brightness),
+			Write:      m.Writes() > writes,
+			Guard:      guard.engaged,
+		}); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parsePipeLine reads "lux" or "seconds lux"; a bare lux is taken at next.
+func parsePipeLine(fields []string, next time.Duration) (time.Duration, float64, error) {
+	if len(fields) > 2 {
+		return 0, 0, fmt.Errorf("expected \"lux\" or \"seconds lux\", got %q", strings.Join(fields, " "))
+	}
+	lux, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+	if err != nil || lux < 0 {
+		return 0, 0, fmt.Errorf("invalid lux %q", fields[len(fields)-1])
+	}
+	if len(fields) == 1 {
+		return next, lux, nil
+	}
+	secs, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || secs < 0 {
+		return 0, 0, fmt.Errorf("invalid time %q", fields[0])
+	}
+	return time.Duration(secs * float64(time.Second)), lux, nil
+}
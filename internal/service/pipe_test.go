@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+)
+
+// testConfig returns the defaults plus args, without board quirks. The
+// flags are registered on a fresh flag set, as config.New uses the global
+// one.
+func testConfig(t *testing.T, args ...string) *config.Config {
+	t.Helper()
+	flag.CommandLine = flag.NewFlagSet(t.Name(), flag.ContinueOnError)
+	cfg := config.New()
+	if err := cfg.Parse(append([]string{"-quirks=false"}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func runPipe(t *testing.T, cfg *config.Config, learned, input string) []pipeDecision {
+	t.Helper()
+	var out bytes.Buffer
+	if err := RunPipe(cfg, learned, strings.NewReader(input), &out, log.New(io.Discard, "", 0)); err != nil {
+		t.Fatal(err)
+	}
+	var decisions []pipeDecision
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var d pipeDecision
+		if err := dec.Decode(&d); err != nil {
+			t.Fatal(err)
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions
+}
+
+func TestRunPipe(t *testing.T) {
+	cfg := testConfig(t, "-curve", "0:1000 100:9000", "-polling-time", "1s")
+	got := runPipe(t, cfg, "", "# comment\n0\n\n10 50\n50\n")
+	if len(got) != 3 {
+		t.Fatalf("expected 3 decisions, got %d", len(got))
+	}
+	if got[0].Time != 0 || got[0].Target != 1000 || !got[0].Write {
+		t.Errorf("expected the first reading to snap to 1000, got %+v", got[0])
+	}
+	if got[1].Time != 10 || got[2].Time != 11 {
+		t.Errorf("expected times 10 and 11, got %v and %v", got[1].Time, got[2].Time)
+	}
+	if got[2].Brightness <= got[0].Brightness || got[2].Brightness >= got[2].Target {
+		t.Errorf("expected the output to ramp towards %d, got %d", got[2].Target, got[2].Brightness)
+	}
+}
+
+func TestRunPipeErrors(t *testing.T) {
+	cfg := testConfig(t)
+	for _, in := range []string{"bright\n", "1 2 3\n", "10 5\n5 5\n", "-1\n"} {
+		err := RunPipe(cfg, "", strings.NewReader(in), io.Discard, log.New(io.Discard, "", 0))
+		if err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+	if err := RunPipe(cfg, "1 2", strings.NewReader("5\n"), io.Discard, log.New(io.Discard, "", 0)); err == nil {
+		t.Error("expected learned offsets that don't match the curve to fail")
+	}
+}
+
+func TestRunPipeAppliesLearnedOffsets(t *testing.T) {
+	cfg := testConfig(t, "-curve", "0:1000 100:9000")
+	got := runPipe(t, cfg, "500 500", "0\n")
+	if got[0].Target != 1500 {
+		t.Errorf("expected the learned +500 on the curve, got %d", got[0].Target)
+	}
+}
+
+func TestRunPipeRunsOscillationGuard(t *testing.T) {
+	// Lux alternating between 10 and 90 every 5s reverses the target
+	// several times a minute.
+	var in strings.Builder
+	for i := 0; i < 100; i++ {
+		lux := 10
+		if i/10%2 == 1 {
+			lux = 90
+		}
+		fmt.Fprintf(&in, "%.1f %d\n", float64(i)/2, lux)
+	}
+	engaged := func(decisions []pipeDecision) bool {
+		for _, d := range decisions {
+			if d.Guard {
+				return true
+			}
+		}
+		return false
+	}
+
+	cfg := testConfig(t, "-curve", "0:1000 100:9000", "-oscillation-rate", "2")
+	if !engaged(runPipe(t, cfg, "", in.String())) {
+		t.Error("expected the oscillation guard to engage")
+	}
+	cfg = testConfig(t, "-curve", "0:1000 100:9000", "-oscillation-rate", "0")
+	if engaged(runPipe(t, cfg, "", in.String())) {
+		t.Error("expected no guard with -oscillation-rate 0")
+	}
+}
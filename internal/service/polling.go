@@ -0,0 +1,56 @@
+package service
+
+import (
+	"math"
+	"time"
+)
+
+// adaptiveInterval picks the next polling interval: the minimum while lux
+// is changing or the output is still ramping, backing off exponentially
+// towards the maximum once readings are stable.
+type adaptiveInterval struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+	lastLux float64
+}
+
+// luxStableFraction is the relative lux change between polls still
+// considered stable; luxStableFloor keeps near-dark readings from counting
+// sensor noise as change.
+const (
+	luxStableFraction = 0.05
+	luxStableFloor    = 0.5
+)
+
+func newAdaptiveInterval(min, max time.Duration) *adaptiveInterval {
+	if max < min {
+		max = min
+	}
+	return &adaptiveInterval{min: min, max: max, current: min, lastLux: -1}
+}
+
+// next records a lux reading and returns the interval until the next poll.
+// settled reports whether the backlight output has reached its target.
+func (a *adaptiveInterval) next(lux float64, settled bool) time.Duration {
+	stable := a.lastLux >= 0 &&
+		math.Abs(lux-a.lastLux) <= math.Max(luxStableFloor, a.lastLux*luxStableFraction)
+	a.lastLux = lux
+
+	if !stable || !settled {
+		a.current = a.min
+		return a.current
+	}
+
+	a.current *= 2
+	if a.current > a.max {
+		a.current = a.max
+	}
+	return a.current
+}
+
+// peek returns the interval next last returned, without recording a
+// reading.
+func (a *adaptiveInterval) peek() time.Duration {
+	return a.current
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveIntervalNext(t *testing.T) {
+	const min, max = time.Second, 5 * time.Second
+	tests := []struct {
+		name    string
+		lux     float64
+		settled bool
+		want    time.Duration
+	}{
+		{name: "first reading", lux: 100, settled: true, want: min},
+		{name: "within 5%", lux: 105, settled: true, want: 2 * time.Second},
+		{name: "beyond 5%", lux: 111, settled: true, want: min},
+		{name: "stable again", lux: 111, settled: true, want: 2 * time.Second},
+		{name: "ramping resets", lux: 111, settled: false, want: min},
+		{name: "doubles", lux: 111, settled: true, want: 2 * time.Second},
+		{name: "doubles again", lux: 111, settled: true, want: 4 * time.Second},
+		{name: "capped at max", lux: 111, settled: true, want: max},
+		{name: "stays at max", lux: 111, settled: true, want: max},
+		{name: "dark", lux: 0.2, settled: true, want: min},
+		{name: "within the floor", lux: 0.7, settled: true, want: 2 * time.Second},
+		{name: "beyond the floor", lux: 1.3, settled: true, want: min},
+	}
+	a := newAdaptiveInterval(min, max)
+	for _, tt := range tests {
+		if got := a.next(tt.lux, tt.settled); got != tt.want {
+			t.Errorf("%s: next(%v, %v) = %v, want %v", tt.name, tt.lux, tt.settled, got, tt.want)
+		}
+		if got := a.peek(); got != tt.want {
+			t.Errorf("%s: peek() = %v after next returned %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAdaptiveIntervalMaxBelowMin(t *testing.T) {
+	a := newAdaptiveInterval(2*time.Second, time.Second)
+	a.next(10, true)
+	if got := a.next(10, true); got != 2*time.Second {
+		t.Errorf("expected max raised to min, got %v", got)
+	}
+}
+
+func TestNextPollIntervalDoesNotBackOff(t *testing.T) {
+	s := testService(t, "-polling-time", "1s", "-max-polling-time", "8s")
+	s.lastLux = 50
+	s.advancePoll()
+	s.advancePoll()
+	want := s.nextPollInterval()
+	if want != 2*time.Second {
+		t.Fatalf("expected one doubling after two stable polls, got %v", want)
+	}
+	for i := 0; i < 3; i++ {
+		if got := s.nextPollInterval(); got != want {
+			t.Errorf("nextPollInterval moved from %v to %v without a poll", want, got)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// remoteConfig is a tuning profile: the curve and manual levels, as their
+// flag-format strings. The one in use comes from -settings-key, or the
+// flags where the hash doesn't set a field.
+type remoteConfig struct {
+	curve  string
+	levels string
+}
+
+// probationWriteFailures is how many backlight writes in a row must fail
+// before a profile on probation is blamed for them.
+const probationWriteFailures = 3
+
+// probation tracks a profile pushed through -settings-key until it has
+// run for -settings-probation without failed writes or oscillation.
+type probation struct {
+	active        bool
+	until         time.Time
+	good          remoteConfig // the known-good profile to roll back to
+	reversals     int          // of the target's direction
+	writeFailures int          // in a row
+}
+
+// refreshRemoteConfig reads -settings-key and applies its curve and
+// manual-levels fields, so the fleet backend or the settings UI can retune
+// the display without a restart. A field that is missing falls back to
+// the flag; a profile that doesn't parse is logged and the current one
+// kept. With -settings-probation the new profile is provisional: see
+// checkProbation.
+func (s *Service) refreshRemoteConfig(ctx context.Context) {
+	key := s.Config.SettingsKey
+	fields, err := s.Redis.GetSettings(ctx, key)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to read %s: %v", key, err)
+		return
+	}
+
+	if !s.rejectedLoaded {
+		curve, levels, err := s.Redis.GetRejectedSettings(ctx)
+		s.noteRedis(ctx, err)
+		if err != nil {
+			s.recordError(ctx, "Failed to read rejected settings: %v", err)
+			return
+		}
+		s.rejected = remoteConfig{curve: curve, levels: levels}
+		s.rejectedLoaded = true
+	}
+
+	next := remoteConfig{
+		curve:  settingOr(fields, "curve", s.Config.Curve),
+		levels: settingOr(fields, "manual-levels", s.Config.ManualLevels),
+	}
+	if next == s.remote || next == s.rejected {
+		return
+	}
+	previous := s.remote
+	if err := s.applyProfile(next); err != nil {
+		s.recordError(ctx, "Invalid settings in %s: %v", key, err)
+		return
+	}
+	s.recordEvent(ctx, "settings", "Settings from %s applied: curve %q, manual levels %q", key, next.curve, next.levels)
+	s.loadLearned(ctx)
+
+	if s.Config.Probation > 0 {
+		if !s.probation.active {
+			s.probation.good = previous
+		}
+		s.probation.active = true
+		s.probation.until = time.Now().Add(s.Config.Probation)
+		s.probation.reversals = 0
+		s.probation.writeFailures = 0
+		s.publishSettingsState(ctx, "probation")
+	}
+	s.publishCapabilities(ctx)
+	s.adjustBacklight(ctx)
+}
+
+// applyProfile parses both fields of p and only then applies them, so a
+// bad field leaves the whole profile untouched. The level map is replaced,
+// never modified, so status snapshots holding the old one stay valid.
+func (s *Service) applyProfile(p remoteConfig) error {
+	curve, err := backlight.ParseCurveIn(p.curve, s.units)
+	if err != nil {
+		return fmt.Errorf("invalid curve: %v", err)
+	}
+	levels, err := parseManualLevels(s.Config, s.units, p.levels)
+	if err != nil {
+		return err
+	}
+	fades, err := parseTransitionFades(s.Config.TransitionFades, levels)
+	if err != nil {
+		return err
+	}
+	if err := s.Backlight.SetCurve(curve); err != nil {
+		return fmt.Errorf("invalid curve: %v", err)
+	}
+	s.Backlight.SetTransitionFades(levels, fades)
+	s.manualLevels = levels
+	s.levelMax.Store(int64(levelMax(levels)))
+	s.remote = p
+	return nil
+}
+
+// checkProbation accepts a provisional profile once -settings-probation
+// has passed, and rolls it back early if the target reversed direction
+// more than -settings-max-transitions times, which means a curve that
+// strobes.
+func (s *Service) checkProbation(ctx context.Context) {
+	if !s.probation.active {
+		return
+	}
+	if max := s.Config.ProbationFlips; max > 0 && s.probation.reversals > max {
+		s.rollBack(ctx, fmt.Sprintf("brightness reversed direction %d times within probation", s.probation.reversals))
+		return
+	}
+	if time.Now().After(s.probation.until) {
+		s.probation = probation{}
+		s.recordEvent(ctx, "settings", "Settings from %s accepted after probation", s.Config.SettingsKey)
+		s.publishSettingsState(ctx, "accepted")
+		s.publishCapabilities(ctx)
+	}
+}
+
+// noteProbationWrite counts backlight write failures in a row while a
+// profile is on probation and rolls it back after
+// probationWriteFailures of them; one transient error is not its fault.
+func (s *Service) noteProbationWrite(ctx context.Context, err error) {
+	if !s.probation.active {
+		return
+	}
+	if err == nil {
+		s.probation.writeFailures = 0
+		return
+	}
+	s.probation.writeFailures++
+	if s.probation.writeFailures >= probationWriteFailures {
+		s.rollBack(ctx, fmt.Sprintf("%d writes failed in a row: %v", s.probation.writeFailures, err))
+	}
+}
+
+// rollBack reverts a provisional profile to the last known-good one. The
+// rejected profile is stored so it isn't applied again, even after a
+// restart, until the hash changes.
+func (s *Service) rollBack(ctx context.Context, reason string) {
+	if !s.probation.active {
+		return
+	}
+	s.rejected = s.remote
+	err := s.Redis.SetRejectedSettings(ctx, s.rejected.curve, s.rejected.levels)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.Logger.Printf("Warning: Failed to store rejected settings: %v", err)
+	}
+	good := s.probation.good
+	s.probation = probation{}
+	if err := s.applyProfile(good); err != nil {
+		s.recordError(ctx, "Failed to roll back settings: %v", err)
+		return
+	}
+	s.recordEvent(ctx, "settings", "Warning: Settings from %s rolled back: %s", s.Config.SettingsKey, reason)
+	s.loadLearned(ctx)
+	s.publishSettingsState(ctx, "rolled-back")
+	s.publishCapabilities(ctx)
+}
+
+// publishSettingsState publishes the outcome of a settings push as the
+// settings field of backlight:status.
+func (s *Service) publishSettingsState(ctx context.Context, state string) {
+	err := s.Redis.SetSettingsState(ctx, state)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.Logger.Printf("Warning: Failed to publish settings state: %v", err)
+	}
+}
+
+// PushSettings writes a curve and manual-levels profile to -settings-key
+// and announces it, as the settings UI would, so it goes through the same
+// probation as any other push. Fields other than curve and manual-levels
+// are rejected.
+func (s *Service) PushSettings(ctx context.Context, fields map[string]string) error {
+	key := s.Config.SettingsKey
+	if key == "" {
+		return fmt.Errorf("settings pushes need -settings-key")
+	}
+	for name := range fields {
+		if name != "curve" && name != "manual-levels" {
+			return fmt.Errorf("unknown setting %q (expected curve or manual-levels)", name)
+		}
+	}
+	var err error
+	if derr := s.do(ctx, func(ctx context.Context) {
+		err = s.Redis.SetSettings(ctx, key, fields)
+		s.noteRedis(ctx, err)
+	}); derr != nil {
+		return derr
+	}
+	return err
+}
+
+// settingOr returns fields[name], or def if the field is unset or empty.
+func settingOr(fields map[string]string, name, def string) string {
+	if v := fields[name]; v != "" {
+		return v
+	}
+	return def
+}
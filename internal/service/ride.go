@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+)
+
+// rideSession collects what a ride looked like from the display's side,
+// from leaving the parked states to entering them again, for the summary
+// appended to -ride-stream.
+type rideSession struct {
+	active      bool
+	start       time.Time
+	luxMin      float64
+	luxMax      float64
+	luxSum      float64
+	readings    int
+	transitions int64
+	errors      int64
+	seconds     map[string]float64 // time at each output band, as in stats
+	band        string
+	bandSince   time.Time
+}
+
+// trackRide starts a session when the scooter leaves the parked states
+// and publishes it when it parks again. Call it after each vehicle state
+// change.
+func (s *Service) trackRide(ctx context.Context) {
+	if s.Config.RideStream == "" {
+		return
+	}
+	parked := s.parkedStates[s.vehicleState]
+	switch {
+	case !parked && !s.ride.active:
+		now := time.Now()
+		s.ride = rideSession{
+			active:    true,
+			start:     now,
+			luxMin:    math.Inf(1),
+			luxMax:    math.Inf(-1),
+			seconds:   make(map[string]float64),
+			band:      s.outputBand(s.Backlight.Output()),
+			bandSince: now,
+		}
+	case parked && s.ride.active:
+		s.publishRide(ctx)
+		s.ride = rideSession{}
+	}
+}
+
+// rideSample adds a lux reading and the current output to the session.
+func (s *Service) rideSample(lux float64) {
+	if !s.ride.active {
+		return
+	}
+	s.ride.luxMin = math.Min(s.ride.luxMin, lux)
+	s.ride.luxMax = math.Max(s.ride.luxMax, lux)
+	s.ride.luxSum += lux
+	s.ride.readings++
+	s.accrueRideTime(time.Now())
+}
+
+// accrueRideTime credits the time since the last call to the band the
+// output was in.
+func (s *Service) accrueRideTime(now time.Time) {
+	if s.ride.band != "" {
+		s.ride.seconds[s.ride.band] += now.Sub(s.ride.bandSince).Seconds()
+	}
+	s.ride.band = s.outputBand(s.Backlight.Output())
+	s.ride.bandSince = now
+}
+
+// publishRide appends the session summary to -ride-stream.
+func (s *Service) publishRide(ctx context.Context) {
+	now := time.Now()
+	s.accrueRideTime(now)
+	r := &s.ride
+	fields := map[string]interface{}{
+		"start":       r.start.UnixMilli(),
+		"end":         now.UnixMilli(),
+		"duration":    int64(now.Sub(r.start).Seconds()),
+		"readings":    r.readings,
+		"transitions": r.transitions,
+		"errors":      r.errors,
+	}
+	if r.readings > 0 {
+		fields["lux-min"] = strconv.FormatFloat(r.luxMin, 'f', 2, 64)
+		fields["lux-max"] = strconv.FormatFloat(r.luxMax, 'f', 2, 64)
+		fields["lux-avg"] = strconv.FormatFloat(r.luxSum/float64(r.readings), 'f', 2, 64)
+	}
+	for band, secs := range r.seconds {
+		fields["seconds-"+band] = int64(secs)
+	}
+	err := s.Redis.PublishRide(ctx, s.Config.RideStream, fields)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to publish ride summary: %v", err)
+		return
+	}
+	if s.Config.Debug {
+		s.Logger.Printf("Debug: Ride summary: %v", fields)
+	}
+}
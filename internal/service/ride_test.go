@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRideSession(t *testing.T) {
+	s := testService(t, "-ride-stream", "rides", "-manual-levels", "low:1000 high:8000")
+	s.Backlight.ApplyManual(8000)
+	ctx := context.Background()
+
+	s.vehicleState = "parked"
+	s.trackRide(ctx)
+	s.rideSample(5)
+	if s.ride.active || s.ride.readings != 0 {
+		t.Fatalf("expected no session while parked, got %+v", s.ride)
+	}
+
+	s.vehicleState = "ready-to-drive"
+	s.trackRide(ctx)
+	if !s.ride.active || s.ride.band != "high" {
+		t.Fatalf("expected a session in the high band, got %+v", s.ride)
+	}
+	for _, lux := range []float64{20, 5, 35} {
+		s.rideSample(lux)
+	}
+	if s.ride.readings != 3 || s.ride.luxMin != 5 || s.ride.luxMax != 35 || s.ride.luxSum != 60 {
+		t.Errorf("unexpected lux summary %+v", s.ride)
+	}
+	s.Backlight.ApplyManual(1000)
+	s.rideSample(1)
+	if s.ride.band != "low" || s.ride.seconds["high"] <= 0 {
+		t.Errorf("expected time credited to high before moving to low, got %v in %s", s.ride.seconds, s.ride.band)
+	}
+
+	// Publishing fails without Redis, but the session still ends.
+	s.vehicleState = "parked"
+	s.trackRide(ctx)
+	if s.ride.active {
+		t.Error("expected parking to end the session")
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import "time"
+
+// Sample is one poll's input and resulting brightness, kept for debugging.
+type Sample struct {
+	Time   time.Time `json:"time"`
+	Lux    float64   `json:"lux"`
+	Target int       `json:"target"`
+	Output int       `json:"output"`
+}
+
+// sampleRing keeps the most recent samples in a fixed-size ring.
+type sampleRing struct {
+	buf  []Sample
+	next int
+	full bool
+}
+
+func newSampleRing(size int) *sampleRing {
+	return &sampleRing{buf: make([]Sample, size)}
+}
+
+func (r *sampleRing) add(s Sample) {
+	r.buf[r.next] = s
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// all returns the samples oldest first.
+func (r *sampleRing) all() []Sample {
+	if !r.full {
+		return append([]Sample(nil), r.buf[:r.next]...)
+	}
+	out := make([]Sample, 0, len(r.buf))
+	out = append(out, r.buf[r.next:]...)
+	return append(out, r.buf[:r.next]...)
+}
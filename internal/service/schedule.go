@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// scheduleEntry is one row of the fallback schedule: brightness from hour
+// until the next entry.
+type scheduleEntry struct {
+	hour       int
+	brightness int
+}
+
+// schedule is the last-resort brightness table used while the sensor is
+// unusable, sorted by hour.
+type schedule []scheduleEntry
+
+// parseSchedule parses -fallback-schedule, "hour:brightness" pairs by local
+// time such as "0:20% 7:95% 19:40%", with brightness in any of u's units.
+// An empty string disables the fallback.
+func parseSchedule(s string, u backlight.Units) (schedule, error) {
+	fields := strings.Fields(s)
+	sc := make(schedule, 0, len(fields))
+	for _, f := range fields {
+		parts := strings.SplitN(f, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid entry %q (expected hour:brightness)", f)
+		}
+		hour, err := strconv.Atoi(parts[0])
+		if err != nil || hour < 0 || hour > 23 {
+			return nil, fmt.Errorf("invalid hour %q", parts[0])
+		}
+		brightness, err := u.Raw(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		sc = append(sc, scheduleEntry{hour: hour, brightness: brightness})
+	}
+	sort.Slice(sc, func(i, j int) bool { return sc[i].hour < sc[j].hour })
+	return sc, nil
+}
+
+// at returns the brightness for t. Hours before the first entry belong to
+// the last one, so the table wraps around midnight.
+func (sc schedule) at(t time.Time) int {
+	b := sc[len(sc)-1].brightness
+	for _, e := range sc {
+		if e.hour > t.Hour() {
+			break
+		}
+		b = e.brightness
+	}
+	return b
+}
+
+// sensorUnusable reports whether the fallback schedule should drive the
+// backlight: the stale-sensor fault is raised or the reading is stuck.
+// Short read failures keep holding the brightness as before.
+func (s *Service) sensorUnusable() bool {
+	return len(s.schedule) > 0 &&
+		(s.faults.active[faultSensorStale.code] || s.stuck.stuck)
+}
+
+// applyFallback drives the backlight from the fallback schedule after a
+// failed lux read. An override or a pinned mode still wins, as neither
+// needs the sensor.
+func (s *Service) applyFallback(ctx context.Context) error {
+	if s.backlightDisabled || !s.sensorUnusable() {
+		return nil
+	}
+	brightness, ok := s.overrideBrightness(ctx)
+	if !ok {
+		if brightness, ok = s.modeTarget(); !ok {
+			brightness = s.schedule.at(time.Now())
+		}
+	}
+	if !s.fallback {
+		s.fallback = true
+		s.recordEvent(ctx, "fallback", "Warning: No usable lux reading, following the fallback schedule")
+	}
+
+	err := s.Backlight.ApplyManual(brightness)
+	s.noteWrite(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to apply fallback brightness: %v", err)
+		return &CycleError{Step: "write", Err: err}
+	}
+	s.markFirstWrite(ctx)
+	s.syncFollowers(ctx)
+	return s.publishCycle(ctx, -1)
+}
+
+// leaveFallback notes that lux readings drive the backlight again.
+func (s *Service) leaveFallback(ctx context.Context) {
+	if s.fallback {
+		s.fallback = false
+		s.recordEvent(ctx, "fallback", "Lux readings usable again, leaving the fallback schedule")
+	}
+}
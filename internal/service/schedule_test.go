@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+func TestParseSchedule(t *testing.T) {
+	u := backlight.Units{Max: 10240}
+	sc, err := parseSchedule("18:50% 6:5200 0:20%", u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := schedule{{0, 2048}, {6, 5200}, {18, 5120}}
+	if len(sc) != len(want) {
+		t.Fatalf("got %v, want %v", sc, want)
+	}
+	for i := range want {
+		if sc[i] != want[i] {
+			t.Errorf("entry %d: got %v, want %v", i, sc[i], want[i])
+		}
+	}
+
+	if sc, err := parseSchedule("", u); err != nil || len(sc) != 0 {
+		t.Errorf("expected an empty schedule, got %v, %v", sc, err)
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	u := backlight.Units{Max: 10240}
+	for _, in := range []string{"6", "24:5000", "-1:5000", "x:5000", "6:-5", "6:bright", "6:120%"} {
+		if _, err := parseSchedule(in, u); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+	if _, err := parseSchedule("6:50%", backlight.Units{}); err == nil {
+		t.Error("expected percent to need a known max brightness")
+	}
+}
+
+func TestScheduleAt(t *testing.T) {
+	sc := schedule{{6, 5200}, {8, 9600}, {21, 2200}}
+	tests := []struct {
+		hour, minute int
+		want         int
+	}{
+		{0, 0, 2200}, // before the first entry wraps to the last
+		{5, 59, 2200},
+		{6, 0, 5200},
+		{7, 30, 5200},
+		{8, 0, 9600},
+		{20, 59, 9600},
+		{21, 0, 2200},
+		{23, 59, 2200},
+	}
+	for _, tt := range tests {
+		at := time.Date(2026, 10, 14, tt.hour, tt.minute, 0, 0, time.Local)
+		if got := sc.at(at); got != tt.want {
+			t.Errorf("%02d:%02d: got %d, want %d", tt.hour, tt.minute, got, tt.want)
+		}
+	}
+}
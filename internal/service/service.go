@@ -2,59 +2,189 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/librescoot/dbc-backlight-service/internal/backlight"
 	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/internal/diag"
 	redisClient "github.com/librescoot/dbc-backlight-service/internal/redis"
+	"github.com/librescoot/dbc-backlight-service/internal/sensor"
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+	"github.com/librescoot/dbc-backlight-service/pkg/filter"
 )
 
 type Service struct {
+	// BootTime is when the process started; used to measure the time to
+	// the first brightness write.
+	BootTime time.Time
+	// Early is the write WriteEarly made before New, if any; the time to
+	// the first brightness is measured from it.
+	Early                   *EarlyWrite
 	Config                  *config.Config
 	Redis                   *redisClient.Client
 	Logger                  *log.Logger
 	Backlight               *backlight.Manager
+	source                  sensor.Source    // nil reads lux from Redis
+	priority                *sensor.Priority // -sensor-sources, or nil
+	mqttSource              *namedSource     // the mqtt entry of -sensor-sources
+	activeSource            string
+	publishedSource         string
 	lastPublishedBrightness int
 	lastPublishedLux        float64
 	luxPublishMinDelta      float64
 	lastLoggedTarget        int
+	lineBuf                 []byte // reused by cycleLine
 	backlightDisabled       bool
 	overrideCh              chan struct{}
 	manualLevels            map[string]int
 	backlightMode           string
+	rawMode                 string // mode as last read from Redis, before validation
+	build                   BuildInfo
 	modeCh                  chan struct{}
+	lastRecordedTarget      int
+	targetDirection         direction
+	stats                   *stats
+	lastError               string
+	lastErrorTime           time.Time
+	firstWriteDone          bool
+	poll                    *adaptiveInterval
+	lastLux                 float64
+	vehicleCh               chan struct{}
+	vehicleState            string
+	parkedStates            map[string]bool
+	powerCh                 chan struct{}
+	sleepStates             map[string]bool
+	hibernateStates         map[string]bool
+	hibernateCh             chan struct{}
+	hibernating             bool // backlight off until the session ends
+	alarm                   alarmState
+	blinking                bool
+	blinkerOffAt            time.Time
+	blinkerCh               chan struct{}
+	headlightOn             bool
+	headlightCh             chan struct{}
+	settingsCh              chan struct{}
+	remote                  remoteConfig
+	rejected                remoteConfig // last profile rolled back
+	rejectedLoaded          bool         // rejected was read from Redis
+	probation               probation
+	fadeWait                bool // -startup-fade: dark until the dashboard is ready
+	fadeDeadline            time.Time
+	readyCh                 chan struct{}
+	units                   backlight.Units
+	gate                    *backlight.GateSink // -wait-for-ui, nil otherwise
+	burnIn                  *backlight.BurnInSink
+	burnInReduced           bool
+	uiDeadline              time.Time
+	uiCh                    chan struct{}
+	idle                    idleDim
+	boost                   interactionBoost
+	histogram               luxHistogram
+	ride                    rideSession
+	activityCh              chan struct{}
+	kickstandCh             chan struct{}
+	oscillation             oscillationGuard
+	alarmCh                 chan struct{}
+	sleeping                bool
+	lastWake                time.Time
+	shutdownPolicy          shutdownPolicy
+	adjustCh                chan struct{}
+	keyspaceCh              chan struct{}
+	lastAdjust              time.Time // start of the last adjustBacklight
+	dumpCh                  chan struct{}
+	samples                 *sampleRing
+	cmdCh                   chan func(context.Context)
+	override                override
+	statusListeners         []func(Status)
+	hooks                   *hooks
+	theme                   theme
+	lastNotified            statusKey
+	followers               []*backlight.Follower
+	faults                  *faultState
+	stuck                   stuckWatch
+	schedule                schedule
+	fallback                bool    // the fallback schedule drives the backlight
+	speed                   float64 // engine-ecu speed in km/h (-1 = unknown)
+	manualBrightness        int
+	manualCh                chan struct{}
+	biasCh                  chan struct{}
+	ownBacklight            atomic.Int32       // our backlight publishes not yet echoed
+	levelMax                atomic.Int64       // highest manual level, for MaxBrightness
+	stop                    context.CancelFunc // ends Run; nil outside it
+	fatal                   error              // why stop was called
 }
 
-func New(cfg *config.Config, logger *log.Logger, version string) (*Service, error) {
-	redis, err := redisClient.New(cfg.RedisURL, logger)
+// recentSamples is how many polls are kept for state dumps.
+const recentSamples = 20
+
+// errorRepeatInterval limits how often an identical error is recorded for
+// diagnostics, since polling failures tend to repeat every tick.
+const errorRepeatInterval = 10 * time.Second
+
+func New(cfg *config.Config, logger *log.Logger, build BuildInfo) (*Service, error) {
+	username, password, err := cfg.RedisCredentials()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Redis client: %v", err)
+		return nil, err
 	}
-
-	curve, err := backlight.ParseCurve(cfg.Curve)
+	redis, err := redisClient.New(cfg.RedisURL, logger, redisClient.Options{
+		ReadTimeout:  cfg.RedisReadTimeout,
+		WriteTimeout: cfg.RedisWriteTimeout,
+		Username:     username,
+		Password:     password,
+		DB:           cfg.RedisDB,
+		TLSCert:      cfg.RedisTLSCert,
+		TLSKey:       cfg.RedisTLSKey,
+		TLSCA:        cfg.RedisTLSCA,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("invalid curve: %v", err)
+		return nil, fmt.Errorf("failed to create Redis client: %v", err)
 	}
 
-	levels, err := backlight.ParseLevels(cfg.ManualLevels)
-	if err != nil {
-		return nil, fmt.Errorf("invalid manual-levels: %v", err)
+	v, errs := validate(cfg)
+	if len(errs) > 0 {
+		return nil, &ConfigError{errs[0]}
 	}
+	units, curve, levels := v.units, v.curve, v.levels
 
+	if cfg.Quirk != "" {
+		logger.Printf("Applied %s board quirks for %q", cfg.Quirk, cfg.Model)
+	} else if cfg.Model != "" {
+		logger.Printf("No board quirks for %q", cfg.Model)
+	}
 	logger.Printf("Backlight curve: %v", curve)
 
-	backlightManager := backlight.New(
-		cfg.SysBacklightPath,
-		logger,
-		curve,
-		cfg.RampRate,
-		cfg.LuxAlpha,
-	)
+	sink, err := NewSink(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MirrorPath != "" {
+		var mirror backlight.Sink = backlight.NewSysfsSink(cfg.MirrorPath)
+		if backlight.IsFIFO(cfg.MirrorPath) {
+			mirror = backlight.NewFIFOSink(cfg.MirrorPath)
+		}
+		sink = backlight.NewMirrorSink(sink, mirror, logger)
+	}
+	var gate *backlight.GateSink
+	if cfg.WaitForUI != "" {
+		gate = backlight.NewGateSink(sink)
+		sink = gate
+	}
+	var burnIn *backlight.BurnInSink
+	if cfg.BurnInLevel > 0 {
+		burnIn = backlight.NewBurnInSink(sink, cfg.BurnInLevel, cfg.BurnInAfter, cfg.BurnInReduce, cfg.BurnInNudge)
+		sink = burnIn
+	}
+	backlightManager, err := backlight.NewManager(sink, managerConfig(cfg, curve, levels, v.fades, v.estimator, logger))
+	if err != nil {
+		return nil, fmt.Errorf("invalid backlight configuration: %v", err)
+	}
 
 	service := &Service{
 		Config:                  cfg,
@@ -69,39 +199,520 @@ func New(cfg *config.Config, logger *log.Logger, version string) (*Service, erro
 		manualLevels:            levels,
 		backlightMode:           "auto",
 		modeCh:                  make(chan struct{}, 1),
+		lastRecordedTarget:      -1,
+		stats:                   newStats(),
+		lastLux:                 -1,
+		vehicleCh:               make(chan struct{}, 1),
+		parkedStates:            make(map[string]bool),
+		powerCh:                 make(chan struct{}, 1),
+		sleepStates:             make(map[string]bool),
+		hibernateStates:         make(map[string]bool),
+		hibernateCh:             make(chan struct{}, 1),
+		alarmCh:                 make(chan struct{}, 1),
+		blinkerCh:               make(chan struct{}, 1),
+		headlightCh:             make(chan struct{}, 1),
+		settingsCh:              make(chan struct{}, 1),
+		readyCh:                 make(chan struct{}, 1),
+		units:                   units,
+		gate:                    gate,
+		burnIn:                  burnIn,
+		uiCh:                    make(chan struct{}, 1),
+		activityCh:              make(chan struct{}, 1),
+		kickstandCh:             make(chan struct{}, 1),
+		remote:                  remoteConfig{curve: cfg.Curve, levels: cfg.ManualLevels},
+		shutdownPolicy:          v.shutdown,
+		adjustCh:                make(chan struct{}, 1),
+		keyspaceCh:              make(chan struct{}, 1),
+		dumpCh:                  make(chan struct{}, 1),
+		samples:                 newSampleRing(recentSamples),
+		cmdCh:                   make(chan func(context.Context)),
+		followers:               v.followers,
+		faults:                  newFaultState(),
+		speed:                   -1,
+		schedule:                v.fallback,
+		manualBrightness:        -1,
+		manualCh:                make(chan struct{}, 1),
+		biasCh:                  make(chan struct{}, 1),
 	}
 
-	service.Logger.Printf("dbc-backlight-service %s", version)
+	for _, state := range strings.Fields(cfg.ParkedStates) {
+		service.parkedStates[state] = true
+	}
+	for _, state := range strings.Fields(cfg.SleepStates) {
+		service.sleepStates[state] = true
+	}
+	for _, state := range strings.Fields(cfg.HibernateStates) {
+		service.hibernateStates[state] = true
+	}
+
+	if cfg.MaxPollingTime > 0 {
+		min := cfg.MinPollingTime
+		if min <= 0 {
+			min = cfg.PollingTime
+		}
+		service.poll = newAdaptiveInterval(min, cfg.MaxPollingTime)
+	}
+
+	var iio, can sensor.Source
+	if cfg.SensorPath != "" {
+		iio = iioSource(cfg, cfg.SensorPath)
+		if cfg.SensorFusion != "" {
+			fused, err := sensor.NewFusion(iio, iioSource(cfg, cfg.SensorPath2), cfg.SensorFusion, cfg.FusionWeight, cfg.FusionMaxRatio)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sensor-fusion: %v", err)
+			}
+			iio = fused
+		}
+		service.source = iio
+	}
+	if cfg.CANInterface != "" {
+		c, err := sensor.OpenCAN(sensor.CANConfig{
+			Interface: cfg.CANInterface,
+			ID:        uint32(cfg.CANID),
+			Offset:    cfg.CANOffset,
+			Size:      cfg.CANSize,
+			BigEndian: cfg.CANBigEndian,
+			Scale:     cfg.CANScale,
+		}, cfg.CANMaxAge, logger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid can-interface: %v", err)
+		}
+		can = c
+		service.source = can
+	}
+	if cfg.SensorSources != "" {
+		if err := service.setupPriority(cfg.SensorSources, iio, can); err != nil {
+			return nil, fmt.Errorf("invalid sensor-sources: %v", err)
+		}
+	}
+
+	service.theme = theme{threshold: -1, hysteresis: cfg.ThemeHysteresis}
+	if cfg.ThemeLevel != "" {
+		service.theme.threshold, _ = service.fixedLevel(modeFixed + cfg.ThemeLevel)
+	}
+
+	if cfg.HookCommand != "" {
+		service.hooks = newHooks(cfg.HookCommand, cfg.HookLevels, cfg.HookTimeout)
+		service.OnStatusChange(service.queueHook)
+	}
+
+	service.levelMax.Store(int64(levelMax(levels)))
+	service.build = build
+	service.Logger.Printf("dbc-backlight-service %s", build)
 
 	return service, nil
 }
 
+// managerConfig is the brightness engine configuration for cfg, shared by
+// the service and pipe mode so both decide alike.
+func managerConfig(cfg *config.Config, curve []backlight.Point, levels map[string]int, fades []backlight.TransitionFade, estimator filter.Estimator, logger *log.Logger) backlight.Config {
+	return backlight.Config{
+		Curve:            curve,
+		RampRate:         cfg.RampRate,
+		LuxAlpha:         cfg.LuxAlpha,
+		Estimator:        estimator,
+		MinConfidence:    cfg.MinConfidence,
+		LogLux:           cfg.LogLux,
+		LuxHysteresis:    cfg.LuxHysteresis,
+		JumpFactor:       cfg.JumpFactor,
+		TunnelFactor:     cfg.TunnelFactor,
+		TunnelWindow:     cfg.TunnelWindow,
+		FlickerRatio:     cfg.FlickerRatio,
+		FadeLevels:       levels,
+		TransitionFades:  fades,
+		MaxSlew:          cfg.MaxSlew,
+		MaxBrightness:    cfg.MaxBrightness,
+		Inverted:         cfg.InvertBrightness,
+		VerifyWrites:     cfg.VerifyWrites,
+		MinWriteInterval: cfg.MinWriteInterval,
+		BlankPath:        cfg.FBBlank,
+		Logger:           logger,
+	}
+}
+
+// UnitsFor returns the brightness units for cfg: percent of
+// -max-brightness, or of the sink's own range, and nits per -nits-table.
+func UnitsFor(cfg *config.Config) (backlight.Units, error) {
+	nits, err := backlight.ParseNitsTable(cfg.NitsTable)
+	if err != nil {
+		return backlight.Units{}, fmt.Errorf("invalid nits-table: %v", err)
+	}
+	max := cfg.MaxBrightness
+	if max <= 0 {
+		switch cfg.Sink {
+		case "sysfs":
+			max = backlight.NewSysfsSink(cfg.SysBacklightPath).Max()
+			if max <= 0 && cfg.AllowMissingHW {
+				max = memoryMax(cfg)
+			}
+		case "pwm":
+			max = cfg.PWMSteps
+		case "i2c":
+			max = cfg.I2CMax
+		}
+	}
+	return backlight.Units{Max: max, Nits: nits}, nil
+}
+
+// parseManualLevels parses a -manual-levels value, respaced by
+// -level-gamma if set.
+func parseManualLevels(cfg *config.Config, units backlight.Units, value string) (map[string]int, error) {
+	levels, err := backlight.ParseLevelsIn(value, units)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manual-levels: %v", err)
+	}
+	if cfg.LevelGamma > 0 {
+		min, max := cfg.LevelMin, cfg.LevelMax
+		for _, b := range levels {
+			if cfg.LevelMin <= 0 && (min <= 0 || b < min) {
+				min = b
+			}
+			if cfg.LevelMax <= 0 && b > max {
+				max = b
+			}
+		}
+		if levels, err = backlight.SpaceLevels(levels, min, max, cfg.LevelGamma); err != nil {
+			return nil, fmt.Errorf("invalid level-gamma: %v", err)
+		}
+	}
+	return levels, nil
+}
+
+// parseTransitionFades parses a -transition-fades value and checks that
+// every level it names is one of levels.
+func parseTransitionFades(value string, levels map[string]int) ([]backlight.TransitionFade, error) {
+	fades, err := backlight.ParseTransitionFades(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transition-fades: %v", err)
+	}
+	for _, f := range fades {
+		for _, name := range []string{f.From, f.To} {
+			if _, ok := levels[name]; !ok && name != "off" && name != "*" {
+				return nil, fmt.Errorf("invalid transition-fades: unknown level %q", name)
+			}
+		}
+	}
+	return fades, nil
+}
+
+// NewSink returns the brightness output selected by -sink. With
+// -allow-missing-hardware a device node that doesn't exist is replaced by
+// an in-memory sink; any other failure to open it is still an error.
+func NewSink(cfg *config.Config, logger *log.Logger) (backlight.Sink, error) {
+	switch cfg.Sink {
+	case "sysfs":
+		if _, err := os.Stat(cfg.SysBacklightPath); os.IsNotExist(err) && cfg.AllowMissingHW {
+			return memorySink(cfg, logger, err), nil
+		}
+		return backlight.NewSysfsSink(cfg.SysBacklightPath), nil
+	case "pwm":
+		if _, err := os.Stat(cfg.PWMChip); os.IsNotExist(err) && cfg.AllowMissingHW {
+			return memorySink(cfg, logger, err), nil
+		}
+		sink, err := backlight.NewPWMSink(cfg.PWMChip, cfg.PWMChannel, cfg.PWMPeriod, cfg.PWMSteps)
+		if err != nil {
+			return nil, &HardwareError{fmt.Errorf("invalid pwm sink: %v", err)}
+		}
+		return sink, nil
+	case "i2c":
+		if _, err := os.Stat(cfg.I2CBus); os.IsNotExist(err) && cfg.AllowMissingHW {
+			return memorySink(cfg, logger, err), nil
+		}
+		sink, err := backlight.OpenI2C(backlight.I2CConfig{
+			Bus:       cfg.I2CBus,
+			Address:   uint16(cfg.I2CAddress),
+			Register:  uint8(cfg.I2CRegister),
+			Size:      cfg.I2CSize,
+			BigEndian: cfg.I2CBigEndian,
+			Max:       cfg.I2CMax,
+		})
+		if err != nil {
+			return nil, &HardwareError{fmt.Errorf("invalid i2c sink: %v", err)}
+		}
+		return sink, nil
+	default:
+		return nil, &ConfigError{fmt.Errorf("invalid sink %q (expected sysfs, pwm or i2c)", cfg.Sink)}
+	}
+}
+
+// memorySink returns the in-memory sink standing in for a missing device
+// node, logging err, the reason it is missing.
+func memorySink(cfg *config.Config, logger *log.Logger, err error) backlight.Sink {
+	sink := backlight.NewMemorySink(memoryMax(cfg))
+	logger.Printf("Warning: Backlight hardware missing (%v), writing to memory (max %d)", err, sink.Max())
+	return sink
+}
+
+// memoryMax is the range of the in-memory sink that stands in for missing
+// hardware: -max-brightness, or what the configured sink would accept.
+func memoryMax(cfg *config.Config) int {
+	switch {
+	case cfg.MaxBrightness > 0:
+		return cfg.MaxBrightness
+	case cfg.Sink == "pwm":
+		return cfg.PWMSteps
+	case cfg.Sink == "i2c" && cfg.I2CMax > 0:
+		return cfg.I2CMax
+	}
+	return 10240
+}
+
+// Run sets the backlight up and drives it until ctx is cancelled. It
+// returns ErrRedisUnreachable if Redis stays down for -redis-give-up.
 func (s *Service) Run(ctx context.Context) error {
-	defer s.Redis.Close()
+	// s.Redis is replaced after hibernation; close whichever is current.
+	defer func() { s.Redis.Close() }()
+
+	ctx, s.stop = context.WithCancel(ctx)
+	defer s.stop()
+
+	if s.mqttSource != nil && s.mqttSource.src == nil {
+		return &ConfigError{fmt.Errorf("sensor-sources lists mqtt but -mqtt-lux-topic is not set")}
+	}
+
+	if s.hooks != nil {
+		go s.runHooks(ctx)
+	}
+
+	// Get the panel to a sensible brightness before the rest of the
+	// session: the status publishes, the subscribers and the monitor loop.
+	// A wrong display at boot is far more visible than a late log line.
+	s.bootstrap(ctx)
 
 	mode := "redis"
-	if s.Config.SensorPath != "" {
-		mode = s.Config.SensorPath
+	if s.source != nil {
+		mode = s.source.String()
 	}
 	s.Logger.Printf("Starting backlight service (poll=%v, ramp=%.0f%%, source=%s)",
 		s.Config.PollingTime, s.Config.RampRate*100, mode)
-	s.Logger.Printf("Using backlight path: %s", s.Config.SysBacklightPath)
+	s.Logger.Printf("Using %s backlight sink: %v", s.Config.Sink, s.Backlight.Sink())
 
-	go s.monitorIlluminance(ctx)
-	go s.subscribeOverride(ctx)
+	if data, err := json.Marshal(s.Config.Values()); err == nil {
+		if err := s.Redis.SetEffectiveConfig(ctx, string(data)); err != nil {
+			s.Logger.Printf("Warning: Failed to publish effective config: %v", err)
+		}
+	}
+
+	s.publishCapabilities(ctx)
 
-	<-ctx.Done()
-	return nil
+	if err := s.Redis.SetServiceState(ctx, "online"); err != nil {
+		s.Logger.Printf("Warning: Failed to publish online state: %v", err)
+	}
+
+	for s.runSession(ctx) && s.hibernate(ctx) {
+	}
+	s.shutdown()
+	return s.fatal
 }
 
-func (s *Service) monitorIlluminance(ctx context.Context) {
-	ticker := time.NewTicker(s.Config.PollingTime)
-	defer ticker.Stop()
+// runSession runs the monitor loop and the Redis subscribers until ctx is
+// cancelled or the vehicle hibernates, reporting which: true means the
+// session ended for hibernation. Run then waits out the hibernation and
+// starts a new session on the fresh Redis client, for as long as ctx lives.
+func (s *Service) runSession(ctx context.Context) bool {
+	if s.Config.LuxCache && s.source == nil {
+		if err := s.Redis.EnableLuxCache(ctx); err != nil {
+			s.Logger.Printf("Warning: Client-side lux cache unavailable, reading every poll: %v", err)
+		}
+	}
 
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	go func() {
+		s.superviseMonitor(sessionCtx)
+		close(done)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.subscribeOverride(sessionCtx)
+	}()
+	if s.Config.CommandStream != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.consumeCommandStream(sessionCtx)
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		<-done
+		return false
+	case <-s.hibernateCh:
+	}
+	cancel()
+	<-done
+	// The Redis client is about to be closed and replaced; nothing from
+	// this session may still be using it.
+	wg.Wait()
+	return true
+}
+
+// bootstrap performs the first brightness decision of Run on the fastest
+// path available. Flag parsing, logging and New still come before it, as
+// the sink and the curve depend on them. A direct sensor needs no Redis at
+// all; otherwise lux, override and mode are fetched in one pipelined round
+// trip.
+func (s *Service) bootstrap(ctx context.Context) {
+	s.beginUIWait()
+	if s.Early != nil {
+		s.Backlight.RampFromCurrent()
+		s.markFirstWrite(ctx)
+	} else if s.Config.StartupFade > 0 {
+		s.beginFadeWait(ctx)
+	} else {
+		s.applyStartupPolicy(ctx)
+	}
+
+	if s.source != nil {
+		lux, err := s.readLux(ctx)
+		if err != nil {
+			s.recordError(ctx, "Failed to read illuminance: %v", err)
+			return
+		}
+		if err := s.Backlight.AdjustBacklight(lux); err != nil {
+			s.recordError(ctx, "Failed to adjust backlight: %v", err)
+			return
+		}
+		if !s.fadeWait {
+			s.markFirstWrite(ctx)
+		}
+		return
+	}
+
+	boot, err := s.Redis.GetBootState(ctx)
+	if err != nil {
+		s.recordError(ctx, "Failed to read boot state: %v", err)
+		return
+	}
+	s.backlightMode = boot.Mode
+	s.Backlight.SetBias(s.clampBias(boot.Bias))
+	if !boot.Enabled {
+		s.backlightDisabled = true
+		if err := s.Backlight.ForceOff(); err != nil {
+			s.recordError(ctx, "Failed to force backlight off: %v", err)
+			return
+		}
+		s.markFirstWrite(ctx)
+		s.syncFollowers(ctx)
+		return
+	}
+	lux, err := s.checkIlluminance(boot.Illuminance)
+	if err != nil {
+		s.recordError(ctx, "Failed to read illuminance: %v", err)
+		return
+	}
+	s.applyLux(ctx, s.correctLux(lux))
+}
+
+// syncFollowers updates the LED devices that follow the display.
+func (s *Service) syncFollowers(ctx context.Context) {
+	if len(s.followers) == 0 {
+		return
+	}
+	max := s.Backlight.MaxBrightness()
+	if max <= 0 {
+		max = s.maxBrightness()
+	}
+	fraction := float64(s.Backlight.Output()) / float64(max)
+	for _, f := range s.followers {
+		if err := f.Follow(fraction); err != nil {
+			s.recordError(ctx, "%v", err)
+		}
+	}
+}
+
+// markFirstWrite reports the time to the first correct brightness once.
+func (s *Service) markFirstWrite(ctx context.Context) {
+	if s.firstWriteDone || (s.gate != nil && !s.gate.IsOpen()) {
+		return
+	}
+	s.firstWriteDone = true
+
+	value, at, from := s.Backlight.Output(), time.Now(), ""
+	if s.Early != nil {
+		value, at, from = s.Early.Brightness, s.Early.At, fmt.Sprintf(" from the %s, before startup", s.Early.From)
+	}
+	msg := fmt.Sprintf("First brightness %d written %v after start%s", value, at.Sub(s.BootTime).Round(time.Millisecond), from)
+	if uptime, err := systemUptime(); err == nil {
+		msg += fmt.Sprintf(" (%v after boot)", (uptime - time.Since(at)).Round(time.Millisecond))
+	}
+	s.recordEvent(ctx, "boot", "%s", msg)
+}
+
+// systemUptime returns the time since kernel boot from /proc/uptime.
+func systemUptime() (time.Duration, error) {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("malformed /proc/uptime")
+	}
+	secs, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+func (s *Service) monitorIlluminance(ctx context.Context) {
 	s.checkOverride(ctx)
 	s.refreshMode(ctx)
+	s.refreshManualBrightness(ctx)
+	s.refreshBias(ctx)
+	s.refreshVehicleState(ctx)
+	s.refreshPowerState(ctx)
+	s.refreshAlarm(ctx)
+	s.refreshBlinker(ctx)
+	s.refreshHeadlight(ctx)
+	s.refreshReady(ctx)
+	s.refreshUIFrame(ctx)
+	s.loadLearned(ctx)
 	s.adjustBacklight(ctx)
 
+	timer := time.NewTimer(s.Config.PollingTime)
+	defer timer.Stop()
+	resetTimer(timer, s.nextPollInterval())
+
+	// flushTimer fires when a write coalesced by -min-write-interval is due,
+	// independently of polling, which may be paused or failing.
+	flushTimer := time.NewTimer(time.Hour)
+	defer flushTimer.Stop()
+	resetTimer(flushTimer, 0)
+	flushArmed := false
+
+	var statsC <-chan time.Time
+	if s.Config.StatsInterval > 0 {
+		statsTicker := time.NewTicker(s.Config.StatsInterval)
+		defer statsTicker.Stop()
+		statsC = statsTicker.C
+	}
+	var burnInC <-chan time.Time
+	if s.burnIn != nil {
+		burnInTicker := time.NewTicker(time.Second)
+		defer burnInTicker.Stop()
+		burnInC = burnInTicker.C
+	}
+	var statusJSONC <-chan time.Time
+	if s.Config.StatusJSONInterval > 0 && s.Config.StatusJSONKey != "" {
+		statusJSONTicker := time.NewTicker(s.Config.StatusJSONInterval)
+		defer statusJSONTicker.Stop()
+		statusJSONC = statusJSONTicker.C
+	}
+	var aliveC <-chan time.Time
+	if s.Config.AliveKey != "" && s.Config.AliveTTL > 0 {
+		aliveTicker := time.NewTicker(s.Config.AliveTTL / 3)
+		defer aliveTicker.Stop()
+		aliveC = aliveTicker.C
+		s.beat(ctx)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -109,20 +720,207 @@ func (s *Service) monitorIlluminance(ctx context.Context) {
 		case <-s.overrideCh:
 			s.checkOverride(ctx)
 		case <-s.modeCh:
+			s.noteActivity(ctx, "mode")
 			s.refreshMode(ctx)
-		case <-ticker.C:
+		case <-s.manualCh:
+			s.noteActivity(ctx, "brightness")
+			s.refreshManualBrightness(ctx)
+		case <-s.biasCh:
+			s.noteActivity(ctx, "bias")
+			s.refreshBias(ctx)
+		case <-s.activityCh:
+			s.noteActivity(ctx, "button")
+			s.boostOnInteraction(ctx, "button")
+		case <-s.kickstandCh:
+			s.boostOnInteraction(ctx, "kickstand")
+		case <-s.readyCh:
+			s.refreshReady(ctx)
+		case <-s.uiCh:
+			s.refreshUIFrame(ctx)
+		case <-s.adjustCh:
 			s.adjustBacklight(ctx)
+			resetTimer(timer, s.nextPollInterval())
+		case <-s.keyspaceCh:
+			// Each cycle's publish fires another event, so don't cycle
+			// faster than polling would; move the next poll up instead.
+			if wait := s.Config.PollingTime - time.Since(s.lastAdjust); wait > 0 {
+				resetTimer(timer, wait)
+			} else {
+				s.adjustBacklight(ctx)
+				resetTimer(timer, s.nextPollInterval())
+			}
+		case <-s.dumpCh:
+			s.dumpState()
+		case cmd := <-s.cmdCh:
+			cmd(ctx)
+		case <-s.vehicleCh:
+			if s.refreshVehicleState(ctx) {
+				s.adjustBacklight(ctx)
+				resetTimer(timer, s.nextPollInterval())
+			}
+		case <-s.powerCh:
+			if s.refreshPowerState(ctx) {
+				resetTimer(timer, s.nextPollInterval())
+			}
+		case <-timer.C:
+			// While paused, the timer is a heartbeat for the vehicle
+			// state and the idle dimming steps; lux is left alone.
+			paused := s.pausedWhenParked()
+			if paused && s.refreshVehicleState(ctx) {
+				paused = s.pausedWhenParked()
+			}
+			if _, idle := s.idleWait(); (!paused || idle) && !s.checkClockJump(ctx) {
+				s.adjustBacklight(ctx)
+				s.advancePoll()
+			}
+			if d := s.nextPollInterval(); d > 0 {
+				timer.Reset(d)
+			}
+		case <-statsC:
+			s.publishStats(ctx)
+		case <-statusJSONC:
+			s.publishStatusJSON(ctx)
+		case <-aliveC:
+			s.beat(ctx)
+		case <-s.alarmCh:
+			s.refreshAlarm(ctx)
+		case <-s.blinkerCh:
+			was := s.blinking
+			s.refreshBlinker(ctx)
+			if s.blinking && !was {
+				s.boostOnInteraction(ctx, "turn signal")
+			}
+		case <-s.headlightCh:
+			s.refreshHeadlight(ctx)
+		case <-s.settingsCh:
+			s.refreshRemoteConfig(ctx)
+		case <-s.alarm.pulseC():
+			s.pulseAlarm(ctx)
+		case <-burnInC:
+			s.tickBurnIn(ctx)
+		case <-flushTimer.C:
+			flushArmed = false
+			err := s.Backlight.FlushPending()
+			s.noteWrite(ctx, err)
+			if err != nil {
+				s.recordError(ctx, "Failed to write backlight: %v", err)
+			}
+			s.syncFollowers(ctx)
+		}
+
+		if wait, ok := s.Backlight.PendingWrite(); ok && !flushArmed {
+			resetTimer(flushTimer, wait+time.Millisecond)
+			flushArmed = true
 		}
 	}
 }
 
+// resetTimer stops t, drains a pending tick and rearms it for d. A
+// non-positive d leaves the timer stopped.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	if d > 0 {
+		t.Reset(d)
+	}
+}
+
+// nextPollInterval returns the delay until the next poll: the fixed
+// -polling-time, or an adaptive interval when -max-polling-time is set.
+// While parked it returns the parked interval, or 0 to pause polling,
+// shortened to the next idle dimming step when one is pending.
+func (s *Service) nextPollInterval() time.Duration {
+	if s.sleeping {
+		return 0
+	}
+	d := s.pollInterval()
+	if wait, ok := s.idleWait(); ok && (d <= 0 || wait < d) {
+		return wait
+	}
+	return d
+}
+
+// pollInterval is nextPollInterval before idle dimming steps are
+// accounted for.
+func (s *Service) pollInterval() time.Duration {
+	if s.boosting() {
+		return s.Config.PollingTime
+	}
+	if s.parkedStates[s.vehicleState] {
+		if s.pausedWhenParked() {
+			// Only a heartbeat that re-reads the vehicle state, in case
+			// the publish that ends the pause is missed.
+			return s.Config.HeartbeatInterval
+		}
+		if s.Config.ParkedPolling > 0 {
+			return s.Config.ParkedPolling
+		}
+	}
+	if s.fadeWait {
+		return s.Config.PollingTime
+	}
+	settled := s.Backlight.Output() == s.Backlight.Target()
+	if s.Config.KeyspaceNotify && settled && s.source == nil {
+		// Lux changes arrive as keyspace events; polling is a heartbeat.
+		return s.Config.HeartbeatInterval
+	}
+	if s.poll == nil || s.lastLux < 0 {
+		return s.Config.PollingTime
+	}
+	return s.poll.peek()
+}
+
+// pausedWhenParked reports whether -pause-when-parked holds polling back.
+func (s *Service) pausedWhenParked() bool {
+	return s.Config.PauseWhenParked && s.parkedStates[s.vehicleState] && !s.boosting()
+}
+
+// advancePoll feeds the reading of a timer poll to the adaptive interval.
+// Only the timer path calls it: events that cycle or rearm the timer
+// without a new scheduled sample must not back polling off.
+func (s *Service) advancePoll() {
+	if s.poll == nil || s.lastLux < 0 {
+		return
+	}
+	s.poll.next(s.lastLux, s.Backlight.Output() == s.Backlight.Target())
+}
+
 func (s *Service) subscribeOverride(ctx context.Context) {
-	pubsub := s.Redis.Subscribe(ctx, "dashboard", "settings")
+	buttons := newButtonWatcher(s.Config.ButtonHold)
+	buttons.add(parseCombo(s.Config.StepUpButton), s.buttonStep(ctx, 1))
+	buttons.add(parseCombo(s.Config.StepDownButton), s.buttonStep(ctx, -1))
+
+	channels := []string{"dashboard", "settings", "vehicle", "power-manager", redisClient.CommandChannel}
+	channels = addChannel(channels, s.Config.AlarmKey)
+	channels = addChannel(channels, s.Config.HeadlightKey)
+	channels = addChannel(channels, s.Config.SettingsKey)
+	if buttons.enabled() || s.Config.IdleDim > 0 || s.Config.InteractionBoost > 0 {
+		channels = append(channels, "buttons")
+	}
+	keyspace := ""
+	if s.Config.KeyspaceNotify {
+		if err := s.Redis.EnableKeyspaceEvents(ctx); err != nil {
+			s.Logger.Printf("Warning: %v; relying on the server's existing setting", err)
+		}
+		keyspace = s.Redis.KeyspaceChannel("dashboard")
+		channels = append(channels, keyspace)
+	}
+	pubsub := s.Redis.Subscribe(ctx, channels...)
 	defer pubsub.Close()
+	// Echoes of publishes made while unsubscribed never arrive.
+	s.ownBacklight.Store(0)
 
 	// Signal initial checks
 	s.signal(s.overrideCh)
 	s.signal(s.modeCh)
+	s.signal(s.vehicleCh)
+	if s.Config.SettingsKey != "" {
+		s.signal(s.settingsCh)
+	}
 
 	ch := pubsub.Channel()
 	for {
@@ -130,16 +928,137 @@ func (s *Service) subscribeOverride(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case msg := <-ch:
+			if msg.Channel == redisClient.CommandChannel {
+				s.runCommand(ctx, msg.Payload)
+				continue
+			}
+			if msg.Channel == "buttons" {
+				buttons.handle(msg.Payload)
+				s.signal(s.activityCh)
+				continue
+			}
+			if msg.Channel == s.Config.AlarmKey {
+				s.signal(s.alarmCh)
+				continue
+			}
+			if msg.Channel == s.Config.SettingsKey {
+				s.signal(s.settingsCh)
+				continue
+			}
+			if msg.Channel == s.Config.HeadlightKey && msg.Payload == s.Config.HeadlightField {
+				s.signal(s.headlightCh)
+			}
+			if msg.Channel == keyspace {
+				// Our own dashboard writes land here too; the monitor
+				// loop holds these to -polling-time.
+				if msg.Payload == "hset" {
+					s.signal(s.keyspaceCh)
+				}
+				continue
+			}
 			switch msg.Payload {
 			case "backlight-enabled":
 				s.signal(s.overrideCh)
+			case "backlight":
+				// Another writer; the next cycle's readback tells. Our own
+				// publishes are not news.
+				if msg.Channel == "dashboard" && s.Config.UIOverride > 0 && !s.ownEcho() {
+					s.signal(s.adjustCh)
+				}
 			case "dashboard.backlight-mode":
 				s.signal(s.modeCh)
+			case "dashboard.backlight-brightness":
+				s.signal(s.manualCh)
+			case "dashboard.backlight-bias":
+				s.signal(s.biasCh)
+			case "ready":
+				if msg.Channel == "dashboard" {
+					s.signal(s.readyCh)
+				}
+			case s.Config.WaitForUI:
+				if msg.Channel == "dashboard" {
+					s.signal(s.uiCh)
+				}
+			case "blinker:state":
+				if msg.Channel == "vehicle" {
+					s.signal(s.blinkerCh)
+				}
+			case "kickstand":
+				if msg.Channel == "vehicle" {
+					s.signal(s.kickstandCh)
+				}
+			case "state":
+				switch msg.Channel {
+				case "vehicle":
+					s.signal(s.vehicleCh)
+				case "power-manager":
+					s.signal(s.powerCh)
+				}
 			}
 		}
 	}
 }
 
+// ownEcho reports whether a "backlight" message on the dashboard channel
+// is the announcement of one of our own publishes, consuming it if so.
+func (s *Service) ownEcho() bool {
+	for {
+		n := s.ownBacklight.Load()
+		if n <= 0 {
+			return false
+		}
+		if s.ownBacklight.CompareAndSwap(n, n-1) {
+			return true
+		}
+	}
+}
+
+// addChannel appends ch unless it is empty or already subscribed.
+func addChannel(channels []string, ch string) []string {
+	if ch == "" {
+		return channels
+	}
+	for _, c := range channels {
+		if c == ch {
+			return channels
+		}
+	}
+	return append(channels, ch)
+}
+
+// TriggerAdjust requests an immediate read and adjust cycle.
+func (s *Service) TriggerAdjust() {
+	s.signal(s.adjustCh)
+}
+
+// TriggerDump requests a dump of the current state to the log.
+func (s *Service) TriggerDump() {
+	s.signal(s.dumpCh)
+}
+
+// dumpState logs the current mode, brightness, curve and recent samples.
+func (s *Service) dumpState() {
+	s.Logger.Printf("State: mode=%s vehicle=%s disabled=%v smoothed-lux=%.2f target=%d output=%d",
+		s.backlightMode, s.vehicleState, s.backlightDisabled,
+		s.Backlight.SmoothedLux(), s.Backlight.Target(), s.Backlight.Output())
+	s.Logger.Printf("Curve: %v", s.Backlight.Curve())
+	s.Logger.Printf("Manual levels: %v", s.manualLevels)
+	for _, sm := range s.samples.all() {
+		s.Logger.Printf("  %s lux=%.2f target=%d output=%d",
+			sm.Time.Format("15:04:05.000"), sm.Lux, sm.Target, sm.Output)
+	}
+}
+
+// runCommand executes a command from the command channel on the monitor
+// goroutine.
+func (s *Service) runCommand(ctx context.Context, cmd string) {
+	s.do(ctx, func(ctx context.Context) {
+		if err := s.handleCommand(ctx, cmd); err != nil {
+			s.recordError(ctx, "Command %q failed: %v", cmd, err)
+		}
+	})
+}
+
 func (s *Service) signal(ch chan struct{}) {
 	select {
 	case ch <- struct{}{}:
@@ -147,73 +1066,354 @@ func (s *Service) signal(ch chan struct{}) {
 	}
 }
 
+// recordEvent logs a notable state change and records it for diagnostics.
+func (s *Service) recordEvent(ctx context.Context, kind, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	s.Logger.Print(msg)
+	s.pushEvent(ctx, kind, msg)
+}
+
+// pushEvent records an event for diagnostics without logging it.
+func (s *Service) pushEvent(ctx context.Context, kind, msg string) {
+	entry := diag.Entry{Time: time.Now(), Kind: kind, Message: msg}
+	if err := s.Redis.PushEvent(ctx, entry.Encode()); err != nil && s.Config.Debug {
+		s.Logger.Printf("Failed to record event: %v", err)
+	}
+}
+
+// recordError logs an error and records it for diagnostics, suppressing
+// identical repeats within errorRepeatInterval.
+func (s *Service) recordError(ctx context.Context, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	s.Logger.Print(msg)
+	s.stats.errors++
+	s.ride.errors++
+
+	now := time.Now()
+	if msg == s.lastError && now.Sub(s.lastErrorTime) < errorRepeatInterval {
+		return
+	}
+	s.lastError = msg
+	s.lastErrorTime = now
+
+	entry := diag.Entry{Time: now, Message: msg}
+	if err := s.Redis.PushError(ctx, entry.Encode()); err != nil && s.Config.Debug {
+		s.Logger.Printf("Failed to record error: %v", err)
+	}
+}
+
 func (s *Service) refreshMode(ctx context.Context) {
 	mode, err := s.Redis.GetBacklightMode(ctx)
 	if err != nil {
-		s.Logger.Printf("Failed to read backlight mode: %v", err)
+		s.recordError(ctx, "Failed to read backlight mode: %v", err)
 		return
 	}
+	if s.applyMode(ctx, mode) {
+		s.adjustBacklight(ctx)
+	}
+}
+
+// applyMode switches to mode, dropping any local override; it reports
+// whether it dropped one, so the caller knows to adjust.
+func (s *Service) applyMode(ctx context.Context, mode string) bool {
+	if mode == s.rawMode {
+		return false
+	}
+	s.rawMode = mode
+	if !s.validMode(mode) {
+		s.recordError(ctx, "Unknown backlight mode %q, using auto", mode)
+		mode = modeAuto
+	}
 	if mode != s.backlightMode {
 		s.backlightMode = mode
-		s.Logger.Printf("Backlight mode: %s", mode)
+		s.recordEvent(ctx, "mode", "Backlight mode: %s", mode)
+		s.notifyStatus()
+		// A mode picked on the dashboard replaces any local override.
+		if s.override.active {
+			s.override = override{}
+			s.recordEvent(ctx, "override", "Local override cleared by mode change")
+			return true
+		}
 	}
+	return false
 }
 
-func (s *Service) readLux(ctx context.Context) (float64, error) {
-	if s.Config.SensorPath != "" {
-		return s.readSensor()
+// refreshVehicleState re-reads the vehicle state and reports whether it
+// changed.
+func (s *Service) refreshVehicleState(ctx context.Context) bool {
+	state, err := s.Redis.GetVehicleState(ctx)
+	if err != nil {
+		s.recordError(ctx, "Failed to read vehicle state: %v", err)
+		return false
+	}
+	if !s.applyVehicleState(state) {
+		return false
+	}
+	s.trackRide(ctx)
+	return true
+}
+
+// applyVehicleState records the vehicle state and reports whether it
+// changed.
+func (s *Service) applyVehicleState(state string) bool {
+	if state == s.vehicleState {
+		return false
 	}
-	return s.Redis.GetIlluminanceValue(ctx)
+	s.vehicleState = state
+	if s.Config.Debug {
+		s.Logger.Printf("Debug: Vehicle state: %s", state)
+	}
+	return true
 }
 
-func (s *Service) readSensor() (float64, error) {
-	data, err := os.ReadFile(s.Config.SensorPath)
+// readLux reads a lux value from the configured source and applies the
+// per-sensor calibration.
+func (s *Service) readLux(ctx context.Context) (float64, error) {
+	var lux float64
+	var err error
+	if s.source != nil {
+		lux, err = s.source.ReadLux(ctx)
+	} else {
+		var il redisClient.Illuminance
+		if il, err = s.Redis.GetIlluminance(ctx); err == nil {
+			lux, err = s.checkIlluminance(il)
+		}
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to read sensor: %v", err)
+		return 0, err
+	}
+	return s.correctLux(lux), nil
+}
+
+// checkIlluminance returns the lux of a Redis reading, rejecting readings
+// the illumination service marked as bad or that are older than
+// -lux-max-age. Readings without a status or timestamp pass.
+func (s *Service) checkIlluminance(il redisClient.Illuminance) (float64, error) {
+	if il.Status != "" && il.Status != "ok" {
+		return 0, fmt.Errorf("sensor status is %q", il.Status)
+	}
+	if max := s.Config.LuxMaxAge; max > 0 {
+		if age := il.Age(time.Now()); age > max {
+			return 0, fmt.Errorf("illuminance reading is %v old", age.Round(time.Second))
+		}
 	}
-	return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	return il.Lux, nil
+}
+
+// correctLux applies -lux-scale and -lux-offset to a raw reading, so a
+// miscalibrated ALS can be fixed without retuning the curve.
+func (s *Service) correctLux(raw float64) float64 {
+	lux := raw*s.Config.LuxScale + s.Config.LuxOffset
+	if lux < 0 {
+		return 0
+	}
+	return lux
+}
+
+// SetSource replaces the lux source; nil reads the Redis dashboard hash.
+// With -sensor-sources listing mqtt, src fills that entry instead. It must
+// be called before Run.
+func (s *Service) SetSource(src sensor.Source) {
+	if s.mqttSource != nil {
+		s.mqttSource.src = src
+		return
+	}
+	s.source = src
 }
 
 func (s *Service) checkOverride(ctx context.Context) {
 	enabled, err := s.Redis.GetBacklightEnabled(ctx)
+	s.noteRedis(ctx, err)
 	if err != nil {
-		s.Logger.Printf("Failed to check backlight-enabled: %v", err)
+		s.recordError(ctx, "Failed to check backlight-enabled: %v", err)
 		return
 	}
+	s.applyEnabled(ctx, enabled)
+}
+
+func (s *Service) applyEnabled(ctx context.Context, enabled bool) {
 	if !enabled && !s.backlightDisabled {
 		s.backlightDisabled = true
-		if err := s.Backlight.ForceOff(); err != nil {
-			s.Logger.Printf("Failed to force backlight off: %v", err)
+		err := s.Backlight.ForceOff()
+		s.noteWrite(ctx, err)
+		if err != nil {
+			s.recordError(ctx, "Failed to force backlight off: %v", err)
 		} else {
-			s.Logger.Printf("Backlight disabled")
+			s.recordEvent(ctx, "override", "Backlight disabled")
 		}
+		s.syncFollowers(ctx)
 	} else if enabled && s.backlightDisabled {
 		s.backlightDisabled = false
-		s.Logger.Printf("Backlight enabled, resuming auto-adjustment")
+		s.recordEvent(ctx, "override", "Backlight enabled, resuming auto-adjustment")
 	}
+	s.notifyStatus()
 }
 
-func (s *Service) adjustBacklight(ctx context.Context) {
+func (s *Service) adjustBacklight(ctx context.Context) error {
+	s.lastAdjust = time.Now()
+	if s.hibernating || s.alarm.active {
+		return nil
+	}
+	if s.source == nil && !s.Redis.LuxCached() {
+		return s.adjustFromCycleState(ctx)
+	}
 	if s.backlightDisabled {
-		return
+		return nil
+	}
+
+	s.refreshSpeed(ctx)
+	if s.Config.UIOverride > 0 {
+		value, err := s.Redis.GetBacklightValue(ctx)
+		s.noteRedis(ctx, err)
+		if err == nil {
+			s.checkExternalWrite(ctx, value)
+		}
 	}
 
 	lux, err := s.readLux(ctx)
+	if s.source == nil {
+		s.noteRedis(ctx, err)
+	}
+	if err == nil {
+		err = s.checkStuck(ctx, lux)
+	}
+	s.noteLux(ctx, lux, err)
 	if err != nil {
-		s.Logger.Printf("Failed to read illuminance: %v", err)
-		return
+		s.recordError(ctx, "Failed to read illuminance: %v", err)
+		ferr := s.applyFallback(ctx)
+		s.noteSource(ctx)
+		if ferr != nil {
+			return ferr
+		}
+		return &CycleError{Step: "read", Err: err}
 	}
 
-	if level, manual := s.manualLevels[s.backlightMode]; manual {
-		if err := s.Backlight.ApplyManual(level); err != nil {
-			s.Logger.Printf("Failed to set manual backlight: %v", err)
-			return
+	err = s.applyLux(ctx, lux)
+	s.noteSource(ctx)
+	s.checkProbation(ctx)
+	s.checkOscillation(ctx)
+	s.checkFadeWait(ctx)
+	s.checkUIGate(ctx)
+	return err
+}
+
+// percent returns brightness as 0-100% of the panel's maximum, so UI code
+// doesn't need to know the raw range.
+func (s *Service) percent(brightness int) int {
+	max := s.MaxBrightness()
+	if max <= 0 {
+		return 0
+	}
+	p := (brightness*100 + max/2) / max
+	if p > 100 {
+		return 100
+	}
+	return p
+}
+
+// adjustFromCycleState reads the lux together with the state that decides
+// how it is applied, in a single Redis round trip.
+func (s *Service) adjustFromCycleState(ctx context.Context) error {
+	st, err := s.Redis.GetCycleState(ctx)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.noteLux(ctx, 0, err)
+		s.recordError(ctx, "Failed to read illuminance: %v", err)
+		if ferr := s.applyFallback(ctx); ferr != nil {
+			return ferr
+		}
+		return &CycleError{Step: "read", Err: err}
+	}
+
+	s.applyEnabled(ctx, st.Enabled)
+	s.applyMode(ctx, st.Mode)
+	if s.applyVehicleState(st.VehicleState) {
+		s.trackRide(ctx)
+	}
+	s.applyBlinker(st.Blinker)
+	s.applySpeed(st.Speed)
+	s.checkExternalWrite(ctx, st.Backlight)
+
+	lux, err := s.checkIlluminance(st.Illuminance)
+	if err == nil {
+		err = s.checkStuck(ctx, lux)
+	}
+	s.noteLux(ctx, lux, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to read illuminance: %v", err)
+		if ferr := s.applyFallback(ctx); ferr != nil {
+			return ferr
+		}
+		return &CycleError{Step: "read", Err: err}
+	}
+	if s.backlightDisabled {
+		return nil
+	}
+	err = s.applyLux(ctx, s.correctLux(lux))
+	s.checkProbation(ctx)
+	s.checkOscillation(ctx)
+	s.checkFadeWait(ctx)
+	s.checkUIGate(ctx)
+	return err
+}
+
+// applyLux drives the backlight from a lux reading and publishes the
+// result. The returned error reports a failed write or publish.
+func (s *Service) applyLux(ctx context.Context, lux float64) error {
+	s.accrueLux(lux)
+	s.rideSample(lux)
+	s.lastLux = lux
+	s.leaveFallback(ctx)
+
+	var err error
+	if brightness, ok := s.overrideBrightness(ctx); ok {
+		if err = s.Backlight.ApplyManual(brightness); err != nil {
+			s.recordError(ctx, "Failed to apply override: %v", err)
+		}
+	} else if level, boosted := s.boostTarget(ctx); boosted {
+		if err = s.Backlight.ApplyManual(level); err != nil {
+			s.recordError(ctx, "Failed to boost backlight: %v", err)
+		}
+	} else if level, idle := s.idleTarget(ctx, lux); idle {
+		if err = s.Backlight.ApplyManual(level); err != nil {
+			s.recordError(ctx, "Failed to dim idle backlight: %v", err)
+		}
+	} else if level, pinned := s.modeTarget(); pinned {
+		if err = s.Backlight.ApplyManual(level); err != nil {
+			s.recordError(ctx, "Failed to set manual backlight: %v", err)
 		}
 	} else {
-		if err := s.Backlight.AdjustBacklight(lux); err != nil {
-			s.Logger.Printf("Failed to adjust backlight: %v", err)
-			return
+		if err = s.Backlight.AdjustBacklight(s.maskBlinker(s.headlightLux(lux))); err != nil {
+			s.recordError(ctx, "Failed to adjust backlight: %v", err)
+		}
+	}
+	s.noteWrite(ctx, err)
+	if err != nil {
+		return &CycleError{Step: "write", Err: err}
+	}
+	if !s.fadeWait && !s.Backlight.Fading() {
+		s.markFirstWrite(ctx)
+	}
+	s.syncFollowers(ctx)
+
+	s.samples.add(Sample{
+		Time:   time.Now(),
+		Lux:    lux,
+		Target: s.Backlight.Target(),
+		Output: s.Backlight.Output(),
+	})
+
+	if target := s.Backlight.Target(); target != s.lastRecordedTarget {
+		if s.lastRecordedTarget >= 0 {
+			s.stats.transitions[s.effectiveLevel()]++
+			s.ride.transitions++
+			if s.targetDirection.reversed(target - s.lastRecordedTarget) {
+				s.probation.reversals++
+				s.noteReversal(ctx)
+			}
+			s.pushEvent(ctx, "transition", string(s.cycleLine("", lux, ": target ", s.lastRecordedTarget, " -> ", target)))
 		}
+		s.lastRecordedTarget = target
 	}
 
 	if s.Config.Debug {
@@ -223,37 +1423,84 @@ func (s *Service) adjustBacklight(ctx context.Context) {
 			delta = -delta
 		}
 		if delta >= 100 || s.lastLoggedTarget < 0 {
-			s.Logger.Printf("lux=%.1f mode=%s -> target %d (output %d)", lux, s.backlightMode, target, s.Backlight.Output())
+			s.Logger.Output(1, string(s.cycleLine("Debug: ", lux, " -> target ", target, ", output ", s.Backlight.Output())))
 			s.lastLoggedTarget = target
 		}
 	}
 
+	return s.publishCycle(ctx, lux)
+}
+
+// cycleLine formats "<prefix>lux=<lux> mode=<mode><sep1><a><sep2><b>" into
+// a reused buffer with strconv rather than fmt, since it runs on every
+// target change. The result is only valid until the next call.
+func (s *Service) cycleLine(prefix string, lux float64, sep1 string, a int, sep2 string, b int) []byte {
+	buf := append(s.lineBuf[:0], prefix...)
+	buf = append(buf, "lux="...)
+	buf = strconv.AppendFloat(buf, lux, 'f', 1, 64)
+	buf = append(buf, " mode="...)
+	buf = append(buf, s.backlightMode...)
+	buf = append(buf, sep1...)
+	buf = strconv.AppendInt(buf, int64(a), 10)
+	buf = append(buf, sep2...)
+	buf = strconv.AppendInt(buf, int64(b), 10)
+	s.lineBuf = buf
+	return buf
+}
+
+// publishCycle publishes the brightness, and lux when read from the sensor
+// directly, to Redis. A negative lux publishes only the brightness.
+func (s *Service) publishCycle(ctx context.Context, lux float64) error {
+	update := redisClient.CycleUpdate{Lux: -1, Backlight: -1}
+
 	// Publish lux to Redis if reading from sensor directly
-	if s.Config.SensorPath != "" {
+	if s.source != nil && lux >= 0 && !s.luxFromRedis() {
 		luxDelta := lux - s.lastPublishedLux
 		if luxDelta < 0 {
 			luxDelta = -luxDelta
 		}
 		if s.lastPublishedLux < 0 || luxDelta >= s.luxPublishMinDelta {
-			if err := s.Redis.SetIlluminanceValue(ctx, lux); err != nil {
-				s.Logger.Printf("Warning: Failed to publish lux to Redis: %v", err)
-			}
-			s.lastPublishedLux = lux
+			update.Lux = lux
 		}
 	}
 
+	s.accrueLevelTime()
+
 	// Publish backlight to Redis
 	brightness := s.Backlight.Output()
 	bDelta := brightness - s.lastPublishedBrightness
 	if bDelta < 0 {
 		bDelta = -bDelta
 	}
-
 	if bDelta >= 100 || s.lastPublishedBrightness == -1 {
-		if err := s.Redis.SetBacklightValue(ctx, brightness); err != nil {
-			s.Logger.Printf("Warning: Failed to write backlight value to Redis: %v", err)
+		update.Backlight = brightness
+		update.Percent = s.percent(brightness)
+	}
+
+	var publishErr error
+	if update.Lux >= 0 || update.Backlight >= 0 {
+		if update.Backlight >= 0 {
+			s.ownBacklight.Add(1)
+		}
+		err := s.Redis.PublishCycle(ctx, update)
+		s.noteRedis(ctx, err)
+		if err != nil {
+			if update.Backlight >= 0 {
+				s.ownBacklight.Add(-1)
+			}
+			s.Logger.Printf("Warning: Failed to publish to Redis: %v", err)
+			publishErr = &CycleError{Step: "publish", Err: err}
 		} else {
-			s.lastPublishedBrightness = brightness
+			if update.Lux >= 0 {
+				s.lastPublishedLux = update.Lux
+			}
+			if update.Backlight >= 0 {
+				s.lastPublishedBrightness = update.Backlight
+			}
 		}
 	}
+
+	s.updateTheme(ctx)
+	s.notifyStatus()
+	return publishErr
 }
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testService builds a Service on a brightness file in a temporary
+// directory. Its Redis client gives up on a dial within milliseconds, so
+// paths that record events or errors fail fast without a server.
+func testService(t *testing.T, args ...string) *Service {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "brightness")
+	if err := os.WriteFile(path, []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t, append([]string{
+		"-backlight-path", path,
+		"-max-brightness", "10240",
+		"-redis-url", "redis://127.0.0.1:1?max_retries=-1&dial_timeout=10ms",
+		"-charging-key", "",
+	}, args...)...)
+	s, err := New(cfg, log.New(io.Discard, "", 0), BuildInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCycleLine(t *testing.T) {
+	s := &Service{backlightMode: "auto"}
+	got := string(s.cycleLine("", 12.345, ": target ", 9000, " -> ", 12000))
+	if want := fmt.Sprintf("lux=%.1f mode=%s: target %d -> %d", 12.345, "auto", 9000, 12000); got != want {
+		t.Errorf("cycleLine = %q, want %q", got, want)
+	}
+	got = string(s.cycleLine("Debug: ", 0, " -> target ", 12000, ", output ", 11500))
+	if want := "Debug: lux=0.0 mode=auto -> target 12000, output 11500"; got != want {
+		t.Errorf("cycleLine = %q, want %q", got, want)
+	}
+	if n := testing.AllocsPerRun(100, func() { s.cycleLine("", 1.5, ": target ", 1, " -> ", 2) }); n != 0 {
+		t.Errorf("cycleLine allocates %v times once the buffer has grown", n)
+	}
+}
+
+func TestParseTransitionFades(t *testing.T) {
+	levels := map[string]int{"low": 1000, "high": 8000}
+	fades, err := parseTransitionFades("high>low:2s:ease-in-out low>off:500ms *>high:100ms", levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fades) != 3 || fades[0].From != "high" || fades[0].To != "low" || fades[0].For != 2*time.Second {
+		t.Errorf("unexpected fades %+v", fades)
+	}
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"high>medium:2s", `unknown level "medium"`},
+		{"dim>*:1s", `unknown level "dim"`},
+		{"high>low", "invalid transition-fades"},
+	}
+	for _, tt := range tests {
+		_, err := parseTransitionFades(tt.value, levels)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseTransitionFades(%q) = %v, want an error containing %q", tt.value, err, tt.want)
+		}
+	}
+}
+
+func TestPauseWhenParkedKeepsHeartbeat(t *testing.T) {
+	s := testService(t, "-pause-when-parked", "-heartbeat-interval", "7s", "-polling-time", "50ms")
+	s.vehicleState = "parked"
+	if !s.pausedWhenParked() || s.nextPollInterval() != 7*time.Second {
+		t.Errorf("expected the heartbeat while paused, got %v", s.nextPollInterval())
+	}
+	if !s.applyVehicleState("ready-to-drive") || s.pausedWhenParked() {
+		t.Fatal("expected ready-to-drive to end the pause")
+	}
+	if got := s.nextPollInterval(); got != 50*time.Millisecond {
+		t.Errorf("expected polling to resume at 50ms once ready, got %v", got)
+	}
+}
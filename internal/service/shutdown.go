@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+// shutdownTimeout bounds the Redis writes made after the run context has
+// already been cancelled.
+const shutdownTimeout = 2 * time.Second
+
+// shutdownPolicy describes what to do with the backlight on exit.
+type shutdownPolicy struct {
+	keep    bool
+	restore bool
+	value   int
+}
+
+// parseShutdownPolicy parses -shutdown-brightness: "keep", "restore-initial"
+// or a brightness in any of u's units.
+func parseShutdownPolicy(s string, u backlight.Units) (shutdownPolicy, error) {
+	switch s {
+	case "", "keep":
+		return shutdownPolicy{keep: true}, nil
+	case "restore-initial":
+		return shutdownPolicy{restore: true}, nil
+	}
+	value, err := u.Raw(s)
+	if err != nil {
+		return shutdownPolicy{}, fmt.Errorf("expected keep, restore-initial or a brightness value: %v", err)
+	}
+	return shutdownPolicy{value: value}, nil
+}
+
+// shutdown applies the shutdown brightness policy and marks the service
+// offline in Redis. It runs after the monitor loop has stopped.
+func (s *Service) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	s.saveLevel()
+	switch p := s.shutdownPolicy; {
+	case p.keep:
+	case p.restore:
+		if initial := s.Backlight.InitialBrightness(); initial >= 0 {
+			if err := s.Backlight.SetOutput(initial); err != nil {
+				s.Logger.Printf("Failed to restore initial brightness: %v", err)
+			} else {
+				s.Logger.Printf("Restored initial brightness %d", initial)
+			}
+		} else {
+			s.Logger.Printf("Initial brightness unknown, leaving backlight as is")
+		}
+	default:
+		if err := s.Backlight.SetOutput(p.value); err != nil {
+			s.Logger.Printf("Failed to set shutdown brightness: %v", err)
+		} else {
+			s.Logger.Printf("Set shutdown brightness %d", p.value)
+		}
+	}
+
+	s.syncFollowers(ctx)
+
+	if c, ok := s.source.(io.Closer); ok {
+		c.Close()
+	}
+
+	if s.Config.AliveKey != "" && s.Config.AliveTTL > 0 {
+		if err := s.Redis.ClearAlive(ctx, s.Config.AliveKey); err != nil {
+			s.Logger.Printf("Warning: Failed to clear %s: %v", s.Config.AliveKey, err)
+		}
+	}
+	if err := s.Redis.SetServiceState(ctx, "offline"); err != nil {
+		s.Logger.Printf("Warning: Failed to publish offline state: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/backlight"
+)
+
+func TestParseShutdownPolicy(t *testing.T) {
+	u := backlight.Units{Max: 10240}
+	tests := []struct {
+		in   string
+		want shutdownPolicy
+	}{
+		{"", shutdownPolicy{keep: true}},
+		{"keep", shutdownPolicy{keep: true}},
+		{"restore-initial", shutdownPolicy{restore: true}},
+		{"0", shutdownPolicy{value: 0}},
+		{"1300", shutdownPolicy{value: 1300}},
+		{"10%", shutdownPolicy{value: 1024}},
+	}
+	for _, tt := range tests {
+		got, err := parseShutdownPolicy(tt.in, u)
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"off", "-1", "150%", "40nits"} {
+		if _, err := parseShutdownPolicy(in, u); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
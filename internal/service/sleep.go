@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// resumeGap is how far the wall clock may run ahead of the monotonic clock
+// between two loop wake-ups before we assume the system was suspended. The
+// monotonic clock stops during suspend, so this catches resumes that no
+// power event announced.
+const resumeGap = 5 * time.Second
+
+// refreshPowerState re-reads the power manager state and pauses or resumes
+// accordingly. It reports whether the sleep state changed.
+func (s *Service) refreshPowerState(ctx context.Context) bool {
+	state, err := s.Redis.GetPowerState(ctx)
+	if err != nil {
+		s.recordError(ctx, "Failed to read power state: %v", err)
+		return false
+	}
+	if s.hibernateStates[state] {
+		s.enterHibernate(ctx, state)
+		return true
+	}
+	sleeping := s.sleepStates[state]
+	if sleeping == s.sleeping {
+		return false
+	}
+	if sleeping {
+		s.sleeping = true
+		s.recordEvent(ctx, "power", "Power state %s: pausing", state)
+	} else {
+		s.resume(ctx, "power state "+state)
+	}
+	return true
+}
+
+// resume drops state that may have gone stale while the system slept:
+// the hardware brightness is re-read, Redis settings are refreshed and an
+// adjustment runs right away.
+func (s *Service) resume(ctx context.Context, reason string) {
+	s.sleeping = false
+	s.recordEvent(ctx, "power", "Resumed (%s), resyncing", reason)
+	if err := s.Backlight.Resync(); err != nil {
+		s.recordError(ctx, "Failed to re-read backlight after resume: %v", err)
+	}
+	s.refreshSettings(ctx)
+	s.adjustBacklight(ctx)
+}
+
+// refreshSettings re-reads every Redis setting the service follows.
+func (s *Service) refreshSettings(ctx context.Context) {
+	s.checkOverride(ctx)
+	s.refreshMode(ctx)
+	s.refreshManualBrightness(ctx)
+	s.refreshBias(ctx)
+	s.refreshVehicleState(ctx)
+	s.refreshBlinker(ctx)
+	s.refreshHeadlight(ctx)
+	if s.Config.SettingsKey != "" {
+		s.refreshRemoteConfig(ctx)
+	}
+}
+
+// checkClockJump detects a suspend that happened without a power event,
+// by comparing wall and monotonic time since the previous wake-up.
+func (s *Service) checkClockJump(ctx context.Context) bool {
+	now := time.Now()
+	last := s.lastWake
+	s.lastWake = now
+	if last.IsZero() || s.sleeping {
+		return false
+	}
+	wall := now.Round(0).Sub(last.Round(0))
+	mono := now.Sub(last)
+	if wall-mono < resumeGap {
+		return false
+	}
+	s.resume(ctx, "clock jumped "+(wall-mono).Round(time.Second).String())
+	return true
+}
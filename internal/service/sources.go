@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/librescoot/dbc-backlight-service/internal/config"
+	"github.com/librescoot/dbc-backlight-service/internal/sensor"
+)
+
+// namedSource is a -sensor-sources entry. It names the source the way the
+// list does, so logs and the published active source read "can" rather
+// than a device path. The MQTT entry has no source until SetSource.
+type namedSource struct {
+	name string
+	src  sensor.Source
+}
+
+func (n *namedSource) ReadLux(ctx context.Context) (float64, error) {
+	if n.src == nil {
+		return 0, fmt.Errorf("not configured")
+	}
+	return n.src.ReadLux(ctx)
+}
+
+func (n *namedSource) String() string { return n.name }
+
+// iioSource returns the source for an IIO illuminance file, taking the
+// median of -sensor-samples readings if more than one.
+func iioSource(cfg *config.Config, path string) sensor.Source {
+	var src sensor.Source = &sensor.File{Path: path}
+	if cfg.SensorSamples > 1 {
+		src = &sensor.Median{
+			Source:   src,
+			Samples:  cfg.SensorSamples,
+			Interval: cfg.SensorInterval,
+		}
+	}
+	return src
+}
+
+// redisSource reads lux from the dashboard hash.
+type redisSource struct{ s *Service }
+
+func (r redisSource) ReadLux(ctx context.Context) (float64, error) {
+	il, err := r.s.Redis.GetIlluminance(ctx)
+	r.s.noteRedis(ctx, err)
+	if err != nil {
+		return 0, err
+	}
+	return r.s.checkIlluminance(il)
+}
+
+func (redisSource) String() string { return "redis" }
+
+// setupPriority builds the lux source from -sensor-sources, an ordered
+// list of redis, iio, can, mqtt and schedule. iio and can are the sources
+// configured by -sensor-path and -can-interface, or nil. schedule may only
+// come last; leaving it out disables the fallback schedule.
+func (s *Service) setupPriority(names string, iio, can sensor.Source) error {
+	var sources []sensor.Source
+	useSchedule := false
+	for _, name := range strings.Fields(names) {
+		if useSchedule {
+			return fmt.Errorf("schedule must be the last source")
+		}
+		entry := &namedSource{name: name}
+		switch name {
+		case "redis":
+			entry.src = redisSource{s}
+		case "iio":
+			if iio == nil {
+				return fmt.Errorf("iio needs -sensor-path")
+			}
+			entry.src = iio
+		case "can":
+			if can == nil {
+				return fmt.Errorf("can needs -can-interface")
+			}
+			entry.src = can
+		case "mqtt":
+			s.mqttSource = entry
+		case "schedule":
+			if len(s.schedule) == 0 {
+				return fmt.Errorf("schedule needs -fallback-schedule")
+			}
+			useSchedule = true
+			continue
+		default:
+			return fmt.Errorf("unknown source %q", name)
+		}
+		sources = append(sources, entry)
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("no lux source listed")
+	}
+	if !useSchedule {
+		s.schedule = nil
+	}
+	s.priority = sensor.NewPriority(s.Config.SensorRetry, sources...)
+	s.source = s.priority
+	return nil
+}
+
+// luxFromRedis reports whether the last reading came from the dashboard
+// hash, which must not be written back.
+func (s *Service) luxFromRedis() bool {
+	if s.priority == nil {
+		return s.source == nil
+	}
+	src := s.priority.Active()
+	return src != nil && src.String() == "redis"
+}
+
+// noteSource publishes which source drives the backlight whenever that
+// changes: a -sensor-sources entry, "schedule" or "none".
+func (s *Service) noteSource(ctx context.Context) {
+	if s.priority == nil {
+		return
+	}
+	name := "none"
+	if s.fallback {
+		name = "schedule"
+	} else if src := s.priority.Active(); src != nil {
+		name = src.String()
+	}
+	if name != s.activeSource {
+		s.activeSource = name
+		s.recordEvent(ctx, "source", "Lux source: %s", name)
+	}
+	if name == s.publishedSource {
+		return
+	}
+	err := s.Redis.SetActiveSource(ctx, name)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		if s.Config.Debug {
+			s.Logger.Printf("Debug: Failed to publish active lux source: %v", err)
+		}
+		return
+	}
+	s.publishedSource = name
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"strconv"
+)
+
+// Lighting changes faster at speed (tree shadows, tunnels), so the lux
+// filter and ramp are sped up in proportion to the vehicle speed and
+// slowed down when stopped: factor = speed / -speed-reference, clamped to
+// [-speed-min-factor, -speed-max-factor].
+
+// speedFactor returns the responsiveness factor for a speed in km/h.
+func (s *Service) speedFactor(speed float64) float64 {
+	f := speed / s.Config.SpeedReference
+	if f < s.Config.SpeedMinFactor {
+		return s.Config.SpeedMinFactor
+	}
+	if f > s.Config.SpeedMaxFactor {
+		return s.Config.SpeedMaxFactor
+	}
+	return f
+}
+
+// applySpeed records an engine-ecu speed value and adapts the
+// responsiveness to it. An unknown speed keeps the configured behaviour.
+func (s *Service) applySpeed(value string) {
+	s.speed = -1
+	if speed, err := strconv.ParseFloat(value, 64); err == nil && speed >= 0 {
+		s.speed = speed
+	}
+	if s.Config.SpeedReference <= 0 {
+		return
+	}
+	factor := 1.0
+	if s.speed >= 0 {
+		factor = s.speedFactor(s.speed)
+	}
+	if factor != s.Backlight.Responsiveness() {
+		s.Backlight.SetResponsiveness(factor)
+	}
+}
+
+// refreshSpeed re-reads the vehicle speed, for lux sources that don't use
+// the pipelined cycle read.
+func (s *Service) refreshSpeed(ctx context.Context) {
+	if s.Config.SpeedReference <= 0 && s.Config.StuckTimeout <= 0 {
+		return
+	}
+	value, err := s.Redis.GetSpeed(ctx)
+	if err != nil {
+		if s.Config.Debug {
+			s.Logger.Printf("Failed to read speed: %v", err)
+		}
+		return
+	}
+	s.applySpeed(value)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Startup policies, selected with -startup-policy, decide what the panel
+// shows before the first lux sample is applied.
+const (
+	startupKeep         = "keep"          // leave the hardware value, then ramp from it
+	startupMid          = "mid"           // write the medium level, then ramp from it
+	startupRestore      = "restore"       // write the last published brightness, then ramp
+	startupFirstReading = "first-reading" // jump straight to the first reading
+)
+
+func validStartupPolicy(p string) error {
+	switch p {
+	case startupKeep, startupMid, startupRestore, startupFirstReading:
+		return nil
+	}
+	return fmt.Errorf("expected keep, mid, restore or first-reading, got %q", p)
+}
+
+// applyStartupPolicy runs before the first lux sample. Every policy except
+// first-reading leaves the manager ramping from the value it settles on.
+func (s *Service) applyStartupPolicy(ctx context.Context) {
+	var value int
+	switch s.Config.StartupPolicy {
+	case startupKeep:
+		if s.Backlight.RampFromCurrent() {
+			s.Logger.Printf("Startup: keeping hardware brightness %d", s.Backlight.Output())
+		}
+		return
+	case startupMid:
+		value = s.midBrightness()
+	case startupRestore:
+		v, err := s.Redis.GetBacklightValue(ctx)
+		if err != nil {
+			s.recordError(ctx, "Failed to read persisted brightness: %v", err)
+			return
+		}
+		if v < 0 {
+			s.Logger.Printf("Startup: no persisted brightness, waiting for the first reading")
+			return
+		}
+		value = v
+	default:
+		return
+	}
+
+	err := s.Backlight.SetOutput(value)
+	s.noteWrite(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to write startup brightness: %v", err)
+		return
+	}
+	s.Backlight.RampFromCurrent()
+	s.Logger.Printf("Startup: brightness %d (%s)", value, s.Config.StartupPolicy)
+	s.markFirstWrite(ctx)
+}
+
+// midBrightness is the "medium" manual level, or the middle of the curve
+// if no such level is configured.
+func (s *Service) midBrightness() int {
+	if b, ok := s.manualLevels["medium"]; ok {
+		return b
+	}
+	curve := s.Backlight.Curve()
+	return (curve[0].Brightness + curve[len(curve)-1].Brightness) / 2
+}
+
+// beginFadeWait replaces the startup policy under -startup-fade: the panel
+// stays dark, with the curve tracking lux but the target held at 0, until
+// the dashboard reports ready or -startup-fade-timeout passes.
+func (s *Service) beginFadeWait(ctx context.Context) {
+	err := s.Backlight.SetOutput(0)
+	s.noteWrite(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to write startup brightness: %v", err)
+	}
+	s.Backlight.RampFromCurrent()
+	s.Backlight.SetHold(true)
+	s.fadeWait = true
+	s.fadeDeadline = time.Now().Add(s.Config.StartupFadeTimeout)
+	s.Logger.Printf("Startup: dark until the dashboard is ready")
+}
+
+// refreshReady starts the fade once dashboard ready is set.
+func (s *Service) refreshReady(ctx context.Context) {
+	if !s.fadeWait {
+		return
+	}
+	ready, err := s.Redis.GetDashboardFlag(ctx, "ready")
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to read dashboard ready: %v", err)
+		return
+	}
+	if ready {
+		s.startFade(ctx, "dashboard ready")
+	}
+}
+
+// checkFadeWait starts the fade anyway once -startup-fade-timeout has
+// passed without the dashboard reporting ready.
+func (s *Service) checkFadeWait(ctx context.Context) {
+	if s.fadeWait && s.Config.StartupFadeTimeout > 0 && time.Now().After(s.fadeDeadline) {
+		s.recordEvent(ctx, "boot", "Warning: Dashboard not ready after %v, fading in anyway", s.Config.StartupFadeTimeout)
+		s.startFade(ctx, "timeout")
+	}
+}
+
+// startFade releases the held target and fades up to it over
+// -startup-fade.
+func (s *Service) startFade(ctx context.Context, reason string) {
+	s.fadeWait = false
+	s.Backlight.SetHold(false)
+	s.Backlight.FadeIn(s.Config.StartupFade)
+	s.recordEvent(ctx, "boot", "Fading in over %v (%s)", s.Config.StartupFade, reason)
+	s.signal(s.adjustCh)
+}
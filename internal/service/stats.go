@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// stats holds the counters published to backlight:stats. Owned by the
+// monitor goroutine.
+type stats struct {
+	transitions map[string]int64 // target changes, by effective level
+	errors      int64
+	restarts    int64 // monitor loop restarts after a panic
+
+	seconds   map[string]float64 // time at each output band, by nearest manual level
+	band      string             // band of the output at the last accrual
+	bandSince time.Time
+}
+
+func newStats() *stats {
+	return &stats{transitions: make(map[string]int64), seconds: make(map[string]float64)}
+}
+
+// accrueLevelTime credits the time since the last call to the band the
+// output was in, then notes the current one. Bands are named after the
+// manual level nearest the output, or "off" at 0, which is what range
+// estimates need to model the panel's power draw.
+func (s *Service) accrueLevelTime() {
+	now := time.Now()
+	if s.stats.band != "" {
+		s.stats.seconds[s.stats.band] += now.Sub(s.stats.bandSince).Seconds()
+	}
+	s.stats.band = s.outputBand(s.Backlight.Output())
+	s.stats.bandSince = now
+}
+
+// outputBand names the manual level nearest brightness, "off" for 0 and
+// "" while the output is unknown.
+func (s *Service) outputBand(brightness int) string {
+	switch {
+	case brightness < 0:
+		return ""
+	case brightness == 0:
+		return "off"
+	}
+	band, best := "", -1
+	for _, name := range s.sortedLevels() {
+		d := s.manualLevels[name] - brightness
+		if d < 0 {
+			d = -d
+		}
+		if best < 0 || d < best {
+			band, best = name, d
+		}
+	}
+	return band
+}
+
+// publishStats writes the counters to Redis with a TTL of three intervals,
+// so a stopped service drops out of the uploaded stats instead of
+// reporting stale numbers. The lux histogram is flushed alongside.
+func (s *Service) publishStats(ctx context.Context) {
+	fields := map[string]interface{}{
+		"uptime":   int64(time.Since(s.BootTime).Seconds()),
+		"last-lux": strconv.FormatFloat(s.lastLux, 'f', 2, 64),
+		"output":   s.Backlight.Output(),
+		"errors":   s.stats.errors,
+		"restarts": s.stats.restarts,
+	}
+	var total int64
+	for level, n := range s.stats.transitions {
+		fields["transitions-"+level] = n
+		total += n
+	}
+	fields["transitions"] = total
+	s.accrueLevelTime()
+	for band, secs := range s.stats.seconds {
+		fields["seconds-"+band] = int64(secs)
+	}
+
+	err := s.Redis.SetStats(ctx, fields, 3*s.Config.StatsInterval)
+	s.noteRedis(ctx, err)
+	if err != nil && s.Config.Debug {
+		s.Logger.Printf("Failed to publish stats: %v", err)
+	}
+	s.flushHistogram(ctx)
+}
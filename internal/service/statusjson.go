@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// jsonStatus is the compact state object published by -status-json-interval
+// for consumers that don't want to assemble it from several hash fields.
+type jsonStatus struct {
+	Level      string          `json:"level"`
+	Brightness int             `json:"brightness"`
+	Lux        float64         `json:"lux"`
+	Mode       string          `json:"mode"`
+	Override   *OverrideStatus `json:"override"`
+	Timestamp  int64           `json:"timestamp"` // Unix milliseconds
+}
+
+// publishStatusJSON stores the status object under -status-json-key and
+// publishes it on the channel of the same name. The key expires after
+// three intervals so a stopped service doesn't leave stale state behind.
+func (s *Service) publishStatusJSON(ctx context.Context) {
+	st := s.status()
+	data, err := json.Marshal(jsonStatus{
+		Level:      st.Level,
+		Brightness: st.Output,
+		Lux:        st.Lux,
+		Mode:       st.Mode,
+		Override:   st.Override,
+		Timestamp:  time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return
+	}
+
+	err = s.Redis.PublishJSON(ctx, s.Config.StatusJSONKey, data, 3*s.Config.StatusJSONInterval)
+	s.noteRedis(ctx, err)
+	if err != nil && s.Config.Debug {
+		s.Logger.Printf("Failed to publish JSON status: %v", err)
+	}
+}
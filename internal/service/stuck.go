@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// stuckWatch notices a sensor that keeps reporting the same value. Real
+// light always varies a little while riding; a bit-identical reading for
+// -stuck-timeout means a frozen driver or a dead illumination service.
+type stuckWatch struct {
+	bits  uint64 // last reading, compared exactly
+	since time.Time
+	has   bool
+	stuck bool
+}
+
+// checkStuck records a lux reading and reports an error while it has been
+// bit-identical for -stuck-timeout of riding. Time spent with the vehicle
+// stopped or its speed unknown doesn't count, since the light barely
+// changes then.
+func (s *Service) checkStuck(ctx context.Context, lux float64) error {
+	if s.Config.StuckTimeout <= 0 {
+		return nil
+	}
+	w := &s.stuck
+	now := time.Now()
+	bits := math.Float64bits(lux)
+	if !w.has || bits != w.bits {
+		w.bits, w.since, w.has = bits, now, true
+		if w.stuck {
+			w.stuck = false
+			s.clearFault(ctx, faultSensorStuck)
+		}
+		return nil
+	}
+	if !w.stuck {
+		if s.speed <= 0 {
+			w.since = now
+			return nil
+		}
+		if now.Sub(w.since) < s.Config.StuckTimeout {
+			return nil
+		}
+		w.stuck = true
+		s.setFault(ctx, faultSensorStuck)
+	}
+	return fmt.Errorf("sensor reading stuck at %.2f lux since %s", lux, w.since.Format("15:04:05"))
+}
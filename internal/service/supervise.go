@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+)
+
+// Restart backoff for the monitor loop after a panic. A loop that stayed
+// up for monitorStableAfter starts over at the minimum.
+const (
+	monitorMinBackoff  = time.Second
+	monitorMaxBackoff  = 30 * time.Second
+	monitorStableAfter = time.Minute
+)
+
+// superviseMonitor runs the monitor loop until ctx is cancelled,
+// restarting it with backoff if it panics, so one bad tick can't leave the
+// backlight frozen while the process keeps running.
+func (s *Service) superviseMonitor(ctx context.Context) {
+	backoff := monitorMinBackoff
+	for {
+		start := time.Now()
+		if !s.runMonitor(ctx) {
+			return
+		}
+
+		s.stats.restarts++
+		if time.Since(start) > monitorStableAfter {
+			backoff = monitorMinBackoff
+		}
+		s.Logger.Printf("Restarting monitor loop in %v (restart %d)", backoff, s.stats.restarts)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > monitorMaxBackoff {
+			backoff = monitorMaxBackoff
+		}
+	}
+}
+
+// runMonitor runs the monitor loop once and reports whether it panicked.
+func (s *Service) runMonitor(ctx context.Context) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			s.recordError(ctx, "Monitor loop panic: %v", r)
+			s.Logger.Printf("%s", debug.Stack())
+		}
+	}()
+	s.monitorIlluminance(ctx)
+	return false
+}
@@ -0,0 +1,46 @@
+package service
+
+import "context"
+
+// theme tracks the dark/light UI theme hint derived from the target
+// brightness.
+type theme struct {
+	threshold  int // dark at or below this brightness (-1 = disabled)
+	hysteresis int // light again only above threshold+hysteresis
+	current    string
+	published  bool
+}
+
+// updateTheme publishes dashboard theme=dark once the target reaches the
+// -theme-level boundary and theme=light once it rises past the boundary
+// plus -theme-hysteresis, so the UI switches color schemes together with
+// the dimming without flapping around the boundary.
+func (s *Service) updateTheme(ctx context.Context) {
+	if s.theme.threshold < 0 || s.alarm.active || s.backlightDisabled {
+		return
+	}
+	target := s.Backlight.Target()
+	if target < 0 {
+		return
+	}
+	next := s.theme.current
+	switch {
+	case target <= s.theme.threshold:
+		next = "dark"
+	case target > s.theme.threshold+s.theme.hysteresis || next == "":
+		next = "light"
+	}
+	if next == s.theme.current && s.theme.published {
+		return
+	}
+	s.theme.current = next
+	err := s.Redis.SetTheme(ctx, next)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.theme.published = false
+		s.Logger.Printf("Warning: Failed to publish theme: %v", err)
+		return
+	}
+	s.theme.published = true
+	s.recordEvent(ctx, "theme", "Theme: %s (target %d)", next, target)
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var updateSnapshots = flag.Bool("update", false, "rewrite the trace snapshots")
+
+// readTrace turns a time_ms,lux trace into pipe mode input and returns the
+// flags from its "# flags:" comment, if any.
+func readTrace(path string) (string, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	var input strings.Builder
+	var flags []string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "# flags:"); ok {
+			flags = append(flags, strings.Fields(rest)...)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ms, lux, ok := strings.Cut(line, ",")
+		if !ok {
+			return "", nil, fmt.Errorf("%s:%d: expected time_ms,lux", path, n)
+		}
+		t, err := strconv.Atoi(ms)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		fmt.Fprintf(&input, "%.3f %s\n", float64(t)/1000, lux)
+	}
+	return input.String(), flags, scanner.Err()
+}
+
+// replayTrace runs a trace through pipe mode with the flag defaults plus
+// the trace's own flags and returns one line per reading on which the
+// target or brightness changed.
+func replayTrace(t *testing.T, path string) []string {
+	t.Helper()
+	input, flags, err := readTrace(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	target, brightness := -1, -1
+	for _, d := range runPipe(t, testConfig(t, flags...), "", input) {
+		if d.Target != target || d.Brightness != brightness {
+			target, brightness = d.Target, d.Brightness
+			lines = append(lines, fmt.Sprintf("%.0f %.2f %d %d", d.Time*1000, d.Lux, target, brightness))
+		}
+	}
+	return lines
+}
+
+// TestPipeTraceSnapshots replays each synthetic testdata/traces/*.csv ride
+// through pipe mode and compares the brightness changes with the matching
+// .snapshot file. Pipe mode covers the engine, the guards it configures
+// and the oscillation guard; overrides, boost and idle dimming come from
+// Redis and are not part of it. The snapshots were generated by this code,
+// so they pin the current behaviour, right or wrong, and a difference only
+// says that it changed. After an intended change, run
+// go test -run TestPipeTraceSnapshots -update and review the snapshot diff.
+func TestPipeTraceSnapshots(t *testing.T) {
+	traces, err := filepath.Glob("testdata/traces/*.csv")
+	if err != nil || len(traces) == 0 {
+		t.Fatalf("no traces found: %v", err)
+	}
+	for _, path := range traces {
+		name := strings.TrimSuffix(filepath.Base(path), ".csv")
+		t.Run(name, func(t *testing.T) {
+			got := replayTrace(t, path)
+			snapshot := strings.TrimSuffix(path, ".csv") + ".snapshot"
+
+			if *updateSnapshots {
+				data := "# regression snapshot written by -update, not a reference\n# time_ms lux target brightness\n" + strings.Join(got, "\n") + "\n"
+				if err := os.WriteFile(snapshot, []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			data, err := os.ReadFile(snapshot)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			var want []string
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				if !strings.HasPrefix(line, "#") {
+					want = append(want, line)
+				}
+			}
+
+			diffs := 0
+			for i := 0; i < len(got) || i < len(want); i++ {
+				var g, w string
+				if i < len(got) {
+					g = got[i]
+				}
+				if i < len(want) {
+					w = want[i]
+				}
+				if g != w {
+					t.Errorf("line %d: want %q, got %q", i+1, w, g)
+					if diffs++; diffs == 10 {
+						t.Fatal("too many differences")
+					}
+				}
+			}
+		})
+	}
+}
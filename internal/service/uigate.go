@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// With -wait-for-ui every backlight write is held back by s.gate until
+// the UI reports its first frame, so early boot never lights up an
+// uninitialised framebuffer. The control loop runs as usual meanwhile;
+// opening the gate writes whatever it settled on.
+
+// beginUIWait starts the -wait-for-ui-timeout clock.
+func (s *Service) beginUIWait() {
+	if s.gate == nil {
+		return
+	}
+	s.uiDeadline = time.Now().Add(s.Config.WaitForUITimeout)
+	s.Logger.Printf("Startup: holding back backlight writes until dashboard %s is set", s.Config.WaitForUI)
+}
+
+// refreshUIFrame opens the gate once the UI has set -wait-for-ui.
+func (s *Service) refreshUIFrame(ctx context.Context) {
+	if s.gate == nil || s.gate.IsOpen() {
+		return
+	}
+	drawn, err := s.Redis.GetDashboardFlag(ctx, s.Config.WaitForUI)
+	s.noteRedis(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to read dashboard %s: %v", s.Config.WaitForUI, err)
+		return
+	}
+	if drawn {
+		s.openGate(ctx, "UI drew its first frame")
+	}
+}
+
+// checkUIGate opens the gate anyway once -wait-for-ui-timeout has passed.
+func (s *Service) checkUIGate(ctx context.Context) {
+	if s.gate == nil || s.gate.IsOpen() || s.Config.WaitForUITimeout <= 0 || time.Now().Before(s.uiDeadline) {
+		return
+	}
+	s.recordEvent(ctx, "boot", "Warning: Dashboard %s not set after %v, lighting the panel anyway", s.Config.WaitForUI, s.Config.WaitForUITimeout)
+	s.openGate(ctx, "timeout")
+}
+
+// openGate lets writes through, starting with the one held back last.
+func (s *Service) openGate(ctx context.Context, reason string) {
+	wrote, err := s.gate.Open()
+	if !wrote {
+		s.recordEvent(ctx, "boot", "Backlight writes enabled (%s)", reason)
+		return
+	}
+	s.noteWrite(ctx, err)
+	if err != nil {
+		s.recordError(ctx, "Failed to write held back brightness: %v", err)
+		return
+	}
+	s.recordEvent(ctx, "boot", "Backlight writes enabled at %d (%s)", s.Backlight.Output(), reason)
+	s.syncFollowers(ctx)
+	s.markFirstWrite(ctx)
+}
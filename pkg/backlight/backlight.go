@@ -0,0 +1,751 @@
+// Package backlight turns ambient light readings into a smoothly ramped
+// display brightness.
+//
+// A Manager maps lux onto a brightness curve, filters the input, applies
+// hysteresis, jump and tunnel detection, ramps and slew-limits the output
+// and writes it to a Sink (a sysfs backlight, a PWM channel or an I2C LED
+// driver). Create one with NewManager and call Step, or AdjustBacklight
+// with readings from elsewhere, once per tick. Manager methods may be
+// called from several goroutines, such as a poll loop and a control API;
+// each call runs atomically.
+package backlight
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/filter"
+)
+
+// Point represents a lux→brightness mapping on the interpolation curve.
+type Point struct {
+	Lux        float64 `json:"lux"`
+	Brightness int     `json:"brightness"`
+}
+
+// ParseCurve parses a curve string of "lux:brightness" pairs with raw
+// brightness values.
+// Example: "0.5:1024 2:1500 5:3000 35:10240"
+func ParseCurve(s string) ([]Point, error) {
+	return ParseCurveIn(s, Units{})
+}
+
+// ParseCurveIn is ParseCurve with brightness values in any of u's units,
+// e.g. "0:5% 5:40% 80:100%".
+func ParseCurveIn(s string, u Units) ([]Point, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("curve needs at least 2 points, got %d", len(fields))
+	}
+
+	points := make([]Point, 0, len(fields))
+	for _, f := range fields {
+		parts := strings.SplitN(f, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid point %q (expected lux:brightness)", f)
+		}
+		lux, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lux value %q: %v", parts[0], err)
+		}
+		brightness, err := u.Raw(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, Point{Lux: lux, Brightness: brightness})
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Lux < points[j].Lux
+	})
+
+	return points, nil
+}
+
+// ParseLevels parses a manual level map of "name:brightness" pairs with
+// raw brightness values.
+// Example: "low:1300 medium:4000 high:10240"
+func ParseLevels(s string) (map[string]int, error) {
+	return ParseLevelsIn(s, Units{})
+}
+
+// ParseLevelsIn is ParseLevels with brightness values in any of u's
+// units, e.g. "low:40nits medium:40% high:100%".
+func ParseLevelsIn(s string, u Units) (map[string]int, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("levels must have at least one entry")
+	}
+
+	levels := make(map[string]int, len(fields))
+	for _, f := range fields {
+		parts := strings.SplitN(f, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid level %q (expected name:brightness)", f)
+		}
+		brightness, err := u.Raw(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		levels[parts[0]] = brightness
+	}
+
+	return levels, nil
+}
+
+type Manager struct {
+	mu               sync.Mutex // guards everything below except sink
+	state            State
+	now              func() time.Time
+	logger           *log.Logger
+	sink             Sink
+	curve            []Point
+	estimator        filter.Estimator
+	minConfidence    float64 // hold target while the estimate is less certain (0 = off)
+	rampRate         float64 // fraction of remaining distance per tick (0..1)
+	responsiveness   float64 // ramp and filter speed-up factor (1 = as configured)
+	targetDeadband   int     // minimum brightness change to update target (anti-flicker)
+	holdTarget       bool    // keep the current target whatever the lux
+	maxSlew          float64 // maximum brightness change per second (0 = unlimited)
+	initial          int     // hardware brightness found at startup (-1 = unknown)
+	maxBrightness    int     // sysfs max_brightness (-1 = unknown)
+	luxHysteresis    float64 // relative lux change needed to re-evaluate target (0 = off)
+	logLux           bool    // curve lux values are log10(lux) decades
+	verifyWrites     bool
+	inverted         bool   // sysfs value is maxBrightness minus the brightness
+	blankPath        string // framebuffer blank attribute ("" = no blanking)
+	blanked          bool
+	bias             int     // user offset added to curve targets
+	offsets          []int   // learned offsets added to each curve point (nil = none)
+	jumpFactor       float64 // lux ratio that skips smoothing and ramp (0 = off)
+	tunnelFactor     float64 // sustained lux drop ratio that counts as a tunnel (0 = off)
+	tunnelWindow     time.Duration
+	fadeLevels       map[string]int
+	transitionFades  []TransitionFade
+	flickerRatio     float64 // high/low ratio of alternating readings treated as pulsed lighting (0 = off)
+	mismatch         int     // last applied value that differed from the request (-1 = none)
+	writes           int64   // successful sysfs writes
+	minWriteInterval time.Duration
+	pending          int // coalesced value waiting for minWriteInterval
+	hasPending       bool
+	written          int       // brightness the sink last took (-1 = none yet)
+	lastWrite        time.Time // time of the last successful write
+}
+
+// luxHysteresisFloor is the smallest lux band used by relative hysteresis,
+// so near-dark readings aren't judged against a band of almost zero.
+const luxHysteresisFloor = 0.05
+
+// logLuxFloor is the lowest lux that log mode distinguishes; darker
+// readings (including 0) map to log10(0.01) = -2.
+const logLuxFloor = 0.01
+
+// maxSlewWindow caps the elapsed time credited to the slew limiter, so a
+// long gap between ticks can't be spent as one large jump.
+const maxSlewWindow = time.Second
+
+// New returns a Manager driving the sysfs backlight brightness file at
+// backlightPath.
+func New(backlightPath string, logger *log.Logger, curve []Point, rampRate, luxAlpha float64) *Manager {
+	return NewWithSink(NewSysfsSink(backlightPath), logger, curve, rampRate, luxAlpha)
+}
+
+// NewWithSink returns a Manager writing brightness to sink.
+func NewWithSink(sink Sink, logger *log.Logger, curve []Point, rampRate, luxAlpha float64) *Manager {
+	m := &Manager{
+		logger:         logger,
+		sink:           sink,
+		curve:          curve,
+		state:          State{Output: -1, Target: -1},
+		now:            time.Now,
+		initial:        -1,
+		maxBrightness:  -1,
+		mismatch:       -1,
+		written:        -1,
+		estimator:      filter.NewEMA(luxAlpha, 0), // smooth lux input; lower alpha is slower/less flickery
+		rampRate:       rampRate,
+		responsiveness: 1,
+		targetDeadband: 150, // ignore target changes smaller than this (anti-flicker)
+	}
+
+	if brightness, err := m.readBrightness(); err == nil {
+		m.state.Output = brightness
+		m.state.Target = brightness
+		m.initial = brightness
+		m.logger.Printf("Initialized from hardware brightness %d", brightness)
+	} else {
+		m.logger.Printf("Warning: Could not read hardware brightness: %v", err)
+	}
+
+	m.maxBrightness = sink.Max()
+
+	return m
+}
+
+// Sink returns the output the Manager writes to.
+func (m *Manager) Sink() Sink { return m.sink }
+
+// MaxBrightness returns the panel's sysfs max_brightness, or -1 if unknown.
+func (m *Manager) MaxBrightness() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.maxBrightness
+}
+
+// SetMaxBrightness overrides the max_brightness read from sysfs, for
+// drivers that report it wrongly. Values <= 0 are ignored.
+func (m *Manager) SetMaxBrightness(max int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if max > 0 {
+		m.maxBrightness = max
+	}
+}
+
+// SetInverted declares a panel that is brightest at 0, so brightness is
+// written and read as maxBrightness minus the value. It fails if the
+// maximum is unknown. Brightness read at startup is converted.
+func (m *Manager) SetInverted(inverted bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if inverted == m.inverted {
+		return nil
+	}
+	if m.maxBrightness <= 0 {
+		return fmt.Errorf("inverted brightness needs a known max brightness")
+	}
+	m.inverted = inverted
+	for _, v := range []*int{&m.state.Output, &m.state.Target, &m.initial} {
+		if *v >= 0 {
+			*v = m.maxBrightness - *v
+		}
+	}
+	return nil
+}
+
+// raw converts between brightness and the sysfs value; the mapping is
+// its own inverse.
+func (m *Manager) raw(value int) int {
+	if m.inverted {
+		return m.maxBrightness - value
+	}
+	return value
+}
+
+// Interpolate returns the brightness for a given curve input (lux, or
+// log10 lux in log mode) by linearly interpolating between the two
+// surrounding curve points.
+func (m *Manager) Interpolate(lux float64) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.interpolate(lux)
+}
+
+func (m *Manager) interpolate(lux float64) int {
+	n := len(m.curve)
+	if lux <= m.curve[0].Lux {
+		return m.pointBrightness(0)
+	}
+	if lux >= m.curve[n-1].Lux {
+		return m.pointBrightness(n - 1)
+	}
+
+	for i := 1; i < n; i++ {
+		if lux <= m.curve[i].Lux {
+			b0, b1 := m.pointBrightness(i-1), m.pointBrightness(i)
+			t := (lux - m.curve[i-1].Lux) / (m.curve[i].Lux - m.curve[i-1].Lux)
+			b := float64(b0) + t*float64(b1-b0)
+			return int(math.Round(b))
+		}
+	}
+
+	return m.pointBrightness(n - 1)
+}
+
+// SetEstimator replaces the default EMA used to smooth the curve input.
+// Its noise parameters are in curve units (log10 lux decades in log mode).
+func (m *Manager) SetEstimator(e filter.Estimator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.estimator = e
+	m.state.Filter = filter.Snapshot{}
+	if s, ok := e.(filter.Scaler); ok && m.responsiveness != 1 {
+		s.SetResponsiveness(m.responsiveness)
+	}
+}
+
+// SetResponsiveness speeds up (factor > 1) or slows down (factor < 1) the
+// ramp and, if it supports it, the lux filter. 1 restores the configured
+// behaviour.
+func (m *Manager) SetResponsiveness(factor float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if factor <= 0 {
+		factor = 1
+	}
+	m.responsiveness = factor
+	if s, ok := m.estimator.(filter.Scaler); ok {
+		s.SetResponsiveness(factor)
+	}
+}
+
+// Responsiveness returns the factor set by SetResponsiveness.
+func (m *Manager) Responsiveness() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.responsiveness
+}
+
+// SetMinConfidence holds the target whenever the estimator's confidence is
+// below c (0..1). Zero disables it.
+func (m *Manager) SetMinConfidence(c float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.minConfidence = c
+}
+
+// Confidence returns the estimator's confidence in the smoothed lux.
+func (m *Manager) Confidence() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.Confidence
+}
+
+// SetJumpFactor lets a reading that differs from the smoothed lux by at
+// least factor (e.g. 10 for a tenfold change either way) bypass the EMA and
+// the ramp, so leaving a dark garage into sunlight takes one tick instead
+// of several seconds. The slew limit still applies. Zero disables it.
+func (m *Manager) SetJumpFactor(factor float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jumpFactor = factor
+}
+
+// SetTunnelDetection treats lux that stays below the smoothed lux divided
+// by factor for window as entering a tunnel, and jumps like SetJumpFactor
+// does. Unlike the jump factor it only looks at drops, and requires them
+// to last, so a passing shadow doesn't count. A factor <= 1 disables it.
+func (m *Manager) SetTunnelDetection(factor float64, window time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tunnelFactor = factor
+	m.tunnelWindow = window
+	m.state.TunnelSince = time.Time{}
+}
+
+// SetBias sets a raw brightness offset the rider applied on top of the
+// curve. The target moves right away, bypassing the deadband, and the
+// output ramps to it on the following ticks.
+func (m *Manager) SetBias(bias int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bias = bias
+	if m.state.Initialized {
+		m.state.Target = m.biased(m.interpolate(m.state.Filter.Estimate))
+		m.state.AnchorLux = m.state.Filter.Estimate
+		m.state.HasAnchor = true
+	}
+}
+
+// Bias returns the current user brightness offset.
+func (m *Manager) Bias() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bias
+}
+
+// biased applies the bias to a curve brightness, clamped to the panel range.
+func (m *Manager) biased(b int) int {
+	b += m.bias
+	if b < 0 {
+		b = 0
+	}
+	if m.maxBrightness > 0 && b > m.maxBrightness {
+		b = m.maxBrightness
+	}
+	return b
+}
+
+// SetLogLux switches the curve to log10(lux): curve lux values are read as
+// decades (0 = 1 lux, 2 = 100 lux, 5 = 100000 lux) and smoothing happens in
+// the log domain, which matches perceived brightness far better than raw lux.
+func (m *Manager) SetLogLux(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logLux = enabled
+}
+
+// curveInput maps a lux reading onto the curve's x axis.
+func (m *Manager) curveInput(lux float64) float64 {
+	if !m.logLux {
+		return lux
+	}
+	return math.Log10(math.Max(lux, logLuxFloor))
+}
+
+// hysteresisBand returns the relative-hysteresis band around anchor in
+// curve units. In log mode a fractional change f is a constant band of
+// log10(1+f) decades.
+func (m *Manager) hysteresisBand(anchor float64) float64 {
+	if m.logLux {
+		return math.Log10(1 + m.luxHysteresis)
+	}
+	return math.Max(anchor*m.luxHysteresis, luxHysteresisFloor)
+}
+
+// SetLuxHysteresis requires lux to move by fraction (e.g. 0.1 = 10%) from
+// the reading that set the current target before the curve is consulted
+// again. Zero disables it, leaving only the brightness deadband.
+func (m *Manager) SetLuxHysteresis(fraction float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.luxHysteresis = fraction
+}
+
+// SetDeadband sets the smallest target change the curve may make, in
+// brightness units. The default is 150.
+func (m *Manager) SetDeadband(units int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.targetDeadband = units
+}
+
+// Deadband returns the current target deadband.
+func (m *Manager) Deadband() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.targetDeadband
+}
+
+// SetHold freezes the target where it is: readings still feed the filter,
+// but neither the curve, a jump nor a tunnel moves the target until the
+// hold is released. The output finishes any ramp in progress.
+func (m *Manager) SetHold(hold bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.holdTarget = hold
+}
+
+// SetSlewRate limits how fast the output may change, in brightness units
+// per second. Zero disables the limit.
+func (m *Manager) SetSlewRate(perSecond float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxSlew = perSecond
+}
+
+// ApplyManual pins the brightness to a fixed level and applies it immediately.
+// A manual selection is a deliberate user choice, so it snaps rather than
+// ramping (auto mode keeps the smooth ambient ramp via AdjustBacklight).
+// With a slew limit configured the snap is spread over successive calls.
+func (m *Manager) ApplyManual(target int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.Target = target
+	m.state.HasAnchor = false
+	step := m.limitStep(&m.state, target-m.state.Output, m.now())
+	if step == 0 {
+		return m.flushPending()
+	}
+	m.state.Output += step
+	return m.writeBrightness(m.state.Output)
+}
+
+func (m *Manager) Target() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.Target
+}
+
+// Output returns the brightness on the panel. While a value is held back
+// by the minimum write interval that is the last one written, not the
+// ramp position.
+func (m *Manager) Output() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hasPending && m.written >= 0 {
+		return m.written
+	}
+	return m.state.Output
+}
+
+// SmoothedLux returns the EMA-filtered lux, or -1 before the first reading.
+func (m *Manager) SmoothedLux() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.smoothedLux(m.state)
+}
+
+func (m *Manager) smoothedLux(st State) float64 {
+	if !st.Filter.Started {
+		return -1
+	}
+	if m.logLux {
+		return math.Pow(10, st.Filter.Estimate)
+	}
+	return st.Filter.Estimate
+}
+
+// Curve returns the lux-to-brightness curve in use.
+func (m *Manager) Curve() []Point {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.curve
+}
+
+// SetCurve replaces the lux-to-brightness curve, for retuning at runtime,
+// and drops offsets learned on the old one. Like a bias change, the target
+// moves to the new curve right away and the output ramps to it.
+func (m *Manager) SetCurve(curve []Point) error {
+	if len(curve) < 2 {
+		return fmt.Errorf("curve needs at least 2 points, got %d", len(curve))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.curve = curve
+	m.offsets = nil
+	if m.state.Initialized {
+		m.state.Target = m.biased(m.interpolate(m.state.Filter.Estimate))
+		m.state.AnchorLux = m.state.Filter.Estimate
+		m.state.HasAnchor = true
+	}
+	return nil
+}
+
+// InitialBrightness returns the hardware brightness read at startup, or -1
+// if it could not be read.
+func (m *Manager) InitialBrightness() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.initial
+}
+
+// Resync re-reads the hardware brightness and adopts it as the output,
+// e.g. after a suspend during which firmware or the driver may have reset
+// it. The next adjustment ramps from there.
+func (m *Manager) Resync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, err := m.readBrightness()
+	if err != nil {
+		return err
+	}
+	m.state.Output = value
+	m.state.HasAnchor = false
+	m.hasPending = false
+	m.state.LastTick = m.now()
+	return nil
+}
+
+// RampFromCurrent makes the first AdjustBacklight ramp from the current
+// output instead of jumping to the curve. It reports false if the output
+// is unknown.
+func (m *Manager) RampFromCurrent() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state.Output < 0 {
+		return false
+	}
+	m.state.Initialized = true
+	m.state.LastTick = m.now()
+	return true
+}
+
+// SetOutput writes a brightness immediately, bypassing ramp and slew
+// limiting. Used for one-off writes such as the shutdown policy.
+func (m *Manager) SetOutput(value int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.Output = value
+	m.state.Target = value
+	m.state.HasAnchor = false
+	m.state.LastTick = m.now()
+	return m.writeNow(value)
+}
+
+// ForceOff writes brightness 0 and updates internal state so that
+// resuming normal adjustment ramps smoothly from 0. With a blank path set
+// the display is powered down as well.
+func (m *Manager) ForceOff() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.Output = 0
+	m.state.Target = 0
+	m.state.HasAnchor = false
+	m.state.LastTick = m.now()
+	if err := m.writeNow(0); err != nil {
+		return err
+	}
+	return m.setBlank(true)
+}
+
+func (m *Manager) GetCurrentBrightness() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.readBrightness()
+}
+
+func (m *Manager) readBrightness() (int, error) {
+	value, err := m.sink.Read()
+	if err != nil {
+		return 0, err
+	}
+	return m.raw(value), nil
+}
+
+// readActual returns the brightness the hardware applied, from
+// actual_brightness where the sink has it and brightness otherwise.
+func (m *Manager) readActual() (int, error) {
+	if a, ok := m.sink.(ActualReader); ok {
+		if value, err := a.ReadActual(); err == nil {
+			return m.raw(value), nil
+		}
+	}
+	return m.readBrightness()
+}
+
+// writeRetries and writeBackoff bound how long a transient sysfs error is
+// retried within a single tick.
+const (
+	writeRetries = 3
+	writeBackoff = 5 * time.Millisecond
+)
+
+// SetVerifyWrites makes every write read the value back. Some drivers clamp
+// silently; the applied value then becomes the reported output.
+func (m *Manager) SetVerifyWrites(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.verifyWrites = enabled
+}
+
+// SetMinWriteInterval guarantees at least d between sysfs writes. Values
+// computed sooner are coalesced: only the latest is written once d has
+// passed, on the next tick. Zero disables the limit.
+func (m *Manager) SetMinWriteInterval(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.minWriteInterval = d
+}
+
+// PendingWrite reports whether a coalesced value is waiting, and how long
+// until it may be written.
+func (m *Manager) PendingWrite() (time.Duration, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.hasPending {
+		return 0, false
+	}
+	wait := m.minWriteInterval - m.now().Sub(m.lastWrite)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// writeBrightness writes value, or holds it as pending if the previous
+// write was less than the minimum write interval ago.
+func (m *Manager) writeBrightness(value int) error {
+	if m.minWriteInterval > 0 && !m.lastWrite.IsZero() && m.now().Sub(m.lastWrite) < m.minWriteInterval {
+		m.pending = value
+		m.hasPending = true
+		return nil
+	}
+	return m.writeNow(value)
+}
+
+// FlushPending writes a coalesced value once the interval has passed.
+func (m *Manager) FlushPending() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.flushPending()
+}
+
+func (m *Manager) flushPending() error {
+	if !m.hasPending {
+		return nil
+	}
+	return m.writeBrightness(m.pending)
+}
+
+// writeNow writes value to sysfs unconditionally.
+func (m *Manager) writeNow(value int) error {
+	m.hasPending = false
+	if value > 0 {
+		if err := m.setBlank(false); err != nil {
+			return err
+		}
+	}
+	raw := m.raw(value)
+	backoff := writeBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = m.sink.Write(raw)
+		if err == nil || attempt == writeRetries || !isTransient(err) {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	if err != nil {
+		return err
+	}
+	m.writes++
+	m.lastWrite = m.now()
+	m.written = value
+
+	if m.verifyWrites {
+		m.verify(value)
+	}
+	return nil
+}
+
+// Writes returns the number of successful brightness writes.
+func (m *Manager) Writes() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.writes
+}
+
+// LastWrite returns the time of the last successful brightness write, or
+// the zero time if none succeeded yet.
+func (m *Manager) LastWrite() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastWrite
+}
+
+// verify reads the brightness back and adopts the applied value when the
+// driver didn't take the requested one.
+func (m *Manager) verify(requested int) {
+	actual, err := m.readActual()
+	if err != nil {
+		m.logger.Printf("Warning: Could not verify brightness write: %v", err)
+		return
+	}
+	if actual == requested {
+		m.mismatch = -1
+		return
+	}
+	if actual != m.mismatch {
+		m.logger.Printf("Warning: driver applied brightness %d instead of %d", actual, requested)
+		m.mismatch = actual
+	}
+	if m.state.Output == requested {
+		m.state.Output = actual
+	}
+	m.written = actual
+}
+
+// isTransient reports whether a write error is worth retrying.
+func isTransient(err error) bool {
+	return errors.Is(err, syscall.EIO) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EBUSY)
+}
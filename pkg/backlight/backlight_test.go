@@ -0,0 +1,667 @@
+package backlight
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var defaultCurve = []Point{
+	{0, 400},
+	{0.5, 1300},
+	{1, 2200},
+	{2, 2900},
+	{5, 4000},
+	{10, 5200},
+	{20, 7000},
+	{35, 8600},
+	{50, 9600},
+	{80, 10240},
+}
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	tmp := t.TempDir() + "/brightness"
+	os.WriteFile(tmp, []byte("5000"), 0644)
+	logger := log.New(os.Stderr, "test: ", 0)
+	return New(tmp, logger, defaultCurve, 0.15, 0.2)
+}
+
+func TestParseCurve(t *testing.T) {
+	curve, err := ParseCurve("0.5:1024 2:1500 5:3000 35:10240")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(curve) != 4 {
+		t.Fatalf("expected 4 points, got %d", len(curve))
+	}
+	if curve[0].Lux != 0.5 || curve[0].Brightness != 1024 {
+		t.Errorf("first point: got %v", curve[0])
+	}
+}
+
+func TestParseCurveSorts(t *testing.T) {
+	curve, err := ParseCurve("35:10240 0.5:1024 5:3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if curve[0].Lux != 0.5 || curve[2].Lux != 35 {
+		t.Errorf("curve not sorted: %v", curve)
+	}
+}
+
+func TestParseCurveErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"1:100",
+		"bad:100 2:200",
+		"1:bad 2:200",
+		"nocolon 2:200",
+	}
+	for _, s := range tests {
+		if _, err := ParseCurve(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestInterpolateBelowMin(t *testing.T) {
+	m := newTestManager(t)
+	if b := m.Interpolate(-1); b != 400 {
+		t.Errorf("below min: got %d, want 400", b)
+	}
+}
+
+func TestInterpolateAboveMax(t *testing.T) {
+	m := newTestManager(t)
+	if b := m.Interpolate(200); b != 10240 {
+		t.Errorf("above max: got %d, want 10240", b)
+	}
+}
+
+func TestInterpolateExactPoints(t *testing.T) {
+	m := newTestManager(t)
+	for _, p := range defaultCurve {
+		if b := m.Interpolate(p.Lux); b != p.Brightness {
+			t.Errorf("at lux=%.1f: got %d, want %d", p.Lux, b, p.Brightness)
+		}
+	}
+}
+
+func TestInterpolateMidpoints(t *testing.T) {
+	m := newTestManager(t)
+
+	// Midpoint between 0.5:1300 and 1:2200 → lux=0.75 → brightness=1750
+	b := m.Interpolate(0.75)
+	if b != 1750 {
+		t.Errorf("midpoint 0.5-1: got %d, want 1750", b)
+	}
+}
+
+// Night readings differ by fractions of a lux; none of that may be lost
+// between the reading and the target.
+func TestSubLuxReadingsKeepResolution(t *testing.T) {
+	var targets []int
+	for _, lux := range []float64{0.1, 0.3, 0.6, 0.9} {
+		m := newTestManager(t)
+		for i := 0; i < 50; i++ {
+			m.AdjustBacklight(lux)
+		}
+		targets = append(targets, m.Target())
+	}
+	for i := 1; i < len(targets); i++ {
+		if targets[i] <= targets[i-1] {
+			t.Errorf("targets for 0.1, 0.3, 0.6 and 0.9 lux should rise, got %v", targets)
+			break
+		}
+	}
+}
+
+func TestRampGradual(t *testing.T) {
+	m := newTestManager(t)
+	// Initialize with a low lux reading
+	m.AdjustBacklight(1.0)
+
+	// Now jump to bright — should ramp, not jump
+	m.AdjustBacklight(200)
+	if m.Output() >= 10240 {
+		t.Errorf("expected gradual ramp, got instant jump to %d", m.Output())
+	}
+	initial := m.Output()
+	if initial <= m.Interpolate(1.0) {
+		t.Errorf("expected upward movement, got %d", initial)
+	}
+}
+
+func TestRampConverges(t *testing.T) {
+	m := newTestManager(t)
+	m.AdjustBacklight(1.0) // initialize
+	for i := 0; i < 200; i++ {
+		m.AdjustBacklight(200)
+	}
+	if m.Output() != 10240 {
+		t.Errorf("expected convergence to 10240, got %d", m.Output())
+	}
+}
+
+func TestRampDown(t *testing.T) {
+	m := newTestManager(t)
+	// Initialize and converge high
+	for i := 0; i < 200; i++ {
+		m.AdjustBacklight(200)
+	}
+	peak := m.Output()
+	// Now ramp down — need several ticks for EMA to converge
+	for i := 0; i < 50; i++ {
+		m.AdjustBacklight(0)
+	}
+	if m.Output() >= peak {
+		t.Errorf("expected downward movement from %d, got %d", peak, m.Output())
+	}
+}
+
+func TestWriteOnRamp(t *testing.T) {
+	m := newTestManager(t)
+	m.AdjustBacklight(1.0) // initialize
+	m.AdjustBacklight(200) // ramp towards 10240
+
+	data, _ := os.ReadFile(m.sink.(*SysfsSink).path)
+	val, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	if val <= m.Interpolate(1.0) {
+		t.Errorf("expected file to be updated during ramp, got %d", val)
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels("low:1300 medium:4000 high:10240")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 3 {
+		t.Fatalf("expected 3 levels, got %d", len(levels))
+	}
+	if levels["low"] != 1300 || levels["medium"] != 4000 || levels["high"] != 10240 {
+		t.Errorf("unexpected levels: %v", levels)
+	}
+}
+
+func TestParseLevelsErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"low",
+		"low:bad",
+		"nocolon 2:200",
+	}
+	for _, s := range tests {
+		if _, err := ParseLevels(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestApplyManualSnapsToTarget(t *testing.T) {
+	m := newTestManager(t) // hardware brightness seeded at 5000
+
+	// A manual pick applies immediately, no ramp.
+	m.ApplyManual(10240)
+	if m.Output() != 10240 {
+		t.Errorf("expected immediate snap to 10240, got %d", m.Output())
+	}
+
+	// And it writes the new level straight to the backlight file.
+	data, _ := os.ReadFile(m.sink.(*SysfsSink).path)
+	val, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	if val != 10240 {
+		t.Errorf("expected backlight file at 10240, got %d", val)
+	}
+}
+
+func TestApplyManualSnapsDown(t *testing.T) {
+	m := newTestManager(t)
+	m.ApplyManual(1300)
+	if m.Output() != 1300 {
+		t.Errorf("expected immediate snap to 1300, got %d", m.Output())
+	}
+}
+
+func TestSlewRateLimitsManualJump(t *testing.T) {
+	m := newTestManager(t) // hardware brightness seeded at 5000
+	m.SetSlewRate(1000)
+	now := time.Now()
+	m.SetClock(func() time.Time { return now })
+	m.ApplyManual(5000) // start the slew clock
+
+	now = now.Add(time.Second)
+	m.ApplyManual(10240)
+	if m.Output() >= 10240 || m.Output() <= 5000 {
+		t.Errorf("expected a limited step towards 10240, got %d", m.Output())
+	}
+}
+
+func TestSlewRateLimitsRamp(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	m.SetClock(func() time.Time { return now })
+	m.AdjustBacklight(0) // initialize at 400
+	m.AdjustBacklight(0) // start the slew clock
+	m.SetSlewRate(2000)
+
+	prev := m.Output()
+	now = now.Add(100 * time.Millisecond)
+	m.AdjustBacklight(200)
+	if step := m.Output() - prev; step <= 0 || step > 201 {
+		t.Errorf("expected step of at most ~200 per 100ms, got %d", step)
+	}
+}
+
+func TestFollowerScalesToLEDRange(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(dir+"/buttons", 0755)
+	os.WriteFile(dir+"/buttons/max_brightness", []byte("255\n"), 0644)
+
+	followers, err := ParseFollowers("buttons:0.5", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := followers[0].Follow(1.0); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(dir + "/buttons/brightness")
+	if got := strings.TrimSpace(string(data)); got != "128" {
+		t.Errorf("expected half of 255, got %s", got)
+	}
+
+	if _, err := ParseFollowers("missing:1", dir); err == nil {
+		t.Error("expected error for a device without max_brightness")
+	}
+}
+
+func TestLuxHysteresisHoldsTargetInsideBand(t *testing.T) {
+	m := newTestManager(t)
+	m.SetLuxHysteresis(0.2)
+	m.AdjustBacklight(20) // initialize at 7000
+	start := m.Target()
+
+	// 15% above the anchor stays inside the 20% band.
+	for i := 0; i < 50; i++ {
+		m.AdjustBacklight(23)
+	}
+	if m.Target() != start {
+		t.Errorf("expected target held at %d inside band, got %d", start, m.Target())
+	}
+
+	// 50% above leaves the band and the curve takes over again.
+	for i := 0; i < 50; i++ {
+		m.AdjustBacklight(30)
+	}
+	if m.Target() <= start {
+		t.Errorf("expected target to rise above %d, got %d", start, m.Target())
+	}
+}
+
+func TestLuxHysteresisReleasedByManual(t *testing.T) {
+	m := newTestManager(t)
+	m.SetLuxHysteresis(0.2)
+	m.AdjustBacklight(20)
+	m.ApplyManual(10240)
+
+	// Back in auto at the same lux, the curve must win over the manual target.
+	m.AdjustBacklight(20)
+	if m.Target() != m.Interpolate(20) {
+		t.Errorf("expected curve target %d after manual, got %d", m.Interpolate(20), m.Target())
+	}
+}
+
+func TestLogLuxUsesDecades(t *testing.T) {
+	tmp := t.TempDir() + "/brightness"
+	os.WriteFile(tmp, []byte("5000"), 0644)
+	curve := []Point{{-2, 400}, {0, 2000}, {2, 6000}, {5, 10240}}
+	m := New(tmp, log.New(os.Stderr, "test: ", 0), curve, 0.15, 0.2)
+	m.SetLogLux(true)
+
+	m.AdjustBacklight(100) // log10(100) = 2
+	if m.Output() != 6000 {
+		t.Errorf("expected 6000 at 100 lux, got %d", m.Output())
+	}
+	if lux := m.SmoothedLux(); lux < 99.9 || lux > 100.1 {
+		t.Errorf("expected smoothed lux reported as ~100, got %.2f", lux)
+	}
+}
+
+func TestBiasOffsetsCurveTarget(t *testing.T) {
+	m := newTestManager(t)
+	m.AdjustBacklight(20) // 7000
+	m.SetBias(1000)
+	if m.Target() != 8000 {
+		t.Errorf("expected biased target 8000, got %d", m.Target())
+	}
+	for i := 0; i < 200; i++ {
+		m.AdjustBacklight(20)
+	}
+	if m.Output() != 8000 {
+		t.Errorf("expected output to settle at 8000, got %d", m.Output())
+	}
+
+	m.SetBias(-10000)
+	if m.Target() != 0 {
+		t.Errorf("expected bias to clamp at 0, got %d", m.Target())
+	}
+}
+
+func TestSetCurveRetargets(t *testing.T) {
+	m := newTestManager(t)
+	m.AdjustBacklight(20) // 7000
+	if err := m.SetCurve([]Point{{0, 1000}, {40, 9000}}); err != nil {
+		t.Fatal(err)
+	}
+	if m.Target() != 5000 {
+		t.Errorf("expected target 5000 on the new curve, got %d", m.Target())
+	}
+	if err := m.SetCurve([]Point{{0, 1000}}); err == nil {
+		t.Error("expected a one-point curve to be rejected")
+	}
+	if len(m.Curve()) != 2 {
+		t.Errorf("rejected curve replaced the current one: %v", m.Curve())
+	}
+}
+
+func TestJumpFactorSkipsRamp(t *testing.T) {
+	m := newTestManager(t)
+	m.SetJumpFactor(10)
+	m.AdjustBacklight(1.0) // 2200
+
+	m.AdjustBacklight(200)
+	if m.Output() != 10240 {
+		t.Errorf("expected jump straight to 10240, got %d", m.Output())
+	}
+	if m.SmoothedLux() != 200 {
+		t.Errorf("expected smoothing reset to 200, got %.1f", m.SmoothedLux())
+	}
+
+	m.AdjustBacklight(2) // tenfold drop
+	if m.Output() != 2900 {
+		t.Errorf("expected jump down to 2900, got %d", m.Output())
+	}
+
+	// A smaller change ramps as usual.
+	m.AdjustBacklight(10)
+	if m.Output() == 2900 || m.Output() >= m.Target() {
+		t.Errorf("expected gradual ramp below the jump factor, got %d (target %d)", m.Output(), m.Target())
+	}
+}
+
+func TestTunnelDetectionNeedsSustainedDrop(t *testing.T) {
+	m := newTestManager(t)
+	m.SetTunnelDetection(10, 50*time.Millisecond)
+	m.AdjustBacklight(200) // 10240
+
+	m.AdjustBacklight(2)
+	if m.Output() == 2900 {
+		t.Fatal("expected a single dark reading to ramp, not jump")
+	}
+	time.Sleep(60 * time.Millisecond)
+	m.AdjustBacklight(200) // only a shadow
+	m.AdjustBacklight(2)
+	if m.Output() == 2900 {
+		t.Fatal("expected the drop to restart after the reading recovered")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	m.AdjustBacklight(2)
+	if m.Output() != 2900 {
+		t.Errorf("expected jump to 2900 after a sustained drop, got %d", m.Output())
+	}
+}
+
+func TestMinWriteIntervalCoalesces(t *testing.T) {
+	m := newTestManager(t) // hardware brightness seeded at 5000
+	m.SetMinWriteInterval(50 * time.Millisecond)
+
+	m.ApplyManual(6000)
+	m.ApplyManual(7000)
+	m.ApplyManual(8000)
+	if got, _ := m.GetCurrentBrightness(); got != 6000 {
+		t.Errorf("expected only the first write to reach sysfs, got %d", got)
+	}
+	if _, ok := m.PendingWrite(); !ok {
+		t.Fatal("expected a pending write")
+	}
+	if m.Output() != 6000 {
+		t.Errorf("expected Output to report the written 6000 while 8000 waits, got %d", m.Output())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := m.FlushPending(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.GetCurrentBrightness(); got != 8000 {
+		t.Errorf("expected coalesced write of 8000, got %d", got)
+	}
+	if m.Output() != 8000 {
+		t.Errorf("expected Output 8000 after the flush, got %d", m.Output())
+	}
+	if m.Writes() != 2 {
+		t.Errorf("expected 2 writes, got %d", m.Writes())
+	}
+}
+
+func TestRampFromCurrentSkipsInitialSnap(t *testing.T) {
+	m := newTestManager(t) // hardware brightness seeded at 5000
+	if !m.RampFromCurrent() {
+		t.Fatal("expected known hardware brightness")
+	}
+	m.AdjustBacklight(200)
+	if m.Output() == 10240 || m.Output() <= 5000 {
+		t.Errorf("expected a ramp up from 5000, got %d", m.Output())
+	}
+}
+
+func TestResponsivenessSpeedsUpRamp(t *testing.T) {
+	normal := newTestManager(t)
+	fast := newTestManager(t)
+	fast.SetResponsiveness(2)
+	for _, m := range []*Manager{normal, fast} {
+		m.RampFromCurrent()
+		m.AdjustBacklight(200)
+	}
+	if fast.Output() <= normal.Output() {
+		t.Errorf("expected a larger first step, got %d vs %d", fast.Output(), normal.Output())
+	}
+}
+
+func TestInvertedWritesComplement(t *testing.T) {
+	m := newTestManager(t) // hardware brightness seeded at 5000
+	if err := m.SetInverted(true); err == nil {
+		t.Error("expected error without a known max brightness")
+	}
+	m.SetMaxBrightness(10240)
+	if err := m.SetInverted(true); err != nil {
+		t.Fatal(err)
+	}
+	if m.InitialBrightness() != 5240 {
+		t.Errorf("expected initial brightness 5240, got %d", m.InitialBrightness())
+	}
+
+	m.SetOutput(1000)
+	data, _ := os.ReadFile(m.sink.(*SysfsSink).path)
+	if got := strings.TrimSpace(string(data)); got != "9240" {
+		t.Errorf("expected sysfs value 9240, got %s", got)
+	}
+	if got, _ := m.GetCurrentBrightness(); got != 1000 {
+		t.Errorf("expected brightness 1000 read back, got %d", got)
+	}
+}
+
+func TestVerifyPrefersActualBrightness(t *testing.T) {
+	m := newTestManager(t)
+	m.SetVerifyWrites(true)
+	actual := filepath.Join(filepath.Dir(m.sink.(*SysfsSink).path), "actual_brightness")
+
+	if err := m.SetOutput(4000); err != nil {
+		t.Fatal(err)
+	}
+	if m.Output() != 4000 {
+		t.Errorf("expected brightness read back without actual_brightness, got %d", m.Output())
+	}
+
+	os.WriteFile(actual, []byte("3000\n"), 0644)
+	if err := m.SetOutput(6000); err != nil {
+		t.Fatal(err)
+	}
+	if m.Output() != 3000 {
+		t.Errorf("expected the value from actual_brightness, got %d", m.Output())
+	}
+}
+
+// flakySink fails writes with errs in turn before accepting them.
+type flakySink struct {
+	memSink
+	errs     []error
+	attempts int
+}
+
+func (s *flakySink) Write(value int) error {
+	s.attempts++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return err
+	}
+	return s.memSink.Write(value)
+}
+
+func TestWriteRetriesTransientErrors(t *testing.T) {
+	eio := &os.PathError{Op: "write", Path: "brightness", Err: syscall.EIO}
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		fails    bool
+	}{
+		{name: "recovers", errs: []error{eio, syscall.EAGAIN}, attempts: 3},
+		{name: "gives up", errs: []error{eio, eio, eio, eio, eio}, attempts: writeRetries + 1, fails: true},
+		{name: "permanent", errs: []error{syscall.EACCES}, attempts: 1, fails: true},
+	}
+	for _, tt := range tests {
+		sink := &flakySink{errs: tt.errs}
+		m := NewWithSink(sink, log.New(io.Discard, "", 0), defaultCurve, 0.15, 0.2)
+		sink.attempts = 0
+		err := m.SetOutput(4000)
+		if tt.fails != (err != nil) {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if sink.attempts != tt.attempts {
+			t.Errorf("%s: expected %d attempts, got %d", tt.name, tt.attempts, sink.attempts)
+		}
+		if !tt.fails && (sink.value != 4000 || m.Writes() != 1) {
+			t.Errorf("%s: expected one write of 4000, got %d after %d writes", tt.name, sink.value, m.Writes())
+		}
+	}
+}
+
+func TestVerifyAdoptsClampedValue(t *testing.T) {
+	var logs bytes.Buffer
+	sink := &clampSink{limit: 8000}
+	m := NewWithSink(sink, log.New(&logs, "", 0), defaultCurve, 0.15, 0.2)
+
+	m.SetOutput(9000)
+	if m.Output() != 9000 {
+		t.Errorf("expected the requested value without verification, got %d", m.Output())
+	}
+
+	m.SetVerifyWrites(true)
+	m.SetOutput(9500)
+	m.SetOutput(9600)
+	if m.Output() != 8000 {
+		t.Errorf("expected the clamped value once verified, got %d", m.Output())
+	}
+	if n := strings.Count(logs.String(), "instead of"); n != 1 {
+		t.Errorf("expected the clamp to be logged once, got %d times:\n%s", n, logs.String())
+	}
+	m.SetOutput(5000)
+	if m.Output() != 5000 {
+		t.Errorf("expected an unclamped value to be kept, got %d", m.Output())
+	}
+}
+
+func TestForceOffBlanksUntilNextWrite(t *testing.T) {
+	m := newTestManager(t)
+	blank := t.TempDir() + "/blank"
+	os.WriteFile(blank, []byte("0"), 0644)
+	m.SetBlankPath(blank)
+
+	if err := m.ForceOff(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(blank); string(data) != "4" || !m.Blanked() {
+		t.Errorf("expected powerdown after ForceOff, got %q", data)
+	}
+	m.SetOutput(0)
+	if !m.Blanked() {
+		t.Error("expected a zero write to keep the display blanked")
+	}
+	m.SetOutput(1000)
+	if data, _ := os.ReadFile(blank); string(data) != "0" || m.Blanked() {
+		t.Errorf("expected unblank before a non-zero write, got %q", data)
+	}
+}
+
+func TestConcurrentControlPaths(t *testing.T) {
+	m := newTestManager(t)
+	m.AdjustBacklight(10)
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				fn(i)
+			}
+		}()
+	}
+	run(func(i int) { m.AdjustBacklight(float64(i % 50)) })
+	run(func(i int) { m.ApplyManual(1000 + i*10) })
+	run(func(i int) { m.SetBias(i%5*100 - 200) })
+	run(func(i int) { m.SetResponsiveness(1 + float64(i%3)) })
+	run(func(int) {
+		m.Output()
+		m.Target()
+		m.SmoothedLux()
+		m.Confidence()
+		m.State()
+	})
+	wg.Wait()
+
+	if got, _ := m.GetCurrentBrightness(); got != m.Output() {
+		t.Errorf("expected the last write %d to match the output, got %d", m.Output(), got)
+	}
+}
+
+func TestWaitForDeviceAppears(t *testing.T) {
+	path := t.TempDir() + "/backlight/brightness"
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("5000"), 0644)
+	}()
+	if err := WaitForDevice(context.Background(), path, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForDeviceTimesOut(t *testing.T) {
+	path := t.TempDir() + "/missing"
+	if err := WaitForDevice(context.Background(), path, 30*time.Millisecond); err == nil {
+		t.Error("expected timeout error")
+	}
+}
@@ -0,0 +1,113 @@
+package backlight
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// benchLux alternates between readings that keep the ramp busy.
+var benchLux = []float64{3, 4.5, 40, 38, 12, 0.8}
+
+func benchManager(b *testing.B, sink Sink) *Manager {
+	b.Helper()
+	m, err := NewManager(sink, Config{
+		Curve:        defaultCurve,
+		RampRate:     0.05,
+		LuxAlpha:     0.1,
+		TunnelFactor: 8,
+		TunnelWindow: time.Second,
+		Logger:       log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return m
+}
+
+func BenchmarkDecide(b *testing.B) {
+	m := benchManager(b, &memSink{value: 5000})
+	st := m.State()
+	now := time.Unix(0, 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		now = now.Add(time.Second)
+		st, _ = m.Decide(st, benchLux[i%len(benchLux)], now)
+	}
+}
+
+func BenchmarkAdjustBacklight(b *testing.B) {
+	m := benchManager(b, &memSink{value: 5000})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.AdjustBacklight(benchLux[i%len(benchLux)])
+	}
+}
+
+// BenchmarkAdjustBacklightSysfs includes the brightness file write.
+func BenchmarkAdjustBacklightSysfs(b *testing.B) {
+	path := b.TempDir() + "/brightness"
+	os.WriteFile(path, []byte("5000"), 0644)
+	m := benchManager(b, NewSysfsSink(path))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.AdjustBacklight(benchLux[i%len(benchLux)])
+	}
+}
+
+func BenchmarkSinkWrite(b *testing.B) {
+	path := b.TempDir() + "/brightness"
+	pwm, err := NewPWMSink(fakePWMChip(b, "0"), 0, 50*time.Microsecond, 10240)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sinks := []struct {
+		name string
+		sink Sink
+	}{
+		{"sysfs", NewSysfsSink(path)},
+		{"pwm", pwm},
+		{"i2c", &I2CSink{cfg: I2CConfig{Register: 0x10, Size: 2, Max: 4095}, dev: &fakeI2C{}}},
+	}
+	for _, s := range sinks {
+		b.Run(s.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.sink.Write(i & 0xfff)
+			}
+		})
+	}
+}
+
+// The 1Hz control loop runs for the whole ride; a tick that ramps and
+// writes the brightness file must not allocate.
+func TestTickDoesNotAllocate(t *testing.T) {
+	path := t.TempDir() + "/brightness"
+	os.WriteFile(path, []byte("5000"), 0644)
+	m, err := NewManager(NewSysfsSink(path), Config{
+		Curve:    defaultCurve,
+		RampRate: 0.05,
+		LuxAlpha: 0.1,
+		Logger:   log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AdjustBacklight(benchLux[0])
+	i := 0
+	allocs := testing.AllocsPerRun(100, func() {
+		i++
+		m.AdjustBacklight(benchLux[i%len(benchLux)])
+	})
+	if allocs != 0 {
+		t.Errorf("AdjustBacklight allocated %.1f times per tick", allocs)
+	}
+	if got, _ := m.Sink().Read(); got != m.Output() {
+		t.Errorf("brightness file = %d, want %d", got, m.Output())
+	}
+}
@@ -0,0 +1,45 @@
+package backlight
+
+import (
+	"fmt"
+	"os"
+)
+
+// Framebuffer blank levels from linux/fb.h, as accepted by FBIOBLANK and
+// the fb class "blank" attribute.
+const (
+	fbBlankUnblank   = "0"
+	fbBlankPowerdown = "4"
+)
+
+// SetBlankPath makes ForceOff also power down the display through a
+// framebuffer "blank" attribute (e.g. /sys/class/graphics/fb0/blank),
+// which stops the panel and controller instead of only the LEDs. The
+// next non-zero write unblanks it first. Empty disables blanking.
+func (m *Manager) SetBlankPath(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blankPath = path
+}
+
+// Blanked reports whether the display is currently powered down.
+func (m *Manager) Blanked() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.blanked
+}
+
+func (m *Manager) setBlank(blank bool) error {
+	if m.blankPath == "" || blank == m.blanked {
+		return nil
+	}
+	level := fbBlankUnblank
+	if blank {
+		level = fbBlankPowerdown
+	}
+	if err := os.WriteFile(m.blankPath, []byte(level), 0644); err != nil {
+		return fmt.Errorf("failed to set framebuffer blank: %v", err)
+	}
+	m.blanked = blank
+	return nil
+}
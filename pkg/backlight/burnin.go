@@ -0,0 +1,126 @@
+package backlight
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// BurnInSink protects OLED panels from burn-in. A value of at least Level
+// that stays unchanged for After is written Reduce lower, and every Nudge
+// the raw value alternates with its neighbour one step down, so no pixel
+// sits at exactly the same drive level for hours. Read reports the value
+// asked for, not what was written.
+type BurnInSink struct {
+	Sink   Sink
+	Level  int           // values at or above this count as high
+	After  time.Duration // how long a high value may stay unchanged
+	Reduce float64       // fraction taken off a static high value, e.g. 0.05
+	Nudge  time.Duration // interval between adjacent raw values (0 = off)
+
+	mu        sync.Mutex
+	now       func() time.Time
+	requested int // -1 until the first write
+	since     time.Time
+	nudged    bool
+	lastNudge time.Time
+	written   int
+}
+
+// NewBurnInSink returns a BurnInSink in front of sink.
+func NewBurnInSink(sink Sink, level int, after time.Duration, reduce float64, nudge time.Duration) *BurnInSink {
+	return &BurnInSink{Sink: sink, Level: level, After: after, Reduce: reduce, Nudge: nudge, now: time.Now, requested: -1, written: -1}
+}
+
+func (b *BurnInSink) Read() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.requested >= 0 {
+		return b.requested, nil
+	}
+	return b.Sink.Read()
+}
+
+// ReadActual compares actual_brightness from Sink with the value written,
+// not the one requested: if the hardware applied the reduced or nudged
+// value it reports the requested one, otherwise what the hardware shows.
+func (b *BurnInSink) ReadActual() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	actual, err := readActual(b.Sink)
+	if err != nil {
+		return 0, err
+	}
+	if b.written >= 0 && actual == b.written {
+		return b.requested, nil
+	}
+	return actual, nil
+}
+
+func (b *BurnInSink) Write(value int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if value != b.requested {
+		b.requested = value
+		b.since = now
+		b.lastNudge = now
+		b.nudged = false
+	}
+	return b.write(b.raw(now))
+}
+
+func (b *BurnInSink) Max() int { return b.Sink.Max() }
+
+// Tick applies the reduction and the nudge as time passes without writes.
+// Call it regularly, e.g. once a second.
+func (b *BurnInSink) Tick() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.requested < 0 {
+		return nil
+	}
+	now := b.now()
+	if b.Nudge > 0 && b.requested >= b.Level && now.Sub(b.lastNudge) >= b.Nudge {
+		b.nudged = !b.nudged
+		b.lastNudge = now
+	}
+	if raw := b.raw(now); raw != b.written {
+		return b.write(raw)
+	}
+	return nil
+}
+
+// Reduced reports whether the static high value is being written lower.
+func (b *BurnInSink) Reduced() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reduced(b.now())
+}
+
+func (b *BurnInSink) reduced(now time.Time) bool {
+	return b.requested >= b.Level && b.After > 0 && now.Sub(b.since) >= b.After
+}
+
+// raw is the value to write for the requested one at now.
+func (b *BurnInSink) raw(now time.Time) int {
+	v := b.requested
+	if b.reduced(now) {
+		v = int(math.Round(float64(v) * (1 - b.Reduce)))
+	}
+	if b.nudged && v > 0 {
+		v--
+	}
+	return v
+}
+
+func (b *BurnInSink) write(raw int) error {
+	if err := b.Sink.Write(raw); err != nil {
+		return err
+	}
+	b.written = raw
+	return nil
+}
+
+func (b *BurnInSink) String() string { return fmt.Sprintf("%v (burn-in protection)", b.Sink) }
@@ -0,0 +1,89 @@
+package backlight
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBurnInReducesStaticHighValue(t *testing.T) {
+	mem := &memSink{}
+	b := NewBurnInSink(mem, 8000, time.Minute, 0.1, 0)
+	start := time.Unix(1000, 0)
+	now := start
+	b.now = func() time.Time { return now }
+
+	b.Write(10000)
+	now = start.Add(59 * time.Second)
+	b.Tick()
+	if mem.value != 10000 {
+		t.Fatalf("expected no reduction before a minute, got %d", mem.value)
+	}
+	now = start.Add(time.Minute)
+	b.Tick()
+	if mem.value != 9000 || !b.Reduced() {
+		t.Errorf("expected 10%% off after a minute, got %d", mem.value)
+	}
+	if v, _ := b.Read(); v != 10000 {
+		t.Errorf("expected Read to report the requested 10000, got %d", v)
+	}
+	b.Write(9500)
+	if mem.value != 9500 {
+		t.Errorf("expected a new value to lift the reduction, got %d", mem.value)
+	}
+}
+
+func TestBurnInLeavesLowValues(t *testing.T) {
+	mem := &memSink{}
+	b := NewBurnInSink(mem, 8000, time.Minute, 0.1, time.Second)
+	start := time.Unix(1000, 0)
+	now := start
+	b.now = func() time.Time { return now }
+
+	b.Write(4000)
+	now = start.Add(time.Hour)
+	b.Tick()
+	if mem.value != 4000 {
+		t.Errorf("expected a low value to be left alone, got %d", mem.value)
+	}
+}
+
+func TestBurnInNudgesBetweenAdjacentValues(t *testing.T) {
+	mem := &memSink{}
+	b := NewBurnInSink(mem, 8000, 0, 0, time.Second)
+	start := time.Unix(1000, 0)
+	now := start
+	b.now = func() time.Time { return now }
+
+	b.Write(10000)
+	var seen []int
+	for i := 1; i <= 4; i++ {
+		now = start.Add(time.Duration(i) * time.Second)
+		b.Tick()
+		seen = append(seen, mem.value)
+	}
+	want := []int{9999, 10000, 9999, 10000}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, seen)
+		}
+	}
+}
+
+func TestBurnInReadActualComparesWrittenValue(t *testing.T) {
+	clamp := &clampSink{limit: 20000}
+	b := NewBurnInSink(clamp, 8000, time.Minute, 0.1, 0)
+	start := time.Unix(1000, 0)
+	now := start
+	b.now = func() time.Time { return now }
+
+	b.Write(10000)
+	now = start.Add(time.Minute)
+	b.Tick()
+	if v, _ := b.ReadActual(); v != 10000 {
+		t.Errorf("expected the reduced write to count as applied, got %d", v)
+	}
+	clamp.limit = 8500
+	if v, _ := b.ReadActual(); v != 8500 {
+		t.Errorf("expected a clamp below the written value to show, got %d", v)
+	}
+}
@@ -0,0 +1,93 @@
+package backlight
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/filter"
+)
+
+// Config collects the Manager settings for NewManager. Zero values leave
+// the corresponding feature off, except where noted.
+type Config struct {
+	Curve    []Point // required, at least two points
+	RampRate float64 // fraction of remaining distance per tick (0..1)
+	LuxAlpha float64 // EMA factor, used when Estimator is nil
+
+	Estimator     filter.Estimator // lux smoothing (default: EMA of LuxAlpha)
+	MinConfidence float64
+	LogLux        bool // curve lux values are log10(lux) decades
+	LuxHysteresis float64
+	JumpFactor    float64
+	TunnelFactor  float64
+	TunnelWindow  time.Duration
+	FlickerRatio  float64
+	MaxSlew       float64 // brightness per second
+
+	FadeLevels      map[string]int // levels TransitionFades are keyed by
+	TransitionFades []TransitionFade
+
+	MaxBrightness    int  // overrides Sink.Max when > 0
+	Inverted         bool // the panel is brightest at 0
+	VerifyWrites     bool
+	MinWriteInterval time.Duration
+	BlankPath        string
+
+	Logger *log.Logger // default: discard
+}
+
+// NewManager returns a Manager writing to sink and configured from cfg.
+func NewManager(sink Sink, cfg Config) (*Manager, error) {
+	if len(cfg.Curve) < 2 {
+		return nil, fmt.Errorf("curve needs at least 2 points, got %d", len(cfg.Curve))
+	}
+	if cfg.RampRate < 0 || cfg.RampRate > 1 {
+		return nil, fmt.Errorf("ramp rate must be between 0 and 1, got %v", cfg.RampRate)
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	m := NewWithSink(sink, logger, cfg.Curve, cfg.RampRate, cfg.LuxAlpha)
+	m.SetMaxBrightness(cfg.MaxBrightness)
+	if err := m.SetInverted(cfg.Inverted); err != nil {
+		return nil, err
+	}
+	if cfg.Estimator != nil {
+		m.SetEstimator(cfg.Estimator)
+	}
+	m.SetMinConfidence(cfg.MinConfidence)
+	m.SetLogLux(cfg.LogLux)
+	m.SetLuxHysteresis(cfg.LuxHysteresis)
+	m.SetJumpFactor(cfg.JumpFactor)
+	m.SetTunnelDetection(cfg.TunnelFactor, cfg.TunnelWindow)
+	m.SetFlickerDetection(cfg.FlickerRatio)
+	m.SetSlewRate(cfg.MaxSlew)
+	m.SetTransitionFades(cfg.FadeLevels, cfg.TransitionFades)
+	m.SetVerifyWrites(cfg.VerifyWrites)
+	m.SetMinWriteInterval(cfg.MinWriteInterval)
+	m.SetBlankPath(cfg.BlankPath)
+	return m, nil
+}
+
+// Source supplies lux readings to Step.
+type Source interface {
+	ReadLux(ctx context.Context) (float64, error)
+}
+
+// Step reads one lux sample from src and runs one control tick. A failed
+// read leaves the output unchanged but still flushes coalesced writes.
+func (m *Manager) Step(ctx context.Context, src Source) error {
+	lux, err := src.ReadLux(ctx)
+	if err != nil {
+		if flushErr := m.FlushPending(); flushErr != nil {
+			return flushErr
+		}
+		return fmt.Errorf("failed to read lux: %v", err)
+	}
+	return m.AdjustBacklight(lux)
+}
@@ -0,0 +1,50 @@
+package backlight
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+type luxSource struct {
+	lux float64
+	err error
+}
+
+func (s luxSource) ReadLux(ctx context.Context) (float64, error) { return s.lux, s.err }
+
+func TestNewManagerValidates(t *testing.T) {
+	sink := NewSysfsSink(t.TempDir() + "/brightness")
+	if _, err := NewManager(sink, Config{Curve: defaultCurve[:1], RampRate: 0.15}); err == nil {
+		t.Error("expected error for a one point curve")
+	}
+	if _, err := NewManager(sink, Config{Curve: defaultCurve, RampRate: 2}); err == nil {
+		t.Error("expected error for a ramp rate above 1")
+	}
+	if _, err := NewManager(sink, Config{Curve: defaultCurve, RampRate: 0.15, Inverted: true}); err == nil {
+		t.Error("expected error for inversion without a max brightness")
+	}
+}
+
+func TestStepReadsSource(t *testing.T) {
+	path := t.TempDir() + "/brightness"
+	os.WriteFile(path, []byte("5000"), 0644)
+	m, err := NewManager(NewSysfsSink(path), Config{Curve: defaultCurve, RampRate: 0.15, LuxAlpha: 0.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Step(context.Background(), luxSource{lux: 200}); err != nil {
+		t.Fatal(err)
+	}
+	if m.Output() != 10240 {
+		t.Errorf("expected the first step to snap to 10240, got %d", m.Output())
+	}
+	if err := m.Step(context.Background(), luxSource{err: errors.New("no sensor")}); err == nil {
+		t.Error("expected the read error to be returned")
+	}
+	if m.Output() != 10240 {
+		t.Errorf("expected a failed read to keep the output, got %d", m.Output())
+	}
+}
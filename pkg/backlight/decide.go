@@ -0,0 +1,265 @@
+package backlight
+
+import (
+	"math"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/filter"
+)
+
+// State is everything the control loop carries from one tick to the next.
+// Decide derives the next State from it without touching the hardware, so
+// ticks can be tested, replayed or simulated with a clock of their own.
+type State struct {
+	Output      int             // brightness the ramp has reached, maybe not yet written (-1 = unknown)
+	Target      int             // brightness the output ramps towards (-1 = unknown)
+	Filter      filter.Snapshot // lux estimator state, in curve units
+	Confidence  float64         // estimator confidence after the last reading
+	AnchorLux   float64         // smoothed input when the curve last set Target
+	HasAnchor   bool
+	Initialized bool      // the first reading has been applied
+	TunnelSince time.Time // when the current drop started (zero = none)
+	TunnelRef   float64   // smoothed lux before the drop
+	LastTick    time.Time // previous tick, for the slew limit
+	Flicker     Flicker   // recent readings, for pulsed lighting
+	Fade        Fade      // fade in progress, if any
+}
+
+// Action is what a tick asks of the hardware.
+type Action struct {
+	Write   bool // write Value; otherwise only flush a coalesced write
+	Value   int
+	Initial bool // the first reading snapped the output to the curve
+	Jump    bool // the reading bypassed smoothing and the ramp
+	Tunnel  bool // the jump was a sustained drop into a tunnel
+}
+
+// State returns the Manager's control loop state.
+func (m *Manager) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// SetClock replaces time.Now for every time-dependent decision: the slew
+// limit, tunnel detection and the write interval.
+func (m *Manager) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+// AdjustBacklight smooths the lux input, computes a target brightness,
+// then ramps the output towards it.
+func (m *Manager) AdjustBacklight(lux float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev := m.state.Flicker.Active
+	next, act := m.decide(m.state, lux, m.now())
+	m.state = next
+
+	if next.Flicker.Active != prev {
+		if prev {
+			m.logger.Printf("pulsed lighting ended at %.1f lux", lux)
+		} else {
+			m.logger.Printf("pulsed lighting detected, holding at %.1f lux", m.deflickered(next))
+		}
+	}
+	if act.Tunnel {
+		m.logger.Printf("lux dropped to %.1f, entering tunnel", lux)
+	}
+	switch {
+	case act.Initial:
+		m.logger.Printf("lux=%.1f → brightness %d (initial)", lux, next.Output)
+	case act.Jump:
+		m.logger.Printf("lux jumped to %.1f → brightness %d", lux, next.Target)
+	}
+	if act.Write {
+		return m.writeBrightness(act.Value)
+	}
+	return m.flushPending()
+}
+
+// Decide runs one control tick for a lux reading taken at now and returns
+// the next state and the write it calls for. The result depends only on
+// st, lux, now and the Manager's configuration; neither m's state nor the
+// hardware is touched. The filter arithmetic borrows the configured
+// estimator under the Manager's lock and puts its state back afterwards,
+// so what-if calls leave the live filter as they found it.
+func (m *Manager) Decide(st State, lux float64, now time.Time) (State, Action) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := m.estimator.Snapshot()
+	defer m.estimator.Restore(saved)
+	return m.decide(st, lux, now)
+}
+
+func (m *Manager) decide(st State, lux float64, now time.Time) (State, Action) {
+	lux = m.deflicker(&st, lux)
+	x := m.curveInput(lux)
+	jump := m.isJump(st, lux)
+	tunnel := m.isTunnel(&st, lux, now)
+	act := Action{Tunnel: tunnel && !jump}
+	jump = (jump || tunnel) && st.Fade.For == 0
+	act.Tunnel = act.Tunnel && jump
+	act.Jump = jump
+
+	// Smooth the lux input to reject single-sample spikes
+	m.estimator.Restore(st.Filter)
+	if !st.Filter.Started || jump {
+		m.estimator.Reset(x)
+	} else {
+		m.estimator.Update(x)
+	}
+	st.Filter = m.estimator.Snapshot()
+	st.Confidence = m.estimator.Confidence()
+	smoothed := st.Filter.Estimate
+
+	newTarget := m.biased(m.interpolate(smoothed))
+
+	if !st.Initialized {
+		st.Target = newTarget
+		st.Output = newTarget
+		st.AnchorLux = smoothed
+		st.HasAnchor = true
+		st.Initialized = true
+		act.Initial = true
+		act.Jump = false
+		act.Write = true
+		act.Value = st.Output
+		return st, act
+	}
+
+	if m.holdTarget {
+		act.Jump, act.Tunnel = false, false
+		return m.ramp(st, act, now)
+	}
+
+	// With relative hysteresis, hold the target until lux has moved by the
+	// configured fraction from where the curve last set it. This behaves the
+	// same on sensors that read uniformly high or low.
+	if m.luxHysteresis > 0 && st.HasAnchor {
+		if !jump && math.Abs(smoothed-st.AnchorLux) <= m.hysteresisBand(st.AnchorLux) {
+			return m.ramp(st, act, now)
+		}
+	}
+
+	// Defer target changes while the estimator is still settling.
+	if !jump && st.Confidence < m.minConfidence {
+		return m.ramp(st, act, now)
+	}
+
+	if jump {
+		prev := st.Target
+		st.Target = newTarget
+		st.AnchorLux = smoothed
+		st.HasAnchor = true
+		if m.startTransition(&st, &act, prev, now) {
+			return m.ramp(st, act, now)
+		}
+		if step := m.limitStep(&st, newTarget-st.Output, now); step != 0 {
+			st.Output += step
+			act.Write = true
+			act.Value = st.Output
+		}
+		return st, act
+	}
+
+	// Only update target if the change exceeds the deadband to prevent
+	// oscillation from sensor noise at interpolation boundaries.
+	delta := newTarget - st.Target
+	if delta < 0 {
+		delta = -delta
+	}
+	if delta > m.targetDeadband {
+		prev := st.Target
+		st.Target = newTarget
+		st.AnchorLux = smoothed
+		st.HasAnchor = true
+		m.startTransition(&st, &act, prev, now)
+	}
+
+	return m.ramp(st, act, now)
+}
+
+// ramp moves the output one ramp-step toward the target, snapping when
+// close.
+func (m *Manager) ramp(st State, act Action, now time.Time) (State, Action) {
+	if st.Fade.For > 0 {
+		return m.fade(st, act, now)
+	}
+	if st.Target == st.Output {
+		m.limitStep(&st, 0, now)
+		return st, act
+	}
+
+	diff := st.Target - st.Output
+	step := int(math.Round(float64(diff) * filter.ScaleRate(m.rampRate, m.responsiveness)))
+	if step == 0 {
+		step = diff
+	}
+
+	st.Output += m.limitStep(&st, step, now)
+	act.Write = true
+	act.Value = st.Output
+	return st, act
+}
+
+// limitStep clamps a brightness step to the slew rate for the time elapsed
+// since the previous tick, and starts the next one at now.
+func (m *Manager) limitStep(st *State, step int, now time.Time) int {
+	elapsed := now.Sub(st.LastTick)
+	st.LastTick = now
+
+	if m.maxSlew <= 0 {
+		return step
+	}
+	if elapsed > maxSlewWindow {
+		elapsed = maxSlewWindow
+	}
+	limit := int(m.maxSlew * elapsed.Seconds())
+	if limit < 1 {
+		limit = 1
+	}
+	if step > limit {
+		return limit
+	}
+	if step < -limit {
+		return -limit
+	}
+	return step
+}
+
+// isTunnel reports whether lux completes a sustained drop.
+func (m *Manager) isTunnel(st *State, lux float64, now time.Time) bool {
+	if m.tunnelFactor <= 1 || !st.Filter.Started || !st.Initialized {
+		return false
+	}
+	cur := math.Max(lux, logLuxFloor)
+	if st.TunnelSince.IsZero() {
+		prev := math.Max(m.smoothedLux(*st), logLuxFloor)
+		if cur*m.tunnelFactor > prev {
+			return false
+		}
+		st.TunnelSince = now
+		st.TunnelRef = prev
+	} else if cur*m.tunnelFactor > st.TunnelRef {
+		st.TunnelSince = time.Time{}
+		return false
+	}
+	if now.Sub(st.TunnelSince) < m.tunnelWindow {
+		return false
+	}
+	st.TunnelSince = time.Time{}
+	return true
+}
+
+// isJump reports whether lux is a jump relative to the smoothed lux.
+func (m *Manager) isJump(st State, lux float64) bool {
+	if m.jumpFactor <= 1 || !st.Filter.Started || !st.Initialized {
+		return false
+	}
+	prev := math.Max(m.smoothedLux(st), logLuxFloor)
+	cur := math.Max(lux, logLuxFloor)
+	return cur >= prev*m.jumpFactor || cur*m.jumpFactor <= prev
+}
@@ -0,0 +1,114 @@
+package backlight
+
+import (
+	"testing"
+	"time"
+
+	"github.com/librescoot/dbc-backlight-service/pkg/filter"
+)
+
+func TestDecideLeavesManagerUntouched(t *testing.T) {
+	m := newTestManager(t) // hardware brightness seeded at 5000
+	before := m.State()
+
+	next, act := m.Decide(before, 200, time.Now())
+	if !act.Initial || !act.Write || act.Value != 10240 {
+		t.Errorf("expected an initial write of 10240, got %+v", act)
+	}
+	if next.Output != 10240 || !next.Initialized {
+		t.Errorf("expected next state at 10240, got %+v", next)
+	}
+	if m.State() != before || m.Writes() != 0 {
+		t.Error("expected Decide to leave the Manager and sink untouched")
+	}
+	if again, _ := m.Decide(before, 200, time.Now()); again.Output != next.Output || again.Filter != next.Filter {
+		t.Errorf("expected the same decision on replay, got %+v", again)
+	}
+}
+
+func TestDecideTunnelWindowFollowsClock(t *testing.T) {
+	m := newTestManager(t)
+	m.SetTunnelDetection(10, time.Second)
+	start := time.Unix(1000, 0)
+
+	st, _ := m.Decide(m.State(), 200, start) // 10240
+	st, act := m.Decide(st, 2, start.Add(100*time.Millisecond))
+	if act.Jump {
+		t.Fatal("expected the drop to wait for the window")
+	}
+	st, act = m.Decide(st, 2, start.Add(900*time.Millisecond))
+	if act.Jump {
+		t.Fatal("expected no jump before the window has passed")
+	}
+	st, act = m.Decide(st, 2, start.Add(1100*time.Millisecond))
+	if !act.Tunnel || !act.Jump || st.Output != 2900 {
+		t.Errorf("expected a tunnel jump to 2900 once the window passed, got %+v output %d", act, st.Output)
+	}
+}
+
+func TestDecideSlewUsesElapsedTime(t *testing.T) {
+	m := newTestManager(t)
+	m.SetSlewRate(1000)
+	start := time.Unix(1000, 0)
+
+	st, _ := m.Decide(m.State(), 0, start) // 400
+	st, _ = m.Decide(st, 0, start)
+	st, _ = m.Decide(st, 200, start.Add(250*time.Millisecond))
+	if st.Output != 650 {
+		t.Errorf("expected 250 brightness per 250ms, got output %d", st.Output)
+	}
+}
+
+func TestDecideHoldKeepsTarget(t *testing.T) {
+	m := newTestManager(t)
+	m.SetJumpFactor(10)
+	start := time.Unix(1000, 0)
+
+	st, _ := m.Decide(m.State(), 200, start) // 10240
+	m.SetHold(true)
+	st, act := m.Decide(st, 0, start.Add(time.Second))
+	if act.Jump || st.Target != 10240 {
+		t.Errorf("expected the hold to keep the target at 10240, got %+v target %d", act, st.Target)
+	}
+	m.SetHold(false)
+	st, _ = m.Decide(st, 0, start.Add(2*time.Second))
+	if st.Target == 10240 {
+		t.Error("expected the target to follow the curve once released")
+	}
+}
+
+func TestDecideWideDeadbandHoldsSmallChanges(t *testing.T) {
+	m := newTestManager(t)
+	start := time.Unix(1000, 0)
+
+	st, _ := m.Decide(m.State(), 20, start)
+	m.SetDeadband(10240)
+	for i := 1; i <= 20; i++ {
+		st, _ = m.Decide(st, 5, start.Add(time.Duration(i)*time.Second))
+	}
+	if st.Target != 7000 {
+		t.Errorf("expected the widened deadband to hold the target at 7000, got %d", st.Target)
+	}
+}
+
+func TestDecideKeepsLiveFilter(t *testing.T) {
+	live, twin := newTestManager(t), newTestManager(t)
+	for _, m := range []*Manager{live, twin} {
+		m.SetEstimator(filter.NewKalman(0.01, 0.1))
+		for _, lux := range []float64{20, 22, 19} {
+			if err := m.AdjustBacklight(lux); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	st := live.State()
+	for _, lux := range []float64{500, 0, 500} {
+		st, _ = live.Decide(st, lux, time.Now())
+	}
+	live.AdjustBacklight(21)
+	twin.AdjustBacklight(21)
+	if live.State().Filter != twin.State().Filter || live.Output() != twin.Output() {
+		t.Errorf("expected what-if decisions to leave the filter alone, got %+v want %+v", live.State().Filter, twin.State().Filter)
+	}
+}
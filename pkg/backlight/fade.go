@@ -0,0 +1,203 @@
+package backlight
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Easing shapes a fade between its endpoints.
+type Easing string
+
+const (
+	Linear      Easing = "linear"
+	EaseInOut   Easing = "ease-in-out" // slow start and end, fastest midway
+	Exponential Easing = "exponential" // equal ratios per step, even to the eye
+)
+
+// ParseEasing checks an easing name; "" is Linear.
+func ParseEasing(s string) (Easing, error) {
+	switch e := Easing(s); e {
+	case "":
+		return Linear, nil
+	case Linear, EaseInOut, Exponential:
+		return e, nil
+	}
+	return "", fmt.Errorf("unknown easing %q (expected linear, ease-in-out or exponential)", s)
+}
+
+// at returns the value frac (0..1) of the way from from to to.
+func (e Easing) at(from, to int, frac float64) int {
+	switch e {
+	case EaseInOut:
+		frac = frac * frac * (3 - 2*frac)
+	case Exponential:
+		// Offset by one so fades to or from 0 stay defined.
+		a, b := float64(from+1), float64(to+1)
+		return int(math.Round(a*math.Pow(b/a, frac))) - 1
+	}
+	return from + int(math.Round(float64(to-from)*frac))
+}
+
+// Fade is a fade from From to the target, started at Start.
+type Fade struct {
+	From   int
+	Start  time.Time
+	For    time.Duration // 0 = no fade
+	Easing Easing        // "" = Linear
+}
+
+// TransitionFade is the fade used when the target moves from one named
+// level to another. From and To are level names, "off" or "*" for any.
+type TransitionFade struct {
+	From, To string
+	For      time.Duration // 0 = snap to the new target
+	Easing   Easing
+}
+
+// ParseTransitionFades parses "from>to:duration[:easing]" entries, e.g.
+// "high>medium:2s:ease-in-out low>*:100ms".
+func ParseTransitionFades(s string) ([]TransitionFade, error) {
+	var fades []TransitionFade
+	for _, f := range strings.Fields(s) {
+		parts := strings.Split(f, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid transition %q (expected from>to:duration[:easing])", f)
+		}
+		names := strings.SplitN(parts[0], ">", 2)
+		if len(names) != 2 || names[0] == "" || names[1] == "" {
+			return nil, fmt.Errorf("invalid transition %q (expected from>to)", parts[0])
+		}
+		d, err := time.ParseDuration(parts[1])
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid duration %q in %q", parts[1], f)
+		}
+		var easing Easing
+		if len(parts) == 3 {
+			if easing, err = ParseEasing(parts[2]); err != nil {
+				return nil, err
+			}
+		}
+		fades = append(fades, TransitionFade{From: names[0], To: names[1], For: d, Easing: easing})
+	}
+	return fades, nil
+}
+
+// SetTransitionFades fades target changes that cross from one of levels
+// to another with the first matching entry of fades, in place of the
+// ramp and the slew limit. The level of a brightness is the nearest one,
+// or "off" at 0. Changes no entry matches ramp as usual.
+func (m *Manager) SetTransitionFades(levels map[string]int, fades []TransitionFade) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fadeLevels = levels
+	m.transitionFades = fades
+}
+
+// levelOf names the level nearest brightness, "off" for 0.
+func (m *Manager) levelOf(brightness int) string {
+	if brightness <= 0 {
+		return "off"
+	}
+	names := make([]string, 0, len(m.fadeLevels))
+	for name := range m.fadeLevels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	level, best := "", -1
+	for _, name := range names {
+		d := m.fadeLevels[name] - brightness
+		if d < 0 {
+			d = -d
+		}
+		if best < 0 || d < best {
+			level, best = name, d
+		}
+	}
+	return level
+}
+
+// transitionFade returns the fade for a target change from from to to.
+func (m *Manager) transitionFade(from, to int) (TransitionFade, bool) {
+	if len(m.transitionFades) == 0 || from < 0 {
+		return TransitionFade{}, false
+	}
+	a, b := m.levelOf(from), m.levelOf(to)
+	if a == b {
+		return TransitionFade{}, false
+	}
+	for _, f := range m.transitionFades {
+		if (f.From == a || f.From == "*") && (f.To == b || f.To == "*") {
+			return f, true
+		}
+	}
+	return TransitionFade{}, false
+}
+
+// startTransition begins the fade for a target change from prev, unless
+// a fade is already running, and reports whether it did. A zero-length
+// fade snaps the output to the target.
+func (m *Manager) startTransition(st *State, act *Action, prev int, now time.Time) bool {
+	if st.Fade.For > 0 {
+		return false
+	}
+	f, ok := m.transitionFade(prev, st.Target)
+	if !ok {
+		return false
+	}
+	if f.For <= 0 {
+		st.LastTick = now
+		if st.Output != st.Target {
+			st.Output = st.Target
+			act.Write = true
+			act.Value = st.Output
+		}
+		return true
+	}
+	st.Fade = Fade{From: st.Output, Start: now, For: f.For, Easing: f.Easing}
+	return true
+}
+
+// FadeIn moves the output from where it is to the target in a straight
+// line over d, in place of the ramp, the slew limit and jumps. The target
+// keeps following the curve meanwhile, so the fade ends wherever the lux
+// says it should.
+func (m *Manager) FadeIn(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	from := m.state.Output
+	if from < 0 {
+		from = 0
+	}
+	m.state.Fade = Fade{From: from, Start: m.now(), For: d}
+	m.state.Initialized = true
+}
+
+// Fading reports whether a fade is in progress.
+func (m *Manager) Fading() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.Fade.For > 0
+}
+
+// fade sets the output to its point on the fade at now, ending the fade
+// once d has passed.
+func (m *Manager) fade(st State, act Action, now time.Time) (State, Action) {
+	st.LastTick = now
+	f := st.Fade
+	frac := now.Sub(f.Start).Seconds() / f.For.Seconds()
+	value := st.Target
+	if frac < 1 {
+		value = f.Easing.at(f.From, st.Target, math.Max(frac, 0))
+	} else {
+		st.Fade = Fade{}
+	}
+	if value != st.Output {
+		st.Output = value
+		act.Write = true
+		act.Value = value
+	}
+	return st, act
+}
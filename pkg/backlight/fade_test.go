@@ -0,0 +1,99 @@
+package backlight
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFadeInIsLinear(t *testing.T) {
+	m := newTestManager(t)
+	start := time.Unix(1000, 0)
+	m.SetClock(func() time.Time { return start })
+	if err := m.SetOutput(0); err != nil {
+		t.Fatal(err)
+	}
+	m.FadeIn(2 * time.Second)
+
+	st, _ := m.Decide(m.State(), 200, start.Add(500*time.Millisecond))
+	if st.Target != 10240 || st.Output != 2560 {
+		t.Errorf("expected a quarter of the way to 10240, got target %d output %d", st.Target, st.Output)
+	}
+	st, _ = m.Decide(st, 200, start.Add(time.Second))
+	if st.Output != 5120 {
+		t.Errorf("expected halfway, got %d", st.Output)
+	}
+	st, _ = m.Decide(st, 200, start.Add(2*time.Second))
+	if st.Output != 10240 || st.Fade.For != 0 {
+		t.Errorf("expected the fade to end at the target, got output %d fade %+v", st.Output, st.Fade)
+	}
+}
+
+func TestFadeInIgnoresJumps(t *testing.T) {
+	m := newTestManager(t)
+	m.SetJumpFactor(10)
+	start := time.Unix(1000, 0)
+	m.SetClock(func() time.Time { return start })
+	m.SetOutput(0)
+	m.FadeIn(time.Second)
+
+	st, _ := m.Decide(m.State(), 200, start.Add(100*time.Millisecond))
+	st, act := m.Decide(st, 2, start.Add(200*time.Millisecond))
+	if act.Jump || st.Fade.For == 0 {
+		t.Errorf("expected the fade to continue through a jump, got %+v", act)
+	}
+}
+
+func TestTransitionFadeEases(t *testing.T) {
+	m := newTestManager(t)
+	m.SetJumpFactor(10)
+	fades, err := ParseTransitionFades("high>low:2s:ease-in-out *>high:0s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetTransitionFades(map[string]int{"low": 1300, "high": 10240}, fades)
+	start := time.Unix(1000, 0)
+
+	st, _ := m.Decide(m.State(), 200, start)
+	if st.Output != 10240 {
+		t.Fatalf("expected the initial reading at 10240, got %d", st.Output)
+	}
+	st, _ = m.Decide(st, 0, start.Add(time.Second))
+	if st.Fade.For != 2*time.Second || st.Output != 10240 {
+		t.Fatalf("expected a 2s fade to start from 10240, got output %d fade %+v", st.Output, st.Fade)
+	}
+	target := st.Target
+	st, _ = m.Decide(st, 0, start.Add(1500*time.Millisecond))
+	if want := 10240 + int(float64(target-10240)*0.15625+0.5); st.Output < want-1 || st.Output > want+1 {
+		t.Errorf("expected a slow start at about %d, got %d", want, st.Output)
+	}
+	st, _ = m.Decide(st, 0, start.Add(2*time.Second))
+	if want := (10240 + target) / 2; st.Output < want-1 || st.Output > want+1 {
+		t.Errorf("expected halfway at %d, got %d", want, st.Output)
+	}
+	st, _ = m.Decide(st, 0, start.Add(3*time.Second))
+	if st.Output != target || st.Fade.For != 0 {
+		t.Fatalf("expected the fade to end at %d, got %d", target, st.Output)
+	}
+
+	st, act := m.Decide(st, 200, start.Add(3100*time.Millisecond))
+	if !act.Write || st.Output != 10240 {
+		t.Errorf("expected a zero-length fade to snap to 10240, got %d", st.Output)
+	}
+}
+
+func TestEasingExponential(t *testing.T) {
+	if got := Exponential.at(0, 1023, 0.5); got != 31 {
+		t.Errorf("expected 31 halfway from 0 to 1023, got %d", got)
+	}
+	if got := Exponential.at(1023, 0, 1); got != 0 {
+		t.Errorf("expected the fade to end at 0, got %d", got)
+	}
+}
+
+func TestParseTransitionFadesErrors(t *testing.T) {
+	for _, s := range []string{"high:2s", "high>:2s", "high>low", "high>low:soon", "high>low:2s:bouncy"} {
+		if _, err := ParseTransitionFades(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package backlight
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// FIFOSink writes each value as a line to a named pipe. It never blocks:
+// without a reader, or with one that is not keeping up, the value is
+// dropped and the pipe reopened on the next write. The pipe is driven
+// through raw syscalls, since the os package would wait for a full pipe
+// to drain.
+type FIFOSink struct {
+	path string
+	fd   int // -1 while closed
+	last int
+	buf  [21]byte
+}
+
+// NewFIFOSink returns a sink writing to the FIFO at path.
+func NewFIFOSink(path string) *FIFOSink {
+	return &FIFOSink{path: path, fd: -1, last: -1}
+}
+
+// IsFIFO reports whether path is a named pipe.
+func IsFIFO(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeNamedPipe != 0
+}
+
+func (s *FIFOSink) Read() (int, error) {
+	if s.last < 0 {
+		return 0, fmt.Errorf("nothing written to %s yet", s.path)
+	}
+	return s.last, nil
+}
+
+func (s *FIFOSink) Write(value int) error {
+	s.last = value
+	return s.writeLine(append(strconv.AppendInt(s.buf[:0], int64(value), 10), '\n'))
+}
+
+func (s *FIFOSink) Max() int { return -1 }
+
+func (s *FIFOSink) String() string { return s.path }
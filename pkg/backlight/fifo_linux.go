@@ -0,0 +1,40 @@
+//go:build linux
+
+package backlight
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+func (s *FIFOSink) writeLine(line []byte) error {
+	if s.fd < 0 {
+		fd, err := unix.Open(s.path, unix.O_WRONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+		if err == unix.ENXIO {
+			return nil // no reader
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", s.path, err)
+		}
+		s.fd = fd
+	}
+	if _, err := unix.Write(s.fd, line); err != nil {
+		s.Close()
+		if err == unix.EPIPE || err == unix.EAGAIN {
+			return nil
+		}
+		return fmt.Errorf("failed to write %s: %v", s.path, err)
+	}
+	return nil
+}
+
+// Close closes the pipe. The next Write opens it again.
+func (s *FIFOSink) Close() error {
+	if s.fd < 0 {
+		return nil
+	}
+	err := unix.Close(s.fd)
+	s.fd = -1
+	return err
+}
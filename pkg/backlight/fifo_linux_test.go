@@ -0,0 +1,38 @@
+package backlight
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestFIFOSinkWritesLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mirror")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	if !IsFIFO(path) {
+		t.Fatal("expected IsFIFO to recognise the pipe")
+	}
+	s := NewFIFOSink(path)
+	defer s.Close()
+
+	if err := s.Write(100); err != nil {
+		t.Fatalf("expected a write without a reader to be dropped, got %v", err)
+	}
+
+	r, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := s.Write(4000); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil || line != "4000\n" {
+		t.Errorf("expected line 4000, got %q (%v)", line, err)
+	}
+}
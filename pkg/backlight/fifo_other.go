@@ -0,0 +1,12 @@
+//go:build !linux
+
+package backlight
+
+import "fmt"
+
+func (s *FIFOSink) writeLine(line []byte) error {
+	return fmt.Errorf("FIFO mirrors are only supported on Linux")
+}
+
+// Close is a no-op off Linux.
+func (s *FIFOSink) Close() error { return nil }
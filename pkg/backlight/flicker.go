@@ -0,0 +1,91 @@
+package backlight
+
+import "math"
+
+// flickerWindow is how many raw readings the flicker detector looks at,
+// and how many readings without the pattern end an episode.
+const flickerWindow = 6
+
+// flickerStability bounds how much the high/low ratio may vary within
+// the window: pulsed lighting aliased by the poll keeps a steady ratio,
+// passing shadows don't.
+const flickerStability = 1.5
+
+// Flicker tracks pulsed lighting across ticks.
+type Flicker struct {
+	Recent [flickerWindow]float64 // raw lux, oldest first
+	Count  int                    // valid entries in Recent
+	Active bool                   // an episode is in progress
+	Misses int                    // readings without the pattern during an episode
+}
+
+// SetFlickerDetection holds the input at the brighter of alternating
+// readings while the last flickerWindow readings alternate high and low
+// by at least ratio. PWM-dimmed LED street lights, aliased by the poll,
+// look like that; static hysteresis would either chase them or need a
+// band wide enough to miss real changes. A ratio <= 1 disables it.
+func (m *Manager) SetFlickerDetection(ratio float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flickerRatio = ratio
+	m.state.Flicker = Flicker{}
+}
+
+// deflicker records lux and returns the reading to use: the upper
+// candidate while an episode is active, lux otherwise.
+func (m *Manager) deflicker(st *State, lux float64) float64 {
+	if m.flickerRatio <= 1 {
+		return lux
+	}
+	f := &st.Flicker
+	if f.Count < flickerWindow {
+		f.Count++
+	} else {
+		copy(f.Recent[:], f.Recent[1:])
+	}
+	f.Recent[f.Count-1] = lux
+
+	switch {
+	case m.isFlicker(f):
+		f.Active = true
+		f.Misses = 0
+	case f.Active:
+		f.Misses++
+		if f.Misses >= flickerWindow {
+			f.Active = false
+			f.Misses = 0
+		}
+	}
+	if !f.Active {
+		return lux
+	}
+	return m.deflickered(*st)
+}
+
+// deflickered returns the upper candidate: the brightest recent reading.
+func (m *Manager) deflickered(st State) float64 {
+	upper := 0.0
+	for _, v := range st.Flicker.Recent[:st.Flicker.Count] {
+		upper = math.Max(upper, v)
+	}
+	return upper
+}
+
+// isFlicker reports whether the window alternates between high and low
+// readings with a large and steady ratio.
+func (m *Manager) isFlicker(f *Flicker) bool {
+	if f.Count < flickerWindow {
+		return false
+	}
+	minRatio, maxRatio := math.Inf(1), 0.0
+	for i := 1; i < flickerWindow; i++ {
+		prev, cur := f.Recent[i-1], f.Recent[i]
+		if i > 1 && (cur > prev) == (prev > f.Recent[i-2]) {
+			return false
+		}
+		ratio := math.Max(prev, cur) / math.Max(math.Min(prev, cur), logLuxFloor)
+		minRatio = math.Min(minRatio, ratio)
+		maxRatio = math.Max(maxRatio, ratio)
+	}
+	return minRatio >= m.flickerRatio && maxRatio <= minRatio*flickerStability
+}
@@ -0,0 +1,69 @@
+package backlight
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+func newFlickerManager(t *testing.T) *Manager {
+	t.Helper()
+	m, err := NewManager(&memSink{value: 5000}, Config{
+		Curve:        defaultCurve,
+		RampRate:     0.15,
+		LuxAlpha:     0.2,
+		FlickerRatio: 2,
+		Logger:       log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestFlickerHoldsAtUpperCandidate(t *testing.T) {
+	m := newFlickerManager(t)
+	for i := 0; i < 20; i++ {
+		lux := 2.0
+		if i%2 == 0 {
+			lux = 6
+		}
+		m.AdjustBacklight(lux)
+	}
+	st := m.State()
+	if !st.Flicker.Active {
+		t.Fatal("expected alternating readings to be detected as flicker")
+	}
+	if got := st.Filter.Estimate; got < 5.9 {
+		t.Errorf("smoothed lux %.2f, want it held near the upper reading 6", got)
+	}
+
+	// Steady light ends the episode after a full window.
+	for i := 0; i < flickerWindow; i++ {
+		m.AdjustBacklight(3)
+	}
+	if m.State().Flicker.Active {
+		t.Error("expected the episode to end under steady light")
+	}
+}
+
+func TestFlickerIgnoresShadows(t *testing.T) {
+	m := newFlickerManager(t)
+	// Large swings, but not alternating every reading.
+	for _, lux := range []float64{20, 20, 4, 4, 20, 4, 4, 20, 20, 4, 20, 20} {
+		m.AdjustBacklight(lux)
+		if m.State().Flicker.Active {
+			t.Fatalf("shadow pattern detected as flicker at %v lux", lux)
+		}
+	}
+}
+
+func TestFlickerNeedsSteadyRatio(t *testing.T) {
+	m := newFlickerManager(t)
+	for _, lux := range []float64{2, 5, 2, 30, 2, 6, 1, 40} {
+		m.AdjustBacklight(lux)
+		if m.State().Flicker.Active {
+			t.Fatalf("erratic alternation detected as flicker at %v lux", lux)
+		}
+	}
+}
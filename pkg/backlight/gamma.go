@@ -0,0 +1,60 @@
+package backlight
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// GammaSteps returns n brightness values from min to max that look evenly
+// spaced. Perceived brightness is roughly raw^(1/gamma), so the values are
+// spaced evenly in that domain and mapped back; gamma 1 is linear. Typical
+// panels are around 2.2.
+func GammaSteps(min, max, n int, gamma float64) ([]int, error) {
+	if n < 2 {
+		return nil, fmt.Errorf("need at least 2 steps, got %d", n)
+	}
+	if min < 0 || max <= min {
+		return nil, fmt.Errorf("need 0 <= min < max, got %d and %d", min, max)
+	}
+	if gamma <= 0 {
+		return nil, fmt.Errorf("gamma must be positive, got %g", gamma)
+	}
+
+	lo := math.Pow(float64(min), 1/gamma)
+	hi := math.Pow(float64(max), 1/gamma)
+	values := make([]int, n)
+	for i := range values {
+		p := lo + (hi-lo)*float64(i)/float64(n-1)
+		values[i] = int(math.Round(math.Pow(p, gamma)))
+	}
+	return values, nil
+}
+
+// SpaceLevels reassigns the brightness of each level with GammaSteps
+// between min and max, keeping the names and their dim-to-bright order.
+func SpaceLevels(levels map[string]int, min, max int, gamma float64) (map[string]int, error) {
+	names := make([]string, 0, len(levels))
+	for name := range levels {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if levels[names[i]] != levels[names[j]] {
+			return levels[names[i]] < levels[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) == 1 {
+		return map[string]int{names[0]: max}, nil
+	}
+
+	values, err := GammaSteps(min, max, len(names), gamma)
+	if err != nil {
+		return nil, err
+	}
+	spaced := make(map[string]int, len(names))
+	for i, name := range names {
+		spaced[name] = values[i]
+	}
+	return spaced, nil
+}
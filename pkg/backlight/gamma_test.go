@@ -0,0 +1,55 @@
+package backlight
+
+import "testing"
+
+func TestGammaStepsLinear(t *testing.T) {
+	got, err := GammaSteps(0, 100, 5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{0, 25, 50, 75, 100}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestGammaStepsPerceptual(t *testing.T) {
+	got, err := GammaSteps(1000, 10240, 5, 2.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != 1000 || got[4] != 10240 {
+		t.Errorf("expected endpoints 1000 and 10240, got %v", got)
+	}
+	// Raw steps widen towards the bright end.
+	for i := 2; i < len(got); i++ {
+		if got[i]-got[i-1] <= got[i-1]-got[i-2] {
+			t.Errorf("expected growing steps, got %v", got)
+		}
+	}
+}
+
+func TestSpaceLevelsKeepsOrder(t *testing.T) {
+	levels := map[string]int{"low": 9350, "medium": 9500, "high": 9700}
+	got, err := SpaceLevels(levels, 1000, 10240, 2.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["low"] != 1000 || got["high"] != 10240 {
+		t.Errorf("expected low and high at the ends, got %v", got)
+	}
+	if got["medium"] <= got["low"] || got["medium"] >= got["high"] {
+		t.Errorf("expected medium in between, got %v", got)
+	}
+}
+
+func TestGammaStepsRejectsBadRange(t *testing.T) {
+	if _, err := GammaSteps(500, 500, 3, 2.2); err == nil {
+		t.Error("expected an error for an empty range")
+	}
+	if _, err := GammaSteps(0, 100, 1, 2.2); err == nil {
+		t.Error("expected an error for a single step")
+	}
+}
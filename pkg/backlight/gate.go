@@ -0,0 +1,81 @@
+package backlight
+
+import (
+	"fmt"
+	"sync"
+)
+
+// GateSink holds back every write to Sink until Open is called, so the
+// panel stays dark until there is something worth lighting. While closed,
+// Read reports the last value held back, so the Manager's read-back
+// checks see what it asked for.
+type GateSink struct {
+	Sink Sink
+
+	mu      sync.Mutex
+	open    bool
+	pending int
+	has     bool
+}
+
+// NewGateSink returns a closed gate in front of sink.
+func NewGateSink(sink Sink) *GateSink {
+	return &GateSink{Sink: sink}
+}
+
+func (g *GateSink) Read() (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.open && g.has {
+		return g.pending, nil
+	}
+	return g.Sink.Read()
+}
+
+// ReadActual passes actual_brightness through from Sink, or like Read
+// reports the value held back while closed.
+func (g *GateSink) ReadActual() (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.open && g.has {
+		return g.pending, nil
+	}
+	return readActual(g.Sink)
+}
+
+func (g *GateSink) Write(value int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.open {
+		g.pending, g.has = value, true
+		return nil
+	}
+	return g.Sink.Write(value)
+}
+
+func (g *GateSink) Max() int { return g.Sink.Max() }
+
+// Open lets writes through, starting with the last one held back. It
+// reports whether anything was written.
+func (g *GateSink) Open() (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.open {
+		return false, nil
+	}
+	g.open = true
+	if !g.has {
+		return false, nil
+	}
+	g.has = false
+	return true, g.Sink.Write(g.pending)
+}
+
+// IsOpen reports whether Open has been called.
+func (g *GateSink) IsOpen() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.open
+}
+
+func (g *GateSink) String() string { return fmt.Sprintf("%v (gated)", g.Sink) }
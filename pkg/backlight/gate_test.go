@@ -0,0 +1,62 @@
+package backlight
+
+import "testing"
+
+func TestGateSinkHoldsWritesUntilOpen(t *testing.T) {
+	mem := &memSink{value: 0}
+	g := NewGateSink(mem)
+
+	g.Write(3000)
+	g.Write(5000)
+	if mem.value != 0 {
+		t.Fatalf("expected no write through a closed gate, got %d", mem.value)
+	}
+	if v, _ := g.Read(); v != 5000 {
+		t.Errorf("expected Read to report the held value 5000, got %d", v)
+	}
+	if wrote, err := g.Open(); !wrote || err != nil || mem.value != 5000 {
+		t.Errorf("expected Open to write the last held value, got %v %v %d", wrote, err, mem.value)
+	}
+	g.Write(6000)
+	if mem.value != 6000 {
+		t.Errorf("expected writes to pass once open, got %d", mem.value)
+	}
+}
+
+func TestGateSinkOpenWithoutWrites(t *testing.T) {
+	mem := &memSink{value: 1234}
+	g := NewGateSink(mem)
+	if v, _ := g.Read(); v != 1234 {
+		t.Errorf("expected Read to pass through before any write, got %d", v)
+	}
+	if wrote, _ := g.Open(); wrote || mem.value != 1234 {
+		t.Errorf("expected nothing written, got %v %d", wrote, mem.value)
+	}
+}
+
+// clampSink applies at most limit and reports it as actual_brightness.
+type clampSink struct {
+	memSink
+	limit int
+}
+
+func (s *clampSink) ReadActual() (int, error) {
+	if s.value > s.limit {
+		return s.limit, nil
+	}
+	return s.value, nil
+}
+
+func TestGateSinkPassesActualThrough(t *testing.T) {
+	clamp := &clampSink{limit: 4000}
+	g := NewGateSink(clamp)
+
+	g.Write(6000)
+	if v, _ := g.ReadActual(); v != 6000 {
+		t.Errorf("expected the held value while closed, got %d", v)
+	}
+	g.Open()
+	if v, _ := g.ReadActual(); v != 4000 {
+		t.Errorf("expected actual_brightness from the sink once open, got %d", v)
+	}
+}
@@ -0,0 +1,153 @@
+package backlight
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// histogramBucketsPerDecade sets the histogram resolution: four buckets
+// per factor of ten, from logLuxFloor up.
+const histogramBucketsPerDecade = 4
+
+// histogramTrim is the share of time at either end the curve suggestion
+// ignores, so a few minutes in a car park or in glare don't stretch it.
+const histogramTrim = 0.02
+
+// LuxHistogram is time spent at each lux, in seconds by bucket. Buckets
+// are spaced evenly in log10(lux), so dusk is resolved as finely as
+// daylight.
+type LuxHistogram map[int]float64
+
+// HistogramBucket returns the bucket lux falls in.
+func HistogramBucket(lux float64) int {
+	return int(math.Floor((math.Log10(math.Max(lux, logLuxFloor)) - math.Log10(logLuxFloor)) * histogramBucketsPerDecade))
+}
+
+// HistogramBucketLux returns the lowest lux of bucket b.
+func HistogramBucketLux(b int) float64 {
+	return logLuxFloor * math.Pow(10, float64(b)/histogramBucketsPerDecade)
+}
+
+// HistogramField names bucket b by its lowest lux, for storage.
+func HistogramField(b int) string {
+	return strconv.FormatFloat(HistogramBucketLux(b), 'g', 3, 64)
+}
+
+// ParseLuxHistogram reads a histogram stored as HistogramField names and
+// seconds.
+func ParseLuxHistogram(fields map[string]string) (LuxHistogram, error) {
+	h := make(LuxHistogram, len(fields))
+	for name, value := range fields {
+		lux, err := strconv.ParseFloat(name, 64)
+		if err != nil || lux < logLuxFloor {
+			return nil, fmt.Errorf("invalid histogram bucket %q", name)
+		}
+		secs, err := strconv.ParseFloat(value, 64)
+		if err != nil || secs < 0 {
+			return nil, fmt.Errorf("invalid time %q for bucket %q", value, name)
+		}
+		b := int(math.Round((math.Log10(lux) - math.Log10(logLuxFloor)) * histogramBucketsPerDecade))
+		h[b] += secs
+	}
+	return h, nil
+}
+
+// Add credits seconds to the bucket of lux.
+func (h LuxHistogram) Add(lux, seconds float64) {
+	h[HistogramBucket(lux)] += seconds
+}
+
+// Total returns the time in the histogram.
+func (h LuxHistogram) Total() float64 {
+	total := 0.0
+	for _, secs := range h {
+		total += secs
+	}
+	return total
+}
+
+// Buckets returns the non-empty buckets, darkest first.
+func (h LuxHistogram) Buckets() []int {
+	buckets := make([]int, 0, len(h))
+	for b, secs := range h {
+		if secs > 0 {
+			buckets = append(buckets, b)
+		}
+	}
+	sort.Ints(buckets)
+	return buckets
+}
+
+// Quantile returns the lux below which a share q (0..1) of the time was
+// spent, interpolated within its bucket in log10(lux).
+func (h LuxHistogram) Quantile(q float64) float64 {
+	total := h.Total()
+	buckets := h.Buckets()
+	if total <= 0 {
+		return 0
+	}
+	want := math.Min(math.Max(q, 0), 1) * total
+	seen := 0.0
+	for _, b := range buckets {
+		secs := h[b]
+		if seen+secs >= want {
+			frac := (want - seen) / secs
+			return HistogramBucketLux(b) * math.Pow(10, frac/histogramBucketsPerDecade)
+		}
+		seen += secs
+	}
+	return HistogramBucketLux(buckets[len(buckets)-1] + 1)
+}
+
+// SuggestCurve keeps the brightness of each curve point and moves the
+// points to evenly spaced quantiles of h, so every step of the curve
+// covers an equal share of the time the rider actually spends. The
+// darkest and brightest histogramTrim of the time are ignored. logLux
+// gives the lux in decades, as -log-lux curves take them.
+func SuggestCurve(curve []Point, h LuxHistogram, logLux bool) ([]Point, error) {
+	if len(curve) < 2 {
+		return nil, fmt.Errorf("curve needs at least 2 points, got %d", len(curve))
+	}
+	if h.Total() <= 0 {
+		return nil, fmt.Errorf("the histogram is empty")
+	}
+	points := make([]Point, len(curve))
+	prev := math.Inf(-1)
+	for i, p := range curve {
+		q := histogramTrim + (1-2*histogramTrim)*float64(i)/float64(len(curve)-1)
+		lux := h.Quantile(q)
+		if logLux {
+			lux = math.Log10(math.Max(lux, logLuxFloor))
+		}
+		lux = roundSignificant(lux, 2)
+		if lux <= prev {
+			// Quantiles in one narrow bucket can round together.
+			lux = prev + math.Max(math.Abs(prev)*0.01, 0.01)
+			lux = math.Round(lux*100) / 100
+		}
+		points[i] = Point{Lux: lux, Brightness: p.Brightness}
+		prev = lux
+	}
+	return points, nil
+}
+
+// FormatCurve renders points in the -curve flag syntax.
+func FormatCurve(points []Point) string {
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = strconv.FormatFloat(p.Lux, 'f', -1, 64) + ":" + strconv.Itoa(p.Brightness)
+	}
+	return strings.Join(parts, " ")
+}
+
+// roundSignificant rounds v to n significant digits.
+func roundSignificant(v float64, n int) float64 {
+	if v == 0 {
+		return 0
+	}
+	scale := math.Pow(10, float64(n)-math.Ceil(math.Log10(math.Abs(v))))
+	return math.Round(v*scale) / scale
+}
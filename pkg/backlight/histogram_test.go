@@ -0,0 +1,104 @@
+package backlight
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	for _, lux := range []float64{0, 0.01, 0.5, 1, 7, 100, 35000} {
+		b := HistogramBucket(lux)
+		low := HistogramBucketLux(b)
+		if lux >= logLuxFloor && (lux < low*0.999 || lux >= HistogramBucketLux(b+1)) {
+			t.Errorf("lux %v in bucket %d from %v", lux, b, low)
+		}
+	}
+	if HistogramBucket(0) != 0 || HistogramBucket(-1) != 0 {
+		t.Error("expected lux at or below the floor in bucket 0")
+	}
+}
+
+func TestParseLuxHistogramRoundTrips(t *testing.T) {
+	h := LuxHistogram{}
+	h.Add(0.3, 10)
+	h.Add(12, 20)
+	h.Add(30000, 5)
+	fields := map[string]string{}
+	for b, secs := range h {
+		fields[HistogramField(b)] = strconv.FormatFloat(secs, 'f', -1, 64)
+	}
+	got, err := ParseLuxHistogram(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for b, secs := range h {
+		if got[b] != secs {
+			t.Errorf("bucket %d: got %v, want %v", b, got[b], secs)
+		}
+	}
+	if _, err := ParseLuxHistogram(map[string]string{"dark": "1"}); err == nil {
+		t.Error("expected an error for a bad bucket")
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := LuxHistogram{}
+	h.Add(1, 50)
+	h.Add(100, 50)
+	if q := h.Quantile(0.25); q < 1 || q >= HistogramBucketLux(HistogramBucket(1)+1) {
+		t.Errorf("expected the lower quartile in the 1 lux bucket, got %v", q)
+	}
+	if q := h.Quantile(0.75); q < 100 || q >= HistogramBucketLux(HistogramBucket(100)+1) {
+		t.Errorf("expected the upper quartile in the 100 lux bucket, got %v", q)
+	}
+}
+
+func TestSuggestCurveFollowsHistogram(t *testing.T) {
+	curve := []Point{{0, 400}, {10, 4000}, {80, 10240}}
+	h := LuxHistogram{}
+	for lux := 1.0; lux <= 1000; lux *= 1.5 {
+		h.Add(lux, 60)
+	}
+	points, err := SuggestCurve(curve, h, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range points {
+		if p.Brightness != curve[i].Brightness {
+			t.Errorf("point %d: brightness %d, want %d", i, p.Brightness, curve[i].Brightness)
+		}
+		if i > 0 && p.Lux <= points[i-1].Lux {
+			t.Errorf("point %d: lux %v not above %v", i, p.Lux, points[i-1].Lux)
+		}
+	}
+	if mid := points[1].Lux; mid < 10 || mid > 100 {
+		t.Errorf("expected the middle point near the median of 1 to 1000 lux, got %v", mid)
+	}
+
+	logPoints, err := SuggestCurve(curve, h, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := logPoints[1].Lux - math.Log10(points[1].Lux); math.Abs(d) > 0.1 {
+		t.Errorf("expected log lux %v, got %v", math.Log10(points[1].Lux), logPoints[1].Lux)
+	}
+
+	if _, err := SuggestCurve(curve, LuxHistogram{}, false); err == nil {
+		t.Error("expected an error for an empty histogram")
+	}
+}
+
+func TestSuggestCurveSeparatesNarrowHistogram(t *testing.T) {
+	h := LuxHistogram{}
+	h.Add(5, 3600)
+	points, err := SuggestCurve([]Point{{0, 400}, {5, 2000}, {10, 4000}, {80, 10240}}, h, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].Lux <= points[i-1].Lux {
+			t.Errorf("points %d and %d not increasing: %v", i-1, i, points)
+		}
+	}
+}
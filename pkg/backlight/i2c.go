@@ -0,0 +1,106 @@
+package backlight
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// I2CConfig describes the brightness register of an I2C LED driver, such
+// as the LP855x family.
+type I2CConfig struct {
+	Bus       string // device node, e.g. /dev/i2c-1
+	Address   uint16 // 7-bit slave address
+	Register  uint8
+	Size      int // register width in bytes, 1 or 2
+	BigEndian bool
+	Max       int // brightness value at full scale
+}
+
+func (c I2CConfig) validate() error {
+	if c.Address > 0x7f {
+		return fmt.Errorf("i2c-address 0x%x is not a 7-bit address", c.Address)
+	}
+	switch c.Size {
+	case 1, 2:
+	default:
+		return fmt.Errorf("i2c-size must be 1 or 2, got %d", c.Size)
+	}
+	if limit := 1<<(8*c.Size) - 1; c.Max <= 0 || c.Max > limit {
+		return fmt.Errorf("i2c-max must be between 1 and %d, got %d", limit, c.Max)
+	}
+	return nil
+}
+
+func (c I2CConfig) order() binary.ByteOrder {
+	if c.BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// encode appends the register write for value to buf: the register
+// address followed by the value bytes.
+func (c I2CConfig) encode(buf []byte, value int) []byte {
+	if value < 0 {
+		value = 0
+	}
+	if value > c.Max {
+		value = c.Max
+	}
+	buf = append(buf, c.Register)
+	if c.Size == 1 {
+		return append(buf, byte(value))
+	}
+	if c.BigEndian {
+		return binary.BigEndian.AppendUint16(buf, uint16(value))
+	}
+	return binary.LittleEndian.AppendUint16(buf, uint16(value))
+}
+
+func (c I2CConfig) decode(b []byte) int {
+	if c.Size == 1 {
+		return int(b[0])
+	}
+	return int(c.order().Uint16(b))
+}
+
+// I2CSink programs the brightness register of an I2C LED driver, for
+// display assemblies that expose no backlight through sysfs.
+type I2CSink struct {
+	cfg I2CConfig
+
+	mu  sync.Mutex // a register read is a write and a read that must not interleave
+	dev io.ReadWriteCloser
+	buf [3]byte // register write, reused so writes do not allocate
+}
+
+func (s *I2CSink) Read() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.dev.Write([]byte{s.cfg.Register}); err != nil {
+		return 0, fmt.Errorf("failed to select I2C register 0x%02x: %v", s.cfg.Register, err)
+	}
+	b := make([]byte, s.cfg.Size)
+	if _, err := io.ReadFull(s.dev, b); err != nil {
+		return 0, fmt.Errorf("failed to read I2C register 0x%02x: %v", s.cfg.Register, err)
+	}
+	return s.cfg.decode(b), nil
+}
+
+func (s *I2CSink) Write(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.dev.Write(s.cfg.encode(s.buf[:0], value))
+	return err
+}
+
+// Max returns the configured full-scale value.
+func (s *I2CSink) Max() int { return s.cfg.Max }
+
+func (s *I2CSink) String() string {
+	return fmt.Sprintf("%s@0x%02x/0x%02x", s.cfg.Bus, s.cfg.Address, s.cfg.Register)
+}
+
+func (s *I2CSink) Close() error { return s.dev.Close() }
@@ -0,0 +1,29 @@
+//go:build linux
+
+package backlight
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// i2cSlave is the I2C_SLAVE ioctl from linux/i2c-dev.h.
+const i2cSlave = 0x0703
+
+// OpenI2C opens the I2C bus device and binds it to cfg.Address.
+func OpenI2C(cfg I2CConfig) (*I2CSink, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(cfg.Bus, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I2C bus: %v", err)
+	}
+	if err := unix.IoctlSetInt(int(f.Fd()), i2cSlave, int(cfg.Address)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to select I2C address 0x%02x: %v", cfg.Address, err)
+	}
+	return &I2CSink{cfg: cfg, dev: f}, nil
+}
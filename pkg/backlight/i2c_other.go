@@ -0,0 +1,10 @@
+//go:build !linux
+
+package backlight
+
+import "fmt"
+
+// OpenI2C is only supported on Linux.
+func OpenI2C(cfg I2CConfig) (*I2CSink, error) {
+	return nil, fmt.Errorf("I2C is only supported on Linux")
+}
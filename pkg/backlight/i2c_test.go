@@ -0,0 +1,61 @@
+package backlight
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fakeI2C emulates a device with an auto-incrementing register file.
+type fakeI2C struct {
+	regs [256]byte
+	ptr  int
+}
+
+func (d *fakeI2C) Write(p []byte) (int, error) {
+	d.ptr = int(p[0])
+	copy(d.regs[d.ptr:], p[1:])
+	return len(p), nil
+}
+
+func (d *fakeI2C) Read(p []byte) (int, error) {
+	return copy(p, d.regs[d.ptr:]), nil
+}
+
+func (d *fakeI2C) Close() error { return nil }
+
+func TestI2CConfigValidate(t *testing.T) {
+	bad := []I2CConfig{
+		{Address: 0x80, Size: 1, Max: 255},
+		{Address: 0x2c, Size: 3, Max: 255},
+		{Address: 0x2c, Size: 1, Max: 256},
+		{Address: 0x2c, Size: 2, Max: 0},
+	}
+	for _, cfg := range bad {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+	if err := (I2CConfig{Address: 0x2c, Size: 2, Max: 4095}).validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestI2CSinkWritesRegister(t *testing.T) {
+	dev := &fakeI2C{}
+	s := &I2CSink{cfg: I2CConfig{Register: 0x10, Size: 2, BigEndian: true, Max: 4095}, dev: dev}
+
+	if err := s.Write(0x0abc); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dev.regs[0x10:0x12], []byte{0x0a, 0xbc}) {
+		t.Errorf("expected big-endian 0x0abc, got % x", dev.regs[0x10:0x12])
+	}
+	if got, err := s.Read(); err != nil || got != 0x0abc {
+		t.Errorf("expected 0x0abc read back, got 0x%x (%v)", got, err)
+	}
+
+	s.Write(5000)
+	if got, _ := s.Read(); got != 4095 {
+		t.Errorf("expected value clamped to 4095, got %d", got)
+	}
+}
@@ -0,0 +1,95 @@
+package backlight
+
+import (
+	"fmt"
+	"math"
+)
+
+// SetCurveOffsets adds offsets[i] to the brightness of curve point i, as
+// learned by Learn. nil clears them.
+func (m *Manager) SetCurveOffsets(offsets []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if offsets != nil && len(offsets) != len(m.curve) {
+		return fmt.Errorf("need %d curve offsets, got %d", len(m.curve), len(offsets))
+	}
+	m.offsets = nil
+	if offsets != nil {
+		m.offsets = append([]int(nil), offsets...)
+	}
+	return nil
+}
+
+// CurveOffsets returns the learned offsets, nil if there are none.
+func (m *Manager) CurveOffsets() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.offsets == nil {
+		return nil
+	}
+	return append([]int(nil), m.offsets...)
+}
+
+// Learn takes preferred as the rider's choice at the current smoothed lux
+// and moves the two curve points around it rate (0..1) of the way from
+// the current target towards it, weighted by how close each point is.
+// Offsets stay within limit of the configured curve. It returns the new
+// offsets and whether the lux is known; the target itself is left for
+// the next tick.
+func (m *Manager) Learn(preferred int, rate float64, limit int) ([]int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.state.Filter.Started || m.state.Target < 0 || rate <= 0 {
+		return nil, false
+	}
+	if m.offsets == nil {
+		m.offsets = make([]int, len(m.curve))
+	}
+	x := m.state.Filter.Estimate
+	diff := float64(preferred - m.state.Target)
+	i, t := m.segment(x)
+	m.nudge(i, rate*(1-t)*diff, limit)
+	if t > 0 {
+		m.nudge(i+1, rate*t*diff, limit)
+	}
+	return append([]int(nil), m.offsets...), true
+}
+
+// segment returns the curve point at or below x and how far x is towards
+// the next one (0..1). Below and above the curve it is the end point.
+func (m *Manager) segment(x float64) (int, float64) {
+	n := len(m.curve)
+	if x <= m.curve[0].Lux {
+		return 0, 0
+	}
+	for i := 1; i < n; i++ {
+		if x <= m.curve[i].Lux {
+			return i - 1, (x - m.curve[i-1].Lux) / (m.curve[i].Lux - m.curve[i-1].Lux)
+		}
+	}
+	return n - 1, 0
+}
+
+// nudge adds delta to the offset of point i, within limit.
+func (m *Manager) nudge(i int, delta float64, limit int) {
+	v := m.offsets[i] + int(math.Round(delta))
+	if limit > 0 && v > limit {
+		v = limit
+	} else if limit > 0 && v < -limit {
+		v = -limit
+	}
+	m.offsets[i] = v
+}
+
+// pointBrightness returns the brightness of curve point i with its
+// learned offset, never below 0.
+func (m *Manager) pointBrightness(i int) int {
+	b := m.curve[i].Brightness
+	if m.offsets != nil {
+		b += m.offsets[i]
+	}
+	if b < 0 {
+		return 0
+	}
+	return b
+}
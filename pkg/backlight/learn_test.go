@@ -0,0 +1,58 @@
+package backlight
+
+import "testing"
+
+func TestLearnMovesNearbyPoints(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.AdjustBacklight(7.5); err != nil {
+		t.Fatal(err)
+	}
+	if m.Target() != 4600 {
+		t.Fatalf("expected a target of 4600 at 7.5 lux, got %d", m.Target())
+	}
+	offsets, ok := m.Learn(5600, 0.5, 0)
+	if !ok {
+		t.Fatal("expected the correction to be learned")
+	}
+	for i, v := range offsets {
+		want := 0
+		if i == 4 || i == 5 {
+			want = 250
+		}
+		if v != want {
+			t.Errorf("offset %d: got %d, want %d", i, v, want)
+		}
+	}
+	if b := m.Interpolate(7.5); b != 4850 {
+		t.Errorf("expected the curve to give 4850 at 7.5 lux, got %d", b)
+	}
+	if b := m.Interpolate(50); b != 9600 {
+		t.Errorf("expected distant points to stay put, got %d at 50 lux", b)
+	}
+}
+
+func TestLearnIsBounded(t *testing.T) {
+	m := newTestManager(t)
+	m.AdjustBacklight(5)
+	for i := 0; i < 10; i++ {
+		m.Learn(10240, 1, 300)
+	}
+	if b := m.Interpolate(5); b != 4300 {
+		t.Errorf("expected the point to stop 300 above 4000, got %d", b)
+	}
+	offsets := m.CurveOffsets()
+	m.SetCurve(defaultCurve)
+	if m.CurveOffsets() != nil || offsets[4] != 300 {
+		t.Errorf("expected offsets %v to be dropped with a new curve", offsets)
+	}
+}
+
+func TestLearnNeedsAReading(t *testing.T) {
+	m := newTestManager(t)
+	if _, ok := m.Learn(5000, 0.5, 0); ok {
+		t.Error("expected no learning before the first reading")
+	}
+	if err := m.SetCurveOffsets([]int{1, 2}); err == nil {
+		t.Error("expected an error for offsets that don't match the curve")
+	}
+}
@@ -0,0 +1,82 @@
+package backlight
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Follower is an LED class device whose brightness tracks the display.
+type Follower struct {
+	Name  string
+	path  string
+	max   int
+	scale float64
+	last  int
+}
+
+// ParseFollowers parses "name:scale" pairs naming devices under ledsDir,
+// reading each device's max_brightness.
+// Example: "button-backlight:1 handlebar-indicator:0.5"
+func ParseFollowers(s, ledsDir string) ([]*Follower, error) {
+	var followers []*Follower
+	for _, f := range strings.Fields(s) {
+		parts := strings.SplitN(f, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid LED %q (expected name:scale)", f)
+		}
+		scale, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || scale < 0 {
+			return nil, fmt.Errorf("invalid scale %q for LED %s", parts[1], parts[0])
+		}
+		dir := filepath.Join(ledsDir, parts[0])
+		max, err := readIntFile(filepath.Join(dir, "max_brightness"))
+		if err != nil {
+			return nil, fmt.Errorf("LED %s: %v", parts[0], err)
+		}
+		followers = append(followers, &Follower{
+			Name:  parts[0],
+			path:  filepath.Join(dir, "brightness"),
+			max:   max,
+			scale: scale,
+			last:  -1,
+		})
+	}
+	return followers, nil
+}
+
+// Follow sets the LED to fraction (0..1) of the display's range, scaled by
+// the device's factor and clamped to its max_brightness. It only writes when
+// the resulting value changes.
+func (f *Follower) Follow(fraction float64) error {
+	v := int(math.Round(fraction * f.scale * float64(f.max)))
+	if v < 0 {
+		v = 0
+	}
+	if v > f.max {
+		v = f.max
+	}
+	if v == f.last {
+		return nil
+	}
+	if err := os.WriteFile(f.path, []byte(strconv.Itoa(v)), 0644); err != nil {
+		return fmt.Errorf("failed to write LED %s: %v", f.Name, err)
+	}
+	f.last = v
+	return nil
+}
+
+func readIntFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid value in %s: %v", path, err)
+	}
+	return v, nil
+}
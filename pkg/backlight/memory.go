@@ -0,0 +1,33 @@
+package backlight
+
+import "sync"
+
+// MemorySink keeps the brightness in memory, standing in for a panel on
+// machines that have none.
+type MemorySink struct {
+	mu    sync.Mutex
+	value int
+	max   int
+}
+
+// NewMemorySink returns a sink accepting values up to max, starting at 0.
+func NewMemorySink(max int) *MemorySink {
+	return &MemorySink{max: max}
+}
+
+func (s *MemorySink) Read() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value, nil
+}
+
+func (s *MemorySink) Write(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value = value
+	return nil
+}
+
+func (s *MemorySink) Max() int { return s.max }
+
+func (s *MemorySink) String() string { return "memory" }
@@ -0,0 +1,54 @@
+package backlight
+
+import (
+	"fmt"
+	"log"
+)
+
+// MirrorSink writes every value to Primary and copies it to Mirror, for a
+// mirrored passenger display or to capture writes on a test rig. Reads
+// and Max come from Primary. A failing mirror never fails the write; its
+// errors are logged once until it recovers.
+type MirrorSink struct {
+	Primary Sink
+	Mirror  Sink
+
+	logger  *log.Logger
+	failing bool
+}
+
+// NewMirrorSink returns a sink writing to primary and mirror.
+func NewMirrorSink(primary, mirror Sink, logger *log.Logger) *MirrorSink {
+	return &MirrorSink{Primary: primary, Mirror: mirror, logger: logger}
+}
+
+func (s *MirrorSink) Read() (int, error) { return s.Primary.Read() }
+
+func (s *MirrorSink) Max() int { return s.Primary.Max() }
+
+// ReadActual passes actual_brightness through from Primary.
+func (s *MirrorSink) ReadActual() (int, error) {
+	return readActual(s.Primary)
+}
+
+// Write writes value to Primary, then to Mirror scaled to its range if
+// both report a maximum.
+func (s *MirrorSink) Write(value int) error {
+	err := s.Primary.Write(value)
+	mirrored := value
+	if pmax, mmax := s.Primary.Max(), s.Mirror.Max(); pmax > 0 && mmax > 0 && pmax != mmax {
+		mirrored = value * mmax / pmax
+	}
+	if merr := s.Mirror.Write(mirrored); merr != nil {
+		if !s.failing {
+			s.logger.Printf("Warning: mirror write failed: %v", merr)
+			s.failing = true
+		}
+	} else if s.failing {
+		s.logger.Printf("Mirror %v writable again", s.Mirror)
+		s.failing = false
+	}
+	return err
+}
+
+func (s *MirrorSink) String() string { return fmt.Sprintf("%v, mirrored to %v", s.Primary, s.Mirror) }
@@ -0,0 +1,40 @@
+package backlight
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+)
+
+type maxSink struct {
+	memSink
+	max int
+}
+
+func (s *maxSink) Max() int { return s.max }
+
+type brokenSink struct{ memSink }
+
+func (s *brokenSink) Write(int) error { return errors.New("broken") }
+
+func TestMirrorSinkScalesToMirrorRange(t *testing.T) {
+	primary := &memSink{}
+	mirror := &maxSink{max: 255}
+	s := NewMirrorSink(primary, mirror, log.New(io.Discard, "", 0))
+
+	if err := s.Write(5120); err != nil {
+		t.Fatal(err)
+	}
+	if primary.value != 5120 || mirror.value != 127 {
+		t.Errorf("expected 5120 and 127, got %d and %d", primary.value, mirror.value)
+	}
+}
+
+func TestMirrorSinkIgnoresMirrorErrors(t *testing.T) {
+	primary := &memSink{}
+	s := NewMirrorSink(primary, &brokenSink{}, log.New(io.Discard, "", 0))
+	if err := s.Write(3000); err != nil || primary.value != 3000 {
+		t.Errorf("expected the primary write to succeed, got %v and %d", err, primary.value)
+	}
+}
@@ -0,0 +1,136 @@
+package backlight
+
+import (
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"testing/quick"
+)
+
+// memSink is an in-memory sink for tests that run many ticks.
+type memSink struct{ value int }
+
+func (s *memSink) Read() (int, error)    { return s.value, nil }
+func (s *memSink) Write(value int) error { s.value = value; return nil }
+func (s *memSink) Max() int              { return 10240 }
+
+// randomCurve returns a valid curve: 2 to 8 points with increasing lux and
+// non-decreasing brightness.
+func randomCurve(r *rand.Rand) []Point {
+	n := 2 + r.Intn(7)
+	lux := make([]float64, n)
+	brightness := make([]int, n)
+	for i := range lux {
+		lux[i] = r.Float64() * 1000
+		brightness[i] = r.Intn(10241)
+	}
+	sort.Float64s(lux)
+	sort.Ints(brightness)
+	curve := make([]Point, n)
+	for i := range curve {
+		curve[i] = Point{Lux: lux[i] + float64(i)*1e-3, Brightness: brightness[i]}
+	}
+	return curve
+}
+
+// randomManager builds a Manager over a random curve with random
+// smoothing, ramp and log mode. Jumps and tunnels stay off: they are
+// meant to skip smoothing, not to break ordering.
+func randomManager(r *rand.Rand) *Manager {
+	m := NewWithSink(&memSink{value: r.Intn(10241)}, log.New(io.Discard, "", 0),
+		randomCurve(r), 0.01+r.Float64()*0.99, 0.01+r.Float64()*0.99)
+	m.SetLogLux(r.Intn(2) == 0)
+	return m
+}
+
+func checkProperty(t *testing.T, property func(r *rand.Rand) bool) {
+	t.Helper()
+	f := func(seed int64) bool { return property(rand.New(rand.NewSource(seed))) }
+	if err := quick.Check(f, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyInterpolateIsMonotonic(t *testing.T) {
+	checkProperty(t, func(r *rand.Rand) bool {
+		m := randomManager(r)
+		prev := math.MinInt
+		for x := -10.0; x < 1100; x += r.Float64() * 20 {
+			b := m.Interpolate(x)
+			if b < prev {
+				return false
+			}
+			prev = b
+		}
+		return true
+	})
+}
+
+// followsDirection feeds readings that only move in one direction and
+// reports whether target and output never moved the other way.
+func followsDirection(r *rand.Rand, rising bool) bool {
+	m := randomManager(r)
+	lux := r.Float64() * 1000
+	m.AdjustBacklight(lux)
+	target, output := m.Target(), m.Output()
+	for i := 0; i < 100; i++ {
+		step := r.Float64() * 30
+		if rising {
+			lux += step
+		} else {
+			lux = math.Max(lux-step, 0)
+		}
+		m.AdjustBacklight(lux)
+		if rising && (m.Target() < target || m.Output() < output) {
+			return false
+		}
+		if !rising && (m.Target() > target || m.Output() > output) {
+			return false
+		}
+		target, output = m.Target(), m.Output()
+	}
+	return true
+}
+
+func TestPropertyRisingLuxNeverDims(t *testing.T) {
+	checkProperty(t, func(r *rand.Rand) bool { return followsDirection(r, true) })
+}
+
+func TestPropertyFallingLuxNeverBrightens(t *testing.T) {
+	checkProperty(t, func(r *rand.Rand) bool { return followsDirection(r, false) })
+}
+
+func TestPropertyHysteresisBandHoldsTarget(t *testing.T) {
+	checkProperty(t, func(r *rand.Rand) bool {
+		m := randomManager(r)
+		m.SetLuxHysteresis(0.05 + r.Float64()*0.5)
+		lux := r.Float64() * 1000
+		for i := 0; i < 300; i++ {
+			m.AdjustBacklight(lux)
+		}
+
+		// Readings whose curve input stays inside the band around the
+		// anchor keep the smoothed input there too.
+		st := m.State()
+		band := m.hysteresisBand(st.AnchorLux)
+		target := m.Target()
+		for i := 0; i < 100; i++ {
+			x := st.AnchorLux + (r.Float64()*2-1)*band*0.999
+			reading := x
+			if m.logLux {
+				reading = math.Pow(10, x)
+			}
+			if reading < 0 {
+				reading = 0
+			}
+			m.AdjustBacklight(reading)
+			if m.Target() != target {
+				return false
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,110 @@
+package backlight
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pwmExportWait bounds how long the channel directory may take to appear
+// after exporting it; udev has to fix up its permissions first.
+const pwmExportWait = time.Second
+
+// PWMSink drives a PWM channel directly through the sysfs PWM interface,
+// for boards without a kernel backlight driver. Brightness 0..steps maps
+// linearly onto a duty cycle of 0..period.
+type PWMSink struct {
+	dir    string // channel directory, e.g. /sys/class/pwm/pwmchip0/pwm0
+	period int64  // nanoseconds
+	steps  int
+	duty   *SysfsSink // kept open for the per-tick duty cycle writes
+}
+
+// NewPWMSink exports channel of the PWM chip at chipDir if needed, sets
+// its period and enables it. The current duty cycle is left as is.
+func NewPWMSink(chipDir string, channel int, period time.Duration, steps int) (*PWMSink, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("PWM period must be positive, got %v", period)
+	}
+	if steps <= 0 {
+		return nil, fmt.Errorf("PWM steps must be positive, got %d", steps)
+	}
+	s := &PWMSink{
+		dir:    filepath.Join(chipDir, fmt.Sprintf("pwm%d", channel)),
+		period: period.Nanoseconds(),
+		steps:  steps,
+	}
+	s.duty = NewSysfsSink(filepath.Join(s.dir, "duty_cycle"))
+
+	if _, err := os.Stat(s.dir); os.IsNotExist(err) {
+		if err := os.WriteFile(filepath.Join(chipDir, "export"), []byte(strconv.Itoa(channel)), 0644); err != nil {
+			return nil, fmt.Errorf("failed to export PWM channel %d: %v", channel, err)
+		}
+		deadline := time.Now().Add(pwmExportWait)
+		for {
+			if _, err := os.Stat(filepath.Join(s.dir, "enable")); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("PWM channel %s did not appear after export", s.dir)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The duty cycle may never exceed the period, so shrink it first when
+	// the new period is shorter.
+	if duty, err := readInt64File(filepath.Join(s.dir, "duty_cycle")); err == nil && duty > s.period {
+		if err := s.writeAttr("duty_cycle", s.period); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.writeAttr("period", s.period); err != nil {
+		return nil, err
+	}
+	if err := s.writeAttr("enable", 1); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *PWMSink) writeAttr(name string, value int64) error {
+	if err := os.WriteFile(filepath.Join(s.dir, name), []byte(strconv.FormatInt(value, 10)), 0644); err != nil {
+		return fmt.Errorf("failed to set PWM %s: %v", name, err)
+	}
+	return nil
+}
+
+func (s *PWMSink) Read() (int, error) {
+	duty, err := readInt64File(filepath.Join(s.dir, "duty_cycle"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read PWM duty cycle: %v", err)
+	}
+	return int((duty*int64(s.steps) + s.period/2) / s.period), nil
+}
+
+func (s *PWMSink) Write(value int) error {
+	if value < 0 {
+		value = 0
+	}
+	if value > s.steps {
+		value = s.steps
+	}
+	return s.duty.Write(int(int64(value) * s.period / int64(s.steps)))
+}
+
+// Max returns the configured number of steps.
+func (s *PWMSink) Max() int { return s.steps }
+
+func (s *PWMSink) String() string { return s.dir }
+
+func readInt64File(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -0,0 +1,78 @@
+package backlight
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePWMChip creates a chip directory whose channel 0 is already exported
+// with the given duty cycle.
+func fakePWMChip(t testing.TB, duty string) string {
+	t.Helper()
+	chip := t.TempDir()
+	channel := filepath.Join(chip, "pwm0")
+	os.Mkdir(channel, 0755)
+	for name, value := range map[string]string{"enable": "0", "period": "0", "duty_cycle": duty} {
+		os.WriteFile(filepath.Join(channel, name), []byte(value+"\n"), 0644)
+	}
+	return chip
+}
+
+func readAttr(t *testing.T, chip, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(chip, "pwm0", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestPWMSinkMapsStepsToDutyCycle(t *testing.T) {
+	chip := fakePWMChip(t, "25000")
+	s, err := NewPWMSink(chip, 0, 50*time.Microsecond, 10240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAttr(t, chip, "period"); got != "50000" {
+		t.Errorf("expected period 50000, got %s", got)
+	}
+	if got := readAttr(t, chip, "enable"); got != "1" {
+		t.Errorf("expected channel enabled, got %s", got)
+	}
+	if got, _ := s.Read(); got != 5120 {
+		t.Errorf("expected initial brightness 5120, got %d", got)
+	}
+
+	s.Write(2560)
+	if got := readAttr(t, chip, "duty_cycle"); got != "12500" {
+		t.Errorf("expected duty cycle 12500, got %s", got)
+	}
+	s.Write(20000)
+	if got := readAttr(t, chip, "duty_cycle"); got != "50000" {
+		t.Errorf("expected duty cycle clamped to the period, got %s", got)
+	}
+}
+
+func TestPWMSinkShrinksDutyBeforePeriod(t *testing.T) {
+	chip := fakePWMChip(t, "100000")
+	if _, err := NewPWMSink(chip, 0, 50*time.Microsecond, 10240); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAttr(t, chip, "duty_cycle"); got != "50000" {
+		t.Errorf("expected duty cycle reduced to the new period, got %s", got)
+	}
+}
+
+func TestPWMSinkExportTimesOut(t *testing.T) {
+	chip := t.TempDir()
+	os.WriteFile(filepath.Join(chip, "export"), nil, 0644)
+	if _, err := NewPWMSink(chip, 1, 50*time.Microsecond, 10240); err == nil {
+		t.Error("expected error when the channel never appears")
+	}
+	if data, _ := os.ReadFile(filepath.Join(chip, "export")); string(data) != "1" {
+		t.Errorf("expected channel 1 exported, got %q", data)
+	}
+}
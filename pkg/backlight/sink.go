@@ -0,0 +1,137 @@
+package backlight
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Sink is where the Manager writes brightness values and reads the
+// hardware state back from.
+type Sink interface {
+	Read() (int, error)
+	Write(value int) error
+	// Max returns the highest value Write accepts, or -1 if unknown.
+	Max() int
+}
+
+// ActualReader is implemented by sinks that can report the value the
+// hardware applied separately from the one last written, as the sysfs
+// actual_brightness attribute does.
+type ActualReader interface {
+	ReadActual() (int, error)
+}
+
+// readActual reads actual_brightness from sink if it has it, and brightness
+// otherwise.
+func readActual(sink Sink) (int, error) {
+	if a, ok := sink.(ActualReader); ok {
+		return a.ReadActual()
+	}
+	return sink.Read()
+}
+
+// SysfsSink drives a kernel backlight class device through its
+// brightness file. The file is kept open between writes and values are
+// formatted into a reused buffer, so a write does not allocate. Like the
+// Manager that owns it, a SysfsSink is not safe for concurrent writes.
+type SysfsSink struct {
+	path    string
+	file    *os.File
+	regular bool // a file on a real filesystem, as in tests, must be truncated first
+	buf     [20]byte
+}
+
+// NewSysfsSink returns a sink writing to the brightness file at path.
+func NewSysfsSink(path string) *SysfsSink {
+	return &SysfsSink{path: path}
+}
+
+func (s *SysfsSink) Read() (int, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read backlight file: %v", err)
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid brightness value: %v", err)
+	}
+	return value, nil
+}
+
+func (s *SysfsSink) Write(value int) error {
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	err := s.write(value)
+	if err != nil {
+		// The device may have been re-registered, for example across
+		// a suspend. Reopen once before giving up.
+		s.Close()
+		if err = s.open(); err != nil {
+			return err
+		}
+		err = s.write(value)
+	}
+	return err
+}
+
+func (s *SysfsSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.regular = info.Mode().IsRegular() && !isSysfs(f)
+	return nil
+}
+
+func (s *SysfsSink) write(value int) error {
+	if s.regular {
+		if err := s.file.Truncate(0); err != nil {
+			return err
+		}
+	}
+	_, err := s.file.WriteAt(strconv.AppendInt(s.buf[:0], int64(value), 10), 0)
+	return err
+}
+
+// Close releases the brightness file. The next Write opens it again.
+func (s *SysfsSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// ReadActual reads actual_brightness next to the brightness file: what
+// the driver applied, where brightness only echoes the last request.
+func (s *SysfsSink) ReadActual() (int, error) {
+	value, err := readIntFile(filepath.Join(filepath.Dir(s.path), "actual_brightness"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read actual_brightness: %v", err)
+	}
+	return value, nil
+}
+
+// Max reads max_brightness next to the brightness file.
+func (s *SysfsSink) Max() int {
+	max, err := readIntFile(filepath.Join(filepath.Dir(s.path), "max_brightness"))
+	if err != nil {
+		return -1
+	}
+	return max
+}
+
+func (s *SysfsSink) String() string { return s.path }
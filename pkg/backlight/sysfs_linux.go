@@ -0,0 +1,19 @@
+//go:build linux
+
+package backlight
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// isSysfs reports whether f lives on sysfs, whose attribute files look
+// like regular files but are rewritten whole by every write.
+func isSysfs(f *os.File) bool {
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(int(f.Fd()), &st); err != nil {
+		return false
+	}
+	return st.Type == unix.SYSFS_MAGIC
+}
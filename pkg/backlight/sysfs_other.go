@@ -0,0 +1,8 @@
+//go:build !linux
+
+package backlight
+
+import "os"
+
+// isSysfs is always false off Linux.
+func isSysfs(f *os.File) bool { return false }
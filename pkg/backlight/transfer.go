@@ -0,0 +1,73 @@
+package backlight
+
+import "math"
+
+// transferSteps is the resolution of the hold band search, in steps
+// across the curve.
+const transferSteps = 4096
+
+// TransferRow describes how a steady lux reading maps to a target.
+type TransferRow struct {
+	Lux        float64 `json:"lux"`
+	Brightness int     `json:"brightness"`
+	HoldLow    float64 `json:"hold_low"`
+	HoldHigh   float64 `json:"hold_high"` // +Inf if no brighter reading moves the target
+}
+
+// Transfer returns the target for a steady reading of lux and the lux
+// range later readings may stay in without moving the target away from
+// it: the lux hysteresis band widened by the brightness deadband.
+// Smoothing, ramping and the jump, tunnel and flicker detectors are not
+// modelled.
+func (m *Manager) Transfer(lux float64) TransferRow {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	x := m.curveInput(lux)
+	target := m.biased(m.interpolate(x))
+	holds := func(y float64) bool {
+		if m.luxHysteresis > 0 && math.Abs(y-x) <= m.hysteresisBand(x) {
+			return true
+		}
+		d := m.biased(m.interpolate(y)) - target
+		return d >= -m.targetDeadband && d <= m.targetDeadband
+	}
+
+	first, last := m.curve[0].Lux, m.curve[len(m.curve)-1].Lux
+	step := (last - first) / transferSteps
+	row := TransferRow{Lux: lux, Brightness: target}
+
+	floor := m.curveInput(0)
+	y := x
+	for y-step >= floor && holds(y-step) {
+		y -= step
+	}
+	if y-step < floor {
+		row.HoldLow = 0
+	} else {
+		row.HoldLow = m.luxOf(y)
+	}
+
+	limit := math.Max(last, x) + step
+	if m.luxHysteresis > 0 {
+		limit += m.hysteresisBand(x)
+	}
+	y = x
+	for y+step <= limit && holds(y+step) {
+		y += step
+	}
+	if y+step > limit {
+		row.HoldHigh = math.Inf(1)
+	} else {
+		row.HoldHigh = m.luxOf(y)
+	}
+	return row
+}
+
+// luxOf is the inverse of curveInput.
+func (m *Manager) luxOf(x float64) float64 {
+	if !m.logLux {
+		return x
+	}
+	return math.Pow(10, x)
+}
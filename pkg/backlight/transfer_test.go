@@ -0,0 +1,39 @@
+package backlight
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTransferDeadbandHold(t *testing.T) {
+	m := newTestManager(t)
+	// 10 lux sets 5200; the curve rises 180 per lux there, so the 150
+	// deadband holds until the target would move by more than 150.
+	row := m.Transfer(10)
+	if row.Brightness != 5200 {
+		t.Fatalf("brightness = %d, want 5200", row.Brightness)
+	}
+	if got := m.biased(m.Interpolate(row.HoldHigh)) - 5200; got > 150 || got < 140 {
+		t.Errorf("hold_high %.2f gives a change of %d, want just under the deadband", row.HoldHigh, got)
+	}
+	if got := 5200 - m.biased(m.Interpolate(row.HoldLow)); got > 150 || got < 140 {
+		t.Errorf("hold_low %.2f gives a change of %d, want just under the deadband", row.HoldLow, got)
+	}
+}
+
+func TestTransferLuxHysteresisWidensBand(t *testing.T) {
+	m := newTestManager(t)
+	m.SetLuxHysteresis(0.2)
+	row := m.Transfer(10)
+	if row.HoldLow > 8.01 || row.HoldHigh < 11.99 {
+		t.Errorf("hold band [%.2f, %.2f], want at least the 20%% band [8, 12]", row.HoldLow, row.HoldHigh)
+	}
+}
+
+func TestTransferTopOfCurveHoldsForever(t *testing.T) {
+	m := newTestManager(t)
+	row := m.Transfer(100)
+	if row.Brightness != 10240 || !math.IsInf(row.HoldHigh, 1) {
+		t.Errorf("got %+v, want 10240 held up to +Inf", row)
+	}
+}
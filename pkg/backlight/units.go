@@ -0,0 +1,123 @@
+package backlight
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Units converts brightness values written in raw counts ("1300"),
+// percent of the maximum ("40%") or luminance ("250nits") to raw counts,
+// so levels carry over between panels with different ranges.
+type Units struct {
+	Max  int         // raw full scale for percent (<= 0 = unknown)
+	Nits []NitsPoint // panel calibration for nits, by raw count
+}
+
+// NitsPoint is a measured panel luminance at a raw brightness.
+type NitsPoint struct {
+	Raw  int
+	Nits float64
+}
+
+// ParseNitsTable parses a luminance calibration of "raw:nits" pairs, e.g.
+// "0:0 1300:40 10240:300". Luminance must rise with the raw value. An
+// empty string is an empty table.
+func ParseNitsTable(s string) ([]NitsPoint, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("nits table needs at least 2 points, got %d", len(fields))
+	}
+	table := make([]NitsPoint, 0, len(fields))
+	for _, f := range fields {
+		parts := strings.SplitN(f, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid point %q (expected raw:nits)", f)
+		}
+		raw, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid raw value %q: %v", parts[0], err)
+		}
+		nits, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nits value %q: %v", parts[1], err)
+		}
+		table = append(table, NitsPoint{Raw: raw, Nits: nits})
+	}
+	sort.Slice(table, func(i, j int) bool { return table[i].Raw < table[j].Raw })
+	for i := 1; i < len(table); i++ {
+		if table[i].Nits <= table[i-1].Nits {
+			return nil, fmt.Errorf("luminance must rise with the raw value, %d:%g follows %d:%g",
+				table[i].Raw, table[i].Nits, table[i-1].Raw, table[i-1].Nits)
+		}
+	}
+	return table, nil
+}
+
+// Raw converts one brightness value to raw counts.
+func (u Units) Raw(s string) (int, error) {
+	switch {
+	case strings.HasSuffix(s, "%"):
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return 0, fmt.Errorf("invalid percentage %q", s)
+		}
+		if u.Max <= 0 {
+			return 0, fmt.Errorf("%q needs a known max brightness", s)
+		}
+		return int(math.Round(float64(u.Max) * pct / 100)), nil
+	case strings.HasSuffix(s, "nits"), strings.HasSuffix(s, "nit"):
+		nits, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSuffix(s, "s"), "nit"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid luminance %q", s)
+		}
+		return u.fromNits(nits)
+	}
+	raw, err := strconv.Atoi(s)
+	if err != nil || raw < 0 {
+		return 0, fmt.Errorf("invalid brightness value %q", s)
+	}
+	return raw, nil
+}
+
+// fromNits interpolates the raw count for a luminance in the table.
+func (u Units) fromNits(nits float64) (int, error) {
+	t := u.Nits
+	if len(t) == 0 {
+		return 0, fmt.Errorf("%gnits needs a -nits-table calibration", nits)
+	}
+	if nits < t[0].Nits || nits > t[len(t)-1].Nits {
+		return 0, fmt.Errorf("%gnits is outside the calibrated %g to %g nits", nits, t[0].Nits, t[len(t)-1].Nits)
+	}
+	for i := 1; i < len(t); i++ {
+		if nits <= t[i].Nits {
+			frac := (nits - t[i-1].Nits) / (t[i].Nits - t[i-1].Nits)
+			return t[i-1].Raw + int(math.Round(frac*float64(t[i].Raw-t[i-1].Raw))), nil
+		}
+	}
+	return t[len(t)-1].Raw, nil
+}
+
+// ToNits returns the calibrated luminance at raw, or false without a
+// table.
+func (u Units) ToNits(raw int) (float64, bool) {
+	t := u.Nits
+	if len(t) == 0 {
+		return 0, false
+	}
+	if raw <= t[0].Raw {
+		return t[0].Nits, true
+	}
+	for i := 1; i < len(t); i++ {
+		if raw <= t[i].Raw {
+			frac := float64(raw-t[i-1].Raw) / float64(t[i].Raw-t[i-1].Raw)
+			return t[i-1].Nits + frac*(t[i].Nits-t[i-1].Nits), true
+		}
+	}
+	return t[len(t)-1].Nits, true
+}
@@ -0,0 +1,93 @@
+package backlight
+
+import "testing"
+
+func TestUnitsRaw(t *testing.T) {
+	table, err := ParseNitsTable("1300:40 0:0 10240:300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := Units{Max: 10240, Nits: table}
+	tests := map[string]int{
+		"1300":    1300,
+		"0":       0,
+		"50%":     5120,
+		"100%":    10240,
+		"40nits":  1300,
+		"20nit":   650,
+		"300nits": 10240,
+	}
+	for in, want := range tests {
+		got, err := u.Raw(in)
+		if err != nil {
+			t.Errorf("Raw(%q): %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Raw(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestUnitsRawErrors(t *testing.T) {
+	table, _ := ParseNitsTable("0:0 10240:300")
+	tests := []struct {
+		u  Units
+		in string
+	}{
+		{Units{Max: 10240}, "-1"},
+		{Units{Max: 10240}, "bright"},
+		{Units{Max: 10240}, "120%"},
+		{Units{}, "50%"},
+		{Units{Max: 10240}, "100nits"},
+		{Units{Nits: table}, "400nits"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.u.Raw(tt.in); err == nil {
+			t.Errorf("expected error for %q with %+v", tt.in, tt.u)
+		}
+	}
+}
+
+func TestParseNitsTableErrors(t *testing.T) {
+	tests := []string{
+		"0:0",
+		"0:0 bad",
+		"0:0 x:10",
+		"0:0 100:x",
+		"0:10 100:5",
+	}
+	for _, s := range tests {
+		if _, err := ParseNitsTable(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestUnitsToNits(t *testing.T) {
+	table, _ := ParseNitsTable("0:0 1000:100")
+	u := Units{Nits: table}
+	if n, ok := u.ToNits(500); !ok || n != 50 {
+		t.Errorf("ToNits(500) = %v, %v, want 50, true", n, ok)
+	}
+	if _, ok := (Units{}).ToNits(500); ok {
+		t.Error("ToNits without a table reported a value")
+	}
+}
+
+func TestParseLevelsInUnits(t *testing.T) {
+	levels, err := ParseLevelsIn("low:10% high:100%", Units{Max: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels["low"] != 26 || levels["high"] != 255 {
+		t.Errorf("unexpected levels: %v", levels)
+	}
+	curve, err := ParseCurveIn("0:10% 80:100%", Units{Max: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if curve[0].Brightness != 26 || curve[1].Brightness != 255 {
+		t.Errorf("unexpected curve: %v", curve)
+	}
+}
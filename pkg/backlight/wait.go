@@ -0,0 +1,57 @@
+package backlight
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// devicePollInterval backs up the directory watch: sysfs does not emit
+// inotify events for every device that appears.
+const devicePollInterval = 250 * time.Millisecond
+
+// WaitForDevice blocks until path exists, ctx is cancelled or timeout
+// passes. Early in boot the backlight driver may not have registered yet.
+func WaitForDevice(ctx context.Context, path string, timeout time.Duration) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	wake, stop := watchDir(nearestDir(path))
+	defer stop()
+
+	ticker := time.NewTicker(devicePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not appear within %v", path, timeout)
+		case <-wake:
+		case <-ticker.C:
+		}
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+	}
+}
+
+// nearestDir returns the closest existing ancestor directory of path.
+func nearestDir(path string) string {
+	dir := filepath.Dir(path)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package backlight
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchDir signals on wake whenever an entry is created in dir. If
+// inotify is unavailable the channel never fires and polling takes over.
+func watchDir(dir string) (wake <-chan struct{}, stop func()) {
+	ch := make(chan struct{}, 1)
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return ch, func() {}
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, unix.IN_CREATE|unix.IN_MOVED_TO); err != nil {
+		unix.Close(fd)
+		return ch, func() {}
+	}
+
+	f := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			if _, err := f.Read(buf); err != nil {
+				return
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch, func() { f.Close() }
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package backlight
+
+// watchDir is a no-op off Linux; WaitForDevice falls back to polling.
+func watchDir(dir string) (wake <-chan struct{}, stop func()) {
+	return make(chan struct{}), func() {}
+}
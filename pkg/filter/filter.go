@@ -0,0 +1,188 @@
+// Package filter provides 1D estimators for smoothing noisy sensor readings.
+package filter
+
+import (
+	"fmt"
+	"math"
+)
+
+// Estimator smooths a stream of measurements. Confidence is in [0, 1]: it
+// drops after sudden changes and recovers as readings agree again, so
+// callers can hold decisions while the estimate is still settling.
+type Estimator interface {
+	Update(x float64) float64
+	Estimate() float64
+	Confidence() float64
+	Reset(x float64)
+	Snapshot() Snapshot
+	Restore(s Snapshot)
+}
+
+// Snapshot is the state of an estimator, apart from its parameters. It is
+// a plain value, so callers can keep, compare and replay estimator states.
+type Snapshot struct {
+	Estimate float64
+	Variance float64 // residual variance (EMA) or error variance (Kalman)
+	Started  bool
+}
+
+// Scaler is implemented by estimators whose responsiveness can be tuned
+// at run time. A factor above 1 follows changes faster, below 1 smooths
+// more; 1 is the configured behaviour.
+type Scaler interface {
+	SetResponsiveness(factor float64)
+}
+
+// ScaleRate turns a per-update rate into the rate of factor updates in a
+// row, so the result stays within (0, 1).
+func ScaleRate(rate, factor float64) float64 {
+	if factor == 1 || rate <= 0 || rate >= 1 {
+		return rate
+	}
+	return 1 - math.Pow(1-rate, factor)
+}
+
+// New returns the estimator named kind ("ema" or "kalman"). alpha is the
+// EMA smoothing factor; q and r are the process and measurement noise
+// variances, in the units of the measurements.
+func New(kind string, alpha, q, r float64) (Estimator, error) {
+	switch kind {
+	case "ema":
+		return NewEMA(alpha, r), nil
+	case "kalman":
+		return NewKalman(q, r), nil
+	}
+	return nil, fmt.Errorf("unknown filter %q (expected ema or kalman)", kind)
+}
+
+// EMA is an exponential moving average that also tracks the exponentially
+// weighted variance of its residuals.
+type EMA struct {
+	alpha    float64
+	base     float64 // alpha before SetResponsiveness
+	r        float64
+	estimate float64
+	variance float64
+	started  bool
+}
+
+// NewEMA returns an EMA with smoothing factor alpha (0..1). r is the
+// expected measurement noise variance, used only to scale Confidence.
+func NewEMA(alpha, r float64) *EMA {
+	return &EMA{alpha: alpha, base: alpha, r: r}
+}
+
+// SetResponsiveness scales alpha as if factor updates happened per
+// reading.
+func (e *EMA) SetResponsiveness(factor float64) {
+	e.alpha = ScaleRate(e.base, factor)
+}
+
+func (e *EMA) Update(x float64) float64 {
+	if !e.started {
+		e.Reset(x)
+		return x
+	}
+	d := x - e.estimate
+	e.estimate += e.alpha * d
+	e.variance = (1 - e.alpha) * (e.variance + e.alpha*d*d)
+	return e.estimate
+}
+
+func (e *EMA) Estimate() float64 { return e.estimate }
+
+// Confidence compares the approximate variance of the estimate
+// (alpha times the residual variance) with the measurement noise.
+func (e *EMA) Confidence() float64 {
+	if !e.started {
+		return 0
+	}
+	if e.r <= 0 {
+		return 1
+	}
+	return e.r / (e.r + e.alpha*e.variance)
+}
+
+func (e *EMA) Reset(x float64) {
+	e.estimate = x
+	e.variance = 0
+	e.started = true
+}
+
+func (e *EMA) Snapshot() Snapshot {
+	return Snapshot{Estimate: e.estimate, Variance: e.variance, Started: e.started}
+}
+
+func (e *EMA) Restore(s Snapshot) {
+	e.estimate, e.variance, e.started = s.Estimate, s.Variance, s.Started
+}
+
+// Kalman is a constant-value Kalman filter. A reading more than three
+// standard deviations from the prediction is treated as a real change:
+// its squared innovation is added to the error variance, which lowers
+// Confidence and lets the estimate catch up quickly.
+type Kalman struct {
+	q, r     float64
+	baseQ    float64 // q before SetResponsiveness
+	estimate float64
+	p        float64
+	started  bool
+}
+
+// NewKalman returns a Kalman filter with process noise q and measurement
+// noise r (both variances).
+func NewKalman(q, r float64) *Kalman {
+	return &Kalman{q: q, r: r, baseQ: q}
+}
+
+// SetResponsiveness scales the process noise: a filter that expects the
+// value to move more follows it faster.
+func (k *Kalman) SetResponsiveness(factor float64) {
+	k.q = k.baseQ * factor * factor
+}
+
+func (k *Kalman) Update(x float64) float64 {
+	if !k.started {
+		k.Reset(x)
+		return x
+	}
+	k.p += k.q
+	y := x - k.estimate
+	if y*y > 9*(k.p+k.r) {
+		k.p += y * y
+	}
+	gain := k.p / (k.p + k.r)
+	k.estimate += gain * y
+	k.p *= 1 - gain
+	return k.estimate
+}
+
+func (k *Kalman) Estimate() float64 { return k.estimate }
+
+// Confidence is r / (p + r): near 1 once the error variance has shrunk
+// below the measurement noise.
+func (k *Kalman) Confidence() float64 {
+	if !k.started {
+		return 0
+	}
+	if k.p+k.r <= 0 {
+		return 1
+	}
+	return k.r / (k.p + k.r)
+}
+
+// Reset starts over at x with an error variance equal to the measurement
+// noise.
+func (k *Kalman) Reset(x float64) {
+	k.estimate = x
+	k.p = k.r
+	k.started = true
+}
+
+func (k *Kalman) Snapshot() Snapshot {
+	return Snapshot{Estimate: k.estimate, Variance: k.p, Started: k.started}
+}
+
+func (k *Kalman) Restore(s Snapshot) {
+	k.estimate, k.p, k.started = s.Estimate, s.Variance, s.Started
+}
@@ -0,0 +1,113 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestEMAMatchesFormula(t *testing.T) {
+	e := NewEMA(0.2, 1)
+	e.Update(10)
+	got := e.Update(20)
+	if math.Abs(got-12) > 1e-9 {
+		t.Errorf("expected 12, got %f", got)
+	}
+}
+
+func TestEMAConfidenceDropsOnChange(t *testing.T) {
+	e := NewEMA(0.2, 1)
+	for i := 0; i < 50; i++ {
+		e.Update(10)
+	}
+	if e.Confidence() != 1 {
+		t.Errorf("expected full confidence on steady input, got %f", e.Confidence())
+	}
+	e.Update(100)
+	if e.Confidence() > 0.5 {
+		t.Errorf("expected low confidence after a jump, got %f", e.Confidence())
+	}
+}
+
+func TestKalmanConverges(t *testing.T) {
+	k := NewKalman(0.01, 1)
+	for i := 0; i < 100; i++ {
+		k.Update(5 + float64(i%2)*2 - 1) // 4, 6, 4, 6 ...
+	}
+	if math.Abs(k.Estimate()-5) > 0.5 {
+		t.Errorf("expected estimate near 5, got %f", k.Estimate())
+	}
+	if k.Confidence() < 0.8 {
+		t.Errorf("expected high confidence once settled, got %f", k.Confidence())
+	}
+}
+
+func TestKalmanTracksStep(t *testing.T) {
+	k := NewKalman(0.01, 1)
+	for i := 0; i < 50; i++ {
+		k.Update(5)
+	}
+	k.Update(50)
+	low := k.Confidence()
+	if k.Estimate() < 40 {
+		t.Errorf("expected estimate to follow the step, got %f", k.Estimate())
+	}
+	for i := 0; i < 20; i++ {
+		k.Update(50)
+	}
+	if k.Confidence() <= low+0.2 {
+		t.Errorf("expected confidence to recover from %f, got %f", low, k.Confidence())
+	}
+}
+
+func TestNewUnknownKind(t *testing.T) {
+	if _, err := New("median", 0.2, 0.01, 1); err == nil {
+		t.Error("expected error for unknown filter")
+	}
+}
+
+func TestEMAResponsivenessMatchesRepeatedUpdates(t *testing.T) {
+	fast := NewEMA(0.2, 0)
+	fast.SetResponsiveness(2)
+	twice := NewEMA(0.2, 0)
+	fast.Update(0)
+	twice.Update(0)
+
+	fast.Update(10)
+	twice.Update(10)
+	twice.Update(10)
+	if math.Abs(fast.Estimate()-twice.Estimate()) > 1e-9 {
+		t.Errorf("expected %f, got %f", twice.Estimate(), fast.Estimate())
+	}
+
+	fast.SetResponsiveness(1)
+	if fast.alpha != 0.2 {
+		t.Errorf("expected alpha back at 0.2, got %f", fast.alpha)
+	}
+}
+
+func TestSnapshotRestoreReplays(t *testing.T) {
+	for _, e := range []Estimator{NewEMA(0.3, 1), NewKalman(0.01, 1)} {
+		e.Update(10)
+		e.Update(12)
+		snap := e.Snapshot()
+		first := e.Update(30)
+
+		e.Update(5)
+		e.Restore(snap)
+		if again := e.Update(30); again != first {
+			t.Errorf("%T: expected %v after restore, got %v", e, first, again)
+		}
+	}
+}
+
+func BenchmarkEstimators(b *testing.B) {
+	for _, e := range []Estimator{NewEMA(0.1, 1), NewKalman(0.01, 1)} {
+		b.Run(fmt.Sprintf("%T", e), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				e.Update(float64(i % 50))
+			}
+		})
+	}
+}